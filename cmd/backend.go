@@ -10,7 +10,13 @@ import (
 
 	"github.com/pdstuber/isit-a-cat/internal/api"
 	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/internal/service/dedup"
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
 	"github.com/pdstuber/isit-a-cat/internal/service/idgenerator"
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/internal/service/similarity"
 	"github.com/pdstuber/isit-a-cat/internal/service/storage"
 	"github.com/pdstuber/isit-a-cat/pkg/prediction"
 	"github.com/spf13/cobra"
@@ -21,14 +27,21 @@ var backendCmd = &cobra.Command{
 	Use:   "backend",
 	Short: "Start the backend to process API requests from the frontend",
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := fetchRegisteredModel(); err != nil {
+			log.Fatalf("could not download model from the registry: %v\n", err)
+		}
+
 		config, err := api.ConfigFromEnv()
 		if err != nil {
 			log.Fatalf("could not create config from environment: %v\n", err)
 		}
 
-		imagePredictor := prediction.NewService(config.Model, config.Labels, defaultColorChannels, config.TFInputOperationName, config.TFOutputOperationName, config.TargetImageDimensions)
+		imagePredictor, err := newReloadableImagePredictor(&config.Config)
+		if err != nil {
+			log.Fatalf("could not create image predictor: %v\n", err)
+		}
 
-		storageService, err := storage.New(config.ObjectStorageBucketName, config.ObjectStorageObjectFolder, config.ObjectStorageEndpoint, config.ObjectStorageAccessKeyID, config.ObjectStorageSecretAccessKey, config.ObjectStorageUseTLS)
+		storageService, err := storage.New(config.ObjectStorage.BucketName, config.ObjectStorage.ObjectFolder, config.ObjectStorage.Endpoint, config.ObjectStorage.AccessKeyID, config.ObjectStorage.SecretAccessKey, config.ObjectStorage.UseTLS)
 		if err != nil {
 			log.Fatalf("could not create storage service: %v\n", err)
 		}
@@ -36,13 +49,59 @@ var backendCmd = &cobra.Command{
 		deps := dep.NewAppDependencies().
 			WithStorageService(storageService).
 			WithIDGenerator(&idgenerator.Service{}).
-			WithImagePredictor(imagePredictor)
+			WithImagePredictor(imagePredictor).
+			WithModelReloader(imagePredictor)
+
+		predictionStore, loaders, err := newPredictionStore(config.PredictionStore, storageService)
+		if err != nil {
+			log.Fatalf("could not create prediction store: %v\n", err)
+		}
+		deps = deps.WithPredictionStore(predictionStore)
+
+		feedbackService := feedback.New(storageService, config.Labels)
+		loaders = append(loaders, feedbackService.Loader())
+		deps = deps.WithFeedback(feedbackService)
+
+		if config.SecondaryModelPath != "" {
+			secondaryConfig, err := model.ConfigFromPath(config.SecondaryModelPath)
+			if err != nil {
+				log.Fatalf("could not load secondary model: %v\n", err)
+			}
+
+			secondaryImagePredictor, err := newImagePredictor(secondaryConfig)
+			if err != nil {
+				log.Fatalf("could not create secondary image predictor: %v\n", err)
+			}
+
+			deps = deps.WithSecondaryImagePredictor(secondaryImagePredictor, config.Experiment)
+			log.Printf("serving secondary model from %s in %s mode\n", config.SecondaryModelPath, config.Experiment.Mode)
+		}
+
+		if embedder, ok := deps.ImagePredictor().(prediction.Embedder); ok && config.EmbeddingTensor != "" {
+			similarityService := similarity.New(embedder, storageService)
+			loaders = append(loaders, similarityService.Loader())
+
+			deps = deps.WithSimilarity(similarityService)
+			log.Printf("indexing embeddings of tensor %s for similar images\n", config.EmbeddingTensor)
+		}
 
-		router := api.NewRouter(deps.Forward(), ":8080")
+		if config.Deduplication.Enabled {
+			deduplicationService := dedup.New(storageService, config.Deduplication.MaxDistance)
+			loaders = append(loaders, deduplicationService.Loader())
+
+			deps = deps.WithDeduplication(deduplicationService)
+			log.Printf("deduplicating uploads within a hamming distance of %d\n", config.Deduplication.MaxDistance)
+		}
+
+		loadInBackground(storageService, loaders...)
+
+		router := api.NewRouter(deps.Forward(), ":8080", config.AdminToken)
 
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 		defer stop()
 
+		reloadOnChange(ctx, &config.Config, imagePredictor)
+
 		router.Start(ctx)
 
 		<-ctx.Done()
@@ -50,6 +109,43 @@ var backendCmd = &cobra.Command{
 	},
 }
 
+// newPredictionStore creates the configured store. The loader of a store in
+// the object storage is returned to load the stored predictions together
+// with the other objects, a store in files loads them in the background.
+func newPredictionStore(config predictionstore.Config, storageService *storage.Service) (predictionstore.Store, []objects.Loader, error) {
+	log.Printf("storing predictions in the %s store\n", config.Backend)
+
+	switch config.Backend {
+	case predictionstore.BackendMemory:
+		return predictionstore.NewMemory(), nil, nil
+	case predictionstore.BackendFile:
+		directory, err := predictionstore.NewDirectory(config.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		store := predictionstore.NewPersistent(directory)
+		loadInBackground(directory, store.Loader())
+		return store, nil, nil
+	}
+
+	store := predictionstore.NewPersistent(storageService)
+	return store, []objects.Loader{store.Loader()}, nil
+}
+
+// loadInBackground loads the stored objects of all loaders with a single
+// listing of the storage
+func loadInBackground(storage objects.Reader, loaders ...objects.Loader) {
+	if len(loaders) == 0 {
+		return
+	}
+
+	go func() {
+		if err := objects.Load(storage, loaders...); err != nil {
+			log.Printf("could not load stored objects: %v\n", err)
+		}
+	}()
+}
+
 func init() {
 	runCmd.AddCommand(backendCmd)
 
@@ -9,7 +9,10 @@ import (
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/pdstuber/isit-a-cat/internal/bot"
-	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +21,10 @@ var botCmd = &cobra.Command{
 	Use:   "bot",
 	Short: "Start the telegram bot.",
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := fetchRegisteredModel(); err != nil {
+			log.Fatalf("could not download model from the registry: %v\n", err)
+		}
+
 		config, err := bot.ConfigFromEnv()
 		if err != nil {
 			log.Fatalf("could not create config from environment: %v\n", err)
@@ -30,13 +37,42 @@ var botCmd = &cobra.Command{
 
 		botAPI.Debug = true
 
-		imagePredictor := prediction.NewService(config.Model, config.Labels, defaultColorChannels, config.TFInputOperationName, config.TFOutputOperationName, config.TargetImageDimensions)
+		imagePredictor, err := newReloadableImagePredictor(&config.Config)
+		if err != nil {
+			log.Fatalf("could not create image predictor: %v\n", err)
+		}
 
 		bot := bot.New(botAPI, imagePredictor)
 
+		if config.Feedback {
+			objectStorage := config.ObjectStorage
+			storageService, err := storage.New(objectStorage.BucketName, objectStorage.ObjectFolder, objectStorage.Endpoint, objectStorage.AccessKeyID, objectStorage.SecretAccessKey, objectStorage.UseTLS)
+			if err != nil {
+				log.Fatalf("could not create storage service: %v\n", err)
+			}
+
+			predictionStore, loaders, err := newPredictionStore(predictionstore.Config{Backend: predictionstore.BackendObject}, storageService)
+			if err != nil {
+				log.Fatalf("could not create prediction store: %v\n", err)
+			}
+
+			feedbackService := feedback.New(storageService, config.Labels)
+			loadInBackground(storageService, append(loaders, feedbackService.Loader())...)
+
+			deps := dep.NewAppDependencies().
+				WithStorageService(storageService).
+				WithImagePredictor(imagePredictor).
+				WithPredictionStore(predictionStore).
+				WithFeedback(feedbackService)
+			bot = bot.WithFeedback(deps.Forward())
+			log.Println("asking for feedback on every answer")
+		}
+
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 		defer stop()
 
+		reloadOnChange(ctx, &config.Config, imagePredictor)
+
 		bot.Start(ctx)
 
 		log.Println("after start")
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"text/tabwriter"
+
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/spf13/cobra"
+)
+
+var (
+	calibrateDataPath  string
+	calibrateModelPath string
+	calibrateMethod    string
+	calibrateBins      int
+	calibrateWrite     bool
+)
+
+// calibrateCmd represents the calibrate command
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Fit the calibration of the probabilities on a folder of labelled images",
+	Long: `Fit the calibration of the probabilities on a folder of labelled images.
+
+The data folder must contain one sub folder per class, named like the class
+in labels.csv, e.g. cats/ and non_cats/. Use other images than the ones the
+model was trained on. The expected calibration error before and after the
+calibration is printed, with --write the calibration is saved as
+calibration.json next to labels.csv, where the backend and the bot load it.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		method, err := prediction.ParseCalibrationMethod(calibrateMethod)
+		if err != nil {
+			log.Fatalf("invalid calibration method: %v\n", err)
+		}
+
+		config, err := loadModelConfig(calibrateModelPath)
+		if err != nil {
+			log.Fatalf("could not load model: %v\n", err)
+		}
+		// the calibration is fit on the raw probabilities of the model
+		config.Calibration = nil
+
+		imagesByClass, err := findLabelledImages(config.Labels, calibrateDataPath)
+		if err != nil {
+			log.Fatalf("could not collect images: %v\n", err)
+		}
+
+		imagePredictor, err := newImagePredictor(config)
+		if err != nil {
+			log.Fatalf("could not create image predictor: %v\n", err)
+		}
+
+		samples, failed := predictLabelledImages(cmd.Context(), imagePredictor, imagesByClass)
+
+		if err := imagePredictor.Stop(); err != nil {
+			log.Printf("could not stop image predictor: %v\n", err)
+		}
+
+		calibrationSamples := make([]prediction.CalibrationSample, 0, len(samples))
+		for _, sample := range samples {
+			calibrationSamples = append(calibrationSamples, prediction.CalibrationSample{
+				Probabilities: sample.Result.Probabilities(config.Labels),
+				Class:         classIndex(config.Labels, sample.Class),
+			})
+		}
+
+		calibration, err := prediction.FitCalibration(method, calibrationSamples)
+		if err != nil {
+			log.Fatalf("could not fit calibration: %v\n", err)
+		}
+		if err := calibration.Validate(len(config.Labels)); err != nil {
+			log.Fatalf("could not fit calibration: %v\n", err)
+		}
+
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Samples:\t%d\n", len(samples))
+		fmt.Fprintf(tw, "Failed:\t%d\n", failed)
+		fmt.Fprintf(tw, "Method:\t%s\n", calibration.Method)
+		if calibration.Method == prediction.CalibrationPlatt {
+			fmt.Fprintf(tw, "A:\t%.4f\n", calibration.A)
+			fmt.Fprintf(tw, "B:\t%.4f\n", calibration.B)
+		} else {
+			fmt.Fprintf(tw, "Temperature:\t%.4f\n", calibration.Temperature)
+		}
+		fmt.Fprintf(tw, "ECE before:\t%.4f\n", prediction.ExpectedCalibrationError(nil, calibrationSamples, calibrateBins))
+		fmt.Fprintf(tw, "ECE after:\t%.4f\n", prediction.ExpectedCalibrationError(calibration, calibrationSamples, calibrateBins))
+		if err := tw.Flush(); err != nil {
+			log.Fatalf("could not write summary: %v\n", err)
+		}
+
+		if calibrateWrite {
+			if err := model.WriteCalibration(config.ModelPath, calibration); err != nil {
+				log.Fatalf("could not write calibration: %v\n", err)
+			}
+		}
+	},
+}
+
+// classIndex returns the index of the class in the labels
+func classIndex(labels []prediction.Label, class string) int {
+	for _, label := range labels {
+		if label.ClassName == class {
+			return label.Index
+		}
+	}
+	return -1
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+
+	calibrateCmd.Flags().StringVar(&calibrateDataPath, "data", "", "folder with one sub folder of images per class")
+	calibrateCmd.Flags().StringVar(&calibrateModelPath, "model-path", "", "directory containing model.pb and labels.csv (defaults to MODEL_PATH)")
+	calibrateCmd.Flags().StringVar(&calibrateMethod, "method", string(prediction.CalibrationTemperature), "calibration method, temperature or platt for models with 2 classes")
+	calibrateCmd.Flags().IntVar(&calibrateBins, "bins", prediction.DefaultCalibrationBins, "number of confidence bins of the expected calibration error")
+	calibrateCmd.Flags().BoolVar(&calibrateWrite, "write", false, "write calibration.json into the model path")
+	calibrateCmd.MarkFlagRequired("data")
+}
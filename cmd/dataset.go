@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pdstuber/isit-a-cat/internal/dataset"
+	"github.com/pdstuber/isit-a-cat/internal/service/dedup"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutPath         string
+	exportMinProbability  float32
+	exportFeedbackOnly    bool
+	exportMaxDistance     int
+	exportValidationSplit float64
+	exportSeed            int64
+)
+
+// datasetCmd represents the dataset command
+var datasetCmd = &cobra.Command{
+	Use:   "dataset",
+	Short: "Build training datasets from the uploaded images",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var datasetExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the labelled uploaded images into one folder per class",
+	Long: `Export the labelled uploaded images into one folder per class.
+
+Images with feedback are labelled with the correct class, images without
+feedback with the stored prediction if it is confident and its probability
+is at least --min-probability. The backend has to store its predictions in
+the object storage, the default PREDICTION_STORE=object, otherwise the export
+fails unless --feedback-only is given. Of images with nearly the same
+perceptual hash only one is exported. The output folder gets one folder per
+class, with --validation-split below train/ and validation/, and a
+manifest.csv listing where the label of every image comes from. learn/learn.py
+reads either layout from its training-images folder and uses the exported
+validation subset instead of its own split. The same --seed puts an image
+into the same subset in every export.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if exportMinProbability < 0 || exportMinProbability > 1 {
+			log.Fatalf("invalid minimum probability: %v is not between 0 and 1\n", exportMinProbability)
+		}
+		if exportValidationSplit < 0 || exportValidationSplit >= 1 {
+			log.Fatalf("invalid validation split: %v is not at least 0 and below 1\n", exportValidationSplit)
+		}
+
+		config, err := storage.ConfigFromEnv()
+		if err != nil {
+			log.Fatalf("could not create config from environment: %v\n", err)
+		}
+
+		storageService, err := storage.New(config.BucketName, config.ObjectFolder, config.Endpoint, config.AccessKeyID, config.SecretAccessKey, config.UseTLS)
+		if err != nil {
+			log.Fatalf("could not create storage service: %v\n", err)
+		}
+
+		summary, err := dataset.Export(storageService, exportOutPath, dataset.Options{
+			MinProbability:  exportMinProbability,
+			FeedbackOnly:    exportFeedbackOnly,
+			MaxDistance:     exportMaxDistance,
+			ValidationSplit: exportValidationSplit,
+			Seed:            exportSeed,
+		})
+		if err != nil {
+			log.Fatalf("could not export dataset: %v\n", err)
+		}
+
+		classes := make([]string, 0, len(summary.PerClass))
+		for class := range summary.PerClass {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Exported:\t%d\n", summary.Exported)
+		fmt.Fprintf(tw, "Duplicates:\t%d\n", summary.Duplicates)
+		fmt.Fprintf(tw, "Failed:\t%d\n", summary.Failed)
+		for _, class := range classes {
+			perSubset := summary.PerClass[class]
+			if exportValidationSplit > 0 {
+				fmt.Fprintf(tw, "%s:\t%d train, %d validation\n", class, perSubset[dataset.SubsetTrain], perSubset[dataset.SubsetValidation])
+			} else {
+				fmt.Fprintf(tw, "%s:\t%d\n", class, perSubset[""])
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			log.Fatalf("could not write summary: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(datasetCmd)
+	datasetCmd.AddCommand(datasetExportCmd)
+
+	datasetExportCmd.Flags().StringVar(&exportOutPath, "out", "", "empty folder the dataset is written to")
+	datasetExportCmd.Flags().Float32Var(&exportMinProbability, "min-probability", 0, "probability a confident prediction needs to label an image without feedback")
+	datasetExportCmd.Flags().BoolVar(&exportFeedbackOnly, "feedback-only", false, "only export images with feedback")
+	datasetExportCmd.Flags().IntVar(&exportMaxDistance, "max-distance", dedup.DefaultMaxDistance, "largest Hamming distance between the perceptual hashes of images exported once, -1 exports all")
+	datasetExportCmd.Flags().Float64Var(&exportValidationSplit, "validation-split", 0, "share of images written to validation/, 0 writes the class folders without a split")
+	datasetExportCmd.Flags().Int64Var(&exportSeed, "seed", 1, "seed of the train/validation split")
+	datasetExportCmd.MarkFlagRequired("out")
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/evaluation"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/spf13/cobra"
+)
+
+var (
+	evaluateDataPath   string
+	evaluateReportPath string
+	evaluateModelPath  string
+	evaluateWorst      int
+)
+
+// evaluateCmd represents the evaluate command
+var evaluateCmd = &cobra.Command{
+	Use:   "evaluate",
+	Short: "Measure the model on a folder of labelled images",
+	Long: `Measure the model on a folder of labelled images.
+
+The data folder must contain one sub folder per class, named like the class
+in labels.csv, e.g. cats/ and non_cats/. A summary is printed and the full
+report is written as json.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := loadModelConfig(evaluateModelPath)
+		if err != nil {
+			log.Fatalf("could not load model: %v\n", err)
+		}
+
+		imagesByClass, err := findLabelledImages(config.Labels, evaluateDataPath)
+		if err != nil {
+			log.Fatalf("could not collect images: %v\n", err)
+		}
+
+		imagePredictor, err := newImagePredictor(config)
+		if err != nil {
+			log.Fatalf("could not create image predictor: %v\n", err)
+		}
+
+		samples, failed := predictLabelledImages(cmd.Context(), imagePredictor, imagesByClass)
+
+		if err := imagePredictor.Stop(); err != nil {
+			log.Printf("could not stop image predictor: %v\n", err)
+		}
+
+		report := evaluation.NewReport(config.Labels, samples, failed, evaluateWorst)
+
+		if err := report.WriteSummary(cmd.OutOrStdout()); err != nil {
+			log.Fatalf("could not write summary: %v\n", err)
+		}
+
+		reportBytes, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatalf("could not marshal report: %v\n", err)
+		}
+		if err := os.WriteFile(evaluateReportPath, reportBytes, 0o644); err != nil {
+			log.Fatalf("could not write report: %v\n", err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(evaluateCmd)
+
+	evaluateCmd.Flags().StringVar(&evaluateDataPath, "data", "", "folder with one sub folder of images per class")
+	evaluateCmd.Flags().StringVar(&evaluateReportPath, "report", "evaluation.json", "file the json report is written to")
+	evaluateCmd.Flags().StringVar(&evaluateModelPath, "model-path", "", "directory containing model.pb and labels.csv (defaults to MODEL_PATH)")
+	evaluateCmd.Flags().IntVar(&evaluateWorst, "worst", 10, "number of worst misclassifications to report")
+	evaluateCmd.MarkFlagRequired("data")
+}
+
+// labelledImages are the paths of the images of a class
+type labelledImages struct {
+	class string
+	paths []string
+}
+
+// findLabelledImages returns the images in the sub folders of dataPath named
+// like the classes of the labels, in the order of the labels and with sorted
+// paths, so that evaluating the same data twice gives the same report
+func findLabelledImages(labels []prediction.Label, dataPath string) ([]labelledImages, error) {
+	sortedLabels := append([]prediction.Label(nil), labels...)
+	sort.Slice(sortedLabels, func(i, j int) bool { return sortedLabels[i].Index < sortedLabels[j].Index })
+
+	var imagesByClass []labelledImages
+	for _, label := range sortedLabels {
+		classPath := filepath.Join(dataPath, label.ClassName)
+		if _, err := os.Stat(classPath); err != nil {
+			log.Printf("no images for class %s: %v\n", label.ClassName, err)
+			continue
+		}
+
+		paths, err := findImages([]string{classPath})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+		imagesByClass = append(imagesByClass, labelledImages{class: label.ClassName, paths: paths})
+	}
+	if len(imagesByClass) == 0 {
+		return nil, fmt.Errorf("%s contains no folder named like a class in labels.csv", dataPath)
+	}
+
+	return imagesByClass, nil
+}
+
+// predictLabelledImages predicts all images and returns the samples and the
+// number of images that could not be predicted
+func predictLabelledImages(ctx context.Context, imagePredictor dep.ImagePredictor, imagesByClass []labelledImages) ([]evaluation.Sample, int) {
+	var samples []evaluation.Sample
+	failed := 0
+	for _, images := range imagesByClass {
+		for _, path := range images.paths {
+			imageBytes, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("could not read %s: %v\n", path, err)
+				failed++
+				continue
+			}
+
+			result, err := imagePredictor.PredictImage(ctx, imageBytes)
+			if err != nil {
+				log.Printf("could not predict %s: %v\n", path, err)
+				failed++
+				continue
+			}
+
+			samples = append(samples, evaluation.Sample{Path: path, Class: images.class, Result: result})
+		}
+	}
+
+	return samples, failed
+}
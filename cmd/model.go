@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/internal/service/reload"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
+
+// loadModelConfig reads the model from modelPath, or from MODEL_PATH if empty
+func loadModelConfig(modelPath string) (*model.Config, error) {
+	if modelPath == "" {
+		return model.ConfigFromEnv()
+	}
+
+	return model.ConfigFromPath(modelPath)
+}
+
+func newImagePredictor(config *model.Config) (*prediction.Service, error) {
+	options := []prediction.Option{
+		prediction.WithModelVersion(config.ModelVersion),
+		prediction.WithResizeMode(config.ResizeMode),
+		prediction.WithAspectPolicy(config.AspectPolicy),
+		prediction.WithThresholds(config.Thresholds),
+		prediction.WithCalibration(config.Calibration),
+		prediction.WithWorkers(config.PredictionWorkers),
+		prediction.WithQueueSize(config.PredictionQueueSize),
+		prediction.WithTimeout(config.PredictionTimeout),
+		prediction.WithMaxBatchSize(config.MaxBatchSize),
+		prediction.WithMaxBatchWait(config.MaxBatchWait),
+		prediction.WithOcclusion(config.ExplanationPatchSize, config.ExplanationStride),
+		prediction.WithEmbedding(config.EmbeddingTensor),
+	}
+
+	switch config.Format {
+	case prediction.FormatWeights:
+		return prediction.NewServiceFromWeights(config.Model, config.Labels, config.TargetImageDimensions, options...)
+	case prediction.FormatSavedModel:
+		return prediction.NewServiceFromSavedModel(config.ModelPath, prediction.SavedModelConfig{
+			Tags:          config.SavedModelTags,
+			SignatureName: config.SavedModelSignature,
+			InputName:     config.TFInputOperationName,
+			OutputName:    config.TFOutputOperationName,
+		}, config.Labels, config.TargetImageDimensions, options...)
+	}
+
+	return prediction.NewService(config.Model, config.Labels, defaultColorChannels, config.TFInputOperationName, config.TFOutputOperationName, config.TargetImageDimensions, options...)
+}
+
+// newModelImagePredictor creates the image predictor for the model in the
+// config, combined with the models of ENSEMBLE_MODEL_PATHS if any are set
+func newModelImagePredictor(config *model.Config) (dep.ImagePredictor, error) {
+	ensembleConfig, err := model.EnsembleConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if !ensembleConfig.Enabled() {
+		imagePredictor, err := newImagePredictor(config)
+		if err != nil {
+			return nil, err
+		}
+		return imagePredictor, nil
+	}
+
+	configs := []*model.Config{config}
+	for _, modelPath := range ensembleConfig.ModelPaths {
+		memberConfig, err := model.ConfigFromPath(modelPath)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, memberConfig)
+	}
+
+	members := make([]prediction.EnsembleMember, 0, len(configs))
+	stopMembers := func() {
+		for _, member := range members {
+			member.Service.Stop()
+		}
+	}
+	for i, memberConfig := range configs {
+		imagePredictor, err := newImagePredictor(memberConfig)
+		if err != nil {
+			stopMembers()
+			return nil, err
+		}
+
+		member := prediction.EnsembleMember{Service: imagePredictor, Weight: 1}
+		if len(ensembleConfig.Weights) > 0 {
+			member.Weight = ensembleConfig.Weights[i]
+		}
+		members = append(members, member)
+	}
+
+	ensemble, err := prediction.NewEnsemble(ensembleConfig.Strategy, members...)
+	if err != nil {
+		stopMembers()
+		return nil, err
+	}
+	return ensemble, nil
+}
+
+// newReloadableImagePredictor creates an image predictor that reads the model
+// from the model path again when it is reloaded. If a version of the registry
+// is configured, it is downloaded again first, so a newly promoted production
+// version is picked up.
+func newReloadableImagePredictor(config *model.Config) (*reload.Predictor, error) {
+	imagePredictor, err := newModelImagePredictor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return reload.New(imagePredictor, func() (dep.ImagePredictor, error) {
+		if err := fetchRegisteredModel(); err != nil {
+			return nil, err
+		}
+
+		reloadedConfig, err := model.ConfigFromPath(config.ModelPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return newModelImagePredictor(reloadedConfig)
+	}), nil
+}
+
+// reloadOnChange reloads the model on SIGHUP and, if a watch interval is
+// configured, when the files in the model path change
+func reloadOnChange(ctx context.Context, config *model.Config, imagePredictor *reload.Predictor) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				log.Println("received SIGHUP, reloading image predictor")
+				if err := imagePredictor.Reload(ctx); err != nil {
+					log.Printf("could not reload image predictor: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	if config.ModelWatchInterval > 0 {
+		go reload.Watch(ctx, config.ModelWatchInterval, config.Files(), imagePredictor.Reload)
+	}
+}
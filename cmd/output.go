@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
+
+const (
+	outputFormatTable = "table"
+	outputFormatJSON  = "json"
+	outputFormatCSV   = "csv"
+)
+
+// A resultWriter prints prediction results for local files
+type resultWriter interface {
+	Write(path string, result *prediction.Result) error
+	Flush() error
+}
+
+func newResultWriter(format string, w io.Writer) (resultWriter, error) {
+	switch format {
+	case outputFormatTable:
+		return newTableResultWriter(w), nil
+	case outputFormatJSON:
+		return &jsonResultWriter{json.NewEncoder(w)}, nil
+	case outputFormatCSV:
+		return newCSVResultWriter(w), nil
+	}
+
+	return nil, fmt.Errorf("unknown output format %q, use one of %s, %s or %s", format, outputFormatTable, outputFormatJSON, outputFormatCSV)
+}
+
+type tableResultWriter struct {
+	w *tabwriter.Writer
+}
+
+func newTableResultWriter(w io.Writer) *tableResultWriter {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tCLASS\tPROBABILITY\tCONFIDENCE\tMODEL VERSION")
+	return &tableResultWriter{tw}
+}
+
+func (t *tableResultWriter) Write(path string, result *prediction.Result) error {
+	_, err := fmt.Fprintf(t.w, "%s\t%s\t%.4f\t%s\t%s\n", path, result.Class, result.Probability, result.Confidence, result.ModelVersion)
+	return err
+}
+
+func (t *tableResultWriter) Flush() error {
+	return t.w.Flush()
+}
+
+type jsonResultWriter struct {
+	encoder *json.Encoder
+}
+
+type jsonResult struct {
+	Path string `json:"path"`
+	*prediction.Result
+}
+
+func (j *jsonResultWriter) Write(path string, result *prediction.Result) error {
+	return j.encoder.Encode(jsonResult{Path: path, Result: result})
+}
+
+func (j *jsonResultWriter) Flush() error {
+	return nil
+}
+
+type csvResultWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVResultWriter(w io.Writer) *csvResultWriter {
+	return &csvResultWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvResultWriter) Write(path string, result *prediction.Result) error {
+	if !c.headerWritten {
+		if err := c.w.Write([]string{"path", "class", "probability", "confidence", "model_version"}); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	return c.w.Write([]string{path, result.Class, strconv.FormatFloat(float64(result.Probability), 'f', -1, 32), string(result.Confidence), result.ModelVersion})
+}
+
+func (c *csvResultWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
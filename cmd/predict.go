@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	predictOutputFormat string
+	predictModelPath    string
+
+	// file extensions picked up when walking a directory
+	imageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".bmp": true}
+)
+
+// predictCmd represents the predict command
+var predictCmd = &cobra.Command{
+	Use:   "predict <file|dir>...",
+	Short: "Predict local image files without any other service running",
+	Long: `Predict local image files with the model from MODEL_PATH.
+
+Directories are searched recursively for images.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		writer, err := newResultWriter(predictOutputFormat, cmd.OutOrStdout())
+		if err != nil {
+			log.Fatalf("%v\n", err)
+		}
+
+		paths, err := findImages(args)
+		if err != nil {
+			log.Fatalf("could not collect images: %v\n", err)
+		}
+
+		config, err := loadModelConfig(predictModelPath)
+		if err != nil {
+			log.Fatalf("could not load model: %v\n", err)
+		}
+
+		imagePredictor, err := newImagePredictor(config)
+		if err != nil {
+			log.Fatalf("could not create image predictor: %v\n", err)
+		}
+
+		failed := 0
+		for _, path := range paths {
+			imageBytes, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("could not read %s: %v\n", path, err)
+				failed++
+				continue
+			}
+
+			result, err := imagePredictor.PredictImage(cmd.Context(), imageBytes)
+			if err != nil {
+				log.Printf("could not predict %s: %v\n", path, err)
+				failed++
+				continue
+			}
+
+			if err := writer.Write(path, result); err != nil {
+				log.Fatalf("could not write result: %v\n", err)
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			log.Fatalf("could not write result: %v\n", err)
+		}
+
+		if err := imagePredictor.Stop(); err != nil {
+			log.Printf("could not stop image predictor: %v\n", err)
+		}
+
+		if failed > 0 {
+			log.Fatalf("%d of %d images could not be predicted\n", failed, len(paths))
+		}
+	},
+}
+
+// findImages returns the given files and all images below the given directories
+func findImages(args []string) ([]string, error) {
+	var paths []string
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(path))] {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+func init() {
+	rootCmd.AddCommand(predictCmd)
+
+	predictCmd.Flags().StringVarP(&predictOutputFormat, "output", "o", outputFormatTable, "output format, one of table, json or csv")
+	predictCmd.Flags().StringVar(&predictModelPath, "model-path", "", "directory containing model.pb and labels.csv (defaults to MODEL_PATH)")
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/internal/registry"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/spf13/cobra"
+)
+
+const modelVersionTimeFormat = "20060102-150405"
+
+var (
+	publishModelPath string
+	publishVersion   string
+	publishMetadata  map[string]string
+	publishPromote   bool
+)
+
+// modelCmd represents the model command
+var modelCmd = &cobra.Command{
+	Use:   "model",
+	Short: "Manage the versions of the model in the model registry",
+	Long: `Manage the versions of the model in the model registry.
+
+The registry is stored in the object storage bucket of the backend. Set
+MODEL_VERSION to a version or to "production" to make the backend and the bot
+download it into MODEL_PATH at startup.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var modelPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Publish the model and labels of a local directory as a new version",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := loadModelConfig(publishModelPath)
+		if err != nil {
+			log.Fatalf("could not load model: %v\n", err)
+		}
+		if config.Format != prediction.FormatFrozenGraph {
+			log.Fatalf("could not publish model: the registry only stores frozen graphs, %s holds a %s model\n", config.ModelPath, config.Format)
+		}
+
+		// make sure the model can be served before anybody promotes it
+		imagePredictor, err := newImagePredictor(config)
+		if err != nil {
+			log.Fatalf("could not create image predictor: %v\n", err)
+		}
+		if err := imagePredictor.Stop(); err != nil {
+			log.Printf("could not stop image predictor: %v\n", err)
+		}
+
+		modelBytes, labelBytes, err := model.ReadFiles(config.ModelPath)
+		if err != nil {
+			log.Fatalf("could not load model: %v\n", err)
+		}
+
+		version := publishVersion
+		if version == "" {
+			version = time.Now().UTC().Format(modelVersionTimeFormat)
+		}
+
+		modelRegistry := mustNewRegistry()
+		manifest, err := modelRegistry.Publish(&registry.Bundle{
+			Manifest: model.Manifest{
+				Version:               version,
+				TargetImageDimensions: config.TargetImageDimensions,
+				TFInputOperationName:  config.TFInputOperationName,
+				TFOutputOperationName: config.TFOutputOperationName,
+				Metadata:              publishMetadata,
+			},
+			Model:  modelBytes,
+			Labels: labelBytes,
+		})
+		if err != nil {
+			log.Fatalf("could not publish model: %v\n", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "published model version %s\n", manifest.Version)
+
+		if publishPromote {
+			if err := modelRegistry.Promote(manifest.Version); err != nil {
+				log.Fatalf("could not promote model: %v\n", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "promoted model version %s to production\n", manifest.Version)
+		}
+	},
+}
+
+var modelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the published versions, the production version is marked with *",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		index, err := mustNewRegistry().Index()
+		if err != nil {
+			log.Fatalf("could not list models: %v\n", err)
+		}
+
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "\tVERSION\tCREATED\tDIMENSIONS\tMETADATA")
+		for _, manifest := range index.Versions {
+			marker := ""
+			if manifest.Version == index.Production {
+				marker = "*"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", marker, manifest.Version, manifest.CreatedAt.Format(time.RFC3339), manifest.TargetImageDimensions, formatMetadata(manifest.Metadata))
+		}
+		if err := tw.Flush(); err != nil {
+			log.Fatalf("could not write models: %v\n", err)
+		}
+	},
+}
+
+var modelPromoteCmd = &cobra.Command{
+	Use:   "promote <version>",
+	Short: "Make a published version the production version",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := mustNewRegistry().Promote(args[0]); err != nil {
+			log.Fatalf("could not promote model: %v\n", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "promoted model version %s to production\n", args[0])
+	},
+}
+
+var modelRollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Make the previous production version the production version again",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		version, err := mustNewRegistry().Rollback()
+		if err != nil {
+			log.Fatalf("could not roll back model: %v\n", err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "rolled back to model version %s\n", version)
+	},
+}
+
+func newRegistry() (*registry.Registry, error) {
+	config, err := registry.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	storageService, err := storage.New(config.ObjectStorage.BucketName, config.ObjectStorage.ObjectFolder, config.ObjectStorage.Endpoint, config.ObjectStorage.AccessKeyID, config.ObjectStorage.SecretAccessKey, config.ObjectStorage.UseTLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return registry.New(storageService), nil
+}
+
+// mustNewRegistry creates the registry for the model commands
+func mustNewRegistry() *registry.Registry {
+	modelRegistry, err := newRegistry()
+	if err != nil {
+		log.Fatalf("could not create model registry: %v\n", err)
+	}
+	return modelRegistry
+}
+
+// fetchRegisteredModel downloads the version set in MODEL_VERSION from the
+// registry into MODEL_PATH. Without MODEL_VERSION the model already in
+// MODEL_PATH is used.
+func fetchRegisteredModel() error {
+	version := registry.ModelVersionFromEnv()
+	if version == "" {
+		return nil
+	}
+
+	modelRegistry, err := newRegistry()
+	if err != nil {
+		return err
+	}
+
+	manifest, err := modelRegistry.Download(version, model.PathFromEnv())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("downloaded model version %s from the registry\n", manifest.Version)
+	return nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	pairs := make([]string, 0, len(metadata))
+	for key, value := range metadata {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func init() {
+	rootCmd.AddCommand(modelCmd)
+	modelCmd.AddCommand(modelPublishCmd, modelListCmd, modelPromoteCmd, modelRollbackCmd)
+
+	modelPublishCmd.Flags().StringVar(&publishModelPath, "model-path", "", "directory containing model.pb and labels.csv, defaults to MODEL_PATH")
+	modelPublishCmd.Flags().StringVar(&publishVersion, "version", "", "name of the new version, defaults to the current time")
+	modelPublishCmd.Flags().StringToStringVar(&publishMetadata, "metadata", nil, "training metadata stored with the version, e.g. --metadata epochs=20,dataset=2024-01")
+	modelPublishCmd.Flags().BoolVar(&publishPromote, "promote", false, "promote the new version to production")
+}
@@ -5,24 +5,23 @@ import (
 	"os"
 	"strconv"
 
-	"github.com/gocarina/gocsv"
-	"github.com/pdstuber/isit-a-cat/pkg/prediction"
-	"github.com/pkg/errors"
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/internal/service/dedup"
+	"github.com/pdstuber/isit-a-cat/internal/service/experiment"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
 )
 
 type Config struct {
-	ListenPort                   string
-	Labels                       []prediction.Label
-	Model                        []byte
-	TargetImageDimensions        int
-	TFInputOperationName         string
-	TFOutputOperationName        string
-	ObjectStorageEndpoint        string
-	ObjectStorageAccessKeyID     string
-	ObjectStorageSecretAccessKey string
-	ObjectStorageUseTLS          bool
-	ObjectStorageBucketName      string
-	ObjectStorageObjectFolder    string
+	ListenPort string
+	model.Config
+	ObjectStorage storage.Config
+	AdminToken    string
+	// SecondaryModelPath enables the experiment when set
+	SecondaryModelPath string
+	Experiment         experiment.Config
+	Deduplication      dedup.Config
+	PredictionStore    predictionstore.Config
 }
 
 func getEnv(key, fallback string) string {
@@ -35,59 +34,55 @@ func getEnv(key, fallback string) string {
 func ConfigFromEnv() (*Config, error) {
 	listenPort := getEnv("LISTEN_PORT", ":8080")
 
-	var labels []prediction.Label
-
-	modelPath := getEnv("MODEL_PATH", "/model")
-	model, err := os.ReadFile(fmt.Sprintf("%s/model.pb", modelPath))
+	modelConfig, err := model.ConfigFromEnv()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not read model")
+		return nil, err
 	}
-	labelBytes, err := os.ReadFile(fmt.Sprintf("%s/labels.csv", modelPath))
+
+	objectStorageConfig, err := storage.ConfigFromEnv()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not read labels")
+		return nil, err
 	}
 
-	if err := gocsv.UnmarshalBytes(labelBytes, &labels); err != nil {
-		return nil, errors.Wrap(err, "could not unmarshal labels csv")
-	}
+	adminToken := getEnv("ADMIN_TOKEN", "")
 
-	targetImageDimensions, err := strconv.Atoi(getEnv("TARGET_IMAGE_DIMENSIONS", "256"))
+	secondaryModelPath := getEnv("SECONDARY_MODEL_PATH", "")
+
+	experimentMode, err := experiment.ParseMode(getEnv("EXPERIMENT_MODE", string(experiment.ModeShadow)))
 	if err != nil {
-		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+		return nil, err
 	}
 
-	inputOperationName := getEnv("TF_INPUT_OPERATION_NAME", "input_1")
-	outputOperationName := getEnv("TF_OUTPUT_OPERATION_NAME", "dense_3/Softmax")
-
-	objectStorageEndpoint := getEnv("OBJECT_STORAGE_ENDPOINT", "minio:9000")
-	objectStorageAccessKeyID := getEnv("MINIO_ACCESS_KEY", "")
-	if objectStorageAccessKeyID == "" {
-		return nil, errors.New("object storage access key id is mandatory")
+	experimentPercentage, err := strconv.Atoi(getEnv("EXPERIMENT_PERCENTAGE", "10"))
+	if err != nil {
+		return nil, err
 	}
-	objectStorageSecretKey := getEnv("MINIO_SECRET_KEY", "")
-	if objectStorageSecretKey == "" {
-		return nil, errors.New("object storage secret key is mandatory")
+	if experimentPercentage < 0 || experimentPercentage > 100 {
+		return nil, fmt.Errorf("EXPERIMENT_PERCENTAGE must be between 0 and 100, got %d", experimentPercentage)
 	}
-	objectStorageUseTLS, err := strconv.ParseBool(getEnv("OBJECT_STORAGE_USE_TLS", "false"))
+
+	deduplicationConfig, err := dedup.ConfigFromEnv()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not parse as boolean")
+		return nil, err
 	}
 
-	storageBucketName := getEnv("STORAGE_BUCKET_NAME", "isit-a-cat")
-	storageObjectFolder := getEnv("STORAGE_OBJECT_FOLDER", "uploaded-images/")
+	predictionStoreConfig, err := predictionstore.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
 
 	return &Config{
-		ListenPort:                   listenPort,
-		Labels:                       labels,
-		Model:                        model,
-		TargetImageDimensions:        targetImageDimensions,
-		TFInputOperationName:         inputOperationName,
-		TFOutputOperationName:        outputOperationName,
-		ObjectStorageEndpoint:        objectStorageEndpoint,
-		ObjectStorageAccessKeyID:     objectStorageAccessKeyID,
-		ObjectStorageSecretAccessKey: objectStorageSecretKey,
-		ObjectStorageUseTLS:          objectStorageUseTLS,
-		ObjectStorageBucketName:      storageBucketName,
-		ObjectStorageObjectFolder:    storageObjectFolder,
+		ListenPort:    listenPort,
+		Config:        *modelConfig,
+		ObjectStorage: *objectStorageConfig,
+		AdminToken:    adminToken,
+
+		SecondaryModelPath: secondaryModelPath,
+		Experiment: experiment.Config{
+			Mode:       experimentMode,
+			Percentage: experimentPercentage,
+		},
+		Deduplication:   *deduplicationConfig,
+		PredictionStore: *predictionStoreConfig,
 	}, nil
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+// Error types shared by the handlers and messages shared by those working on
+// uploaded images
+const (
+	ErrorTypeServerError            = "SERVER_ERROR"
+	ErrorTypeClientError            = "CLIENT_ERROR"
+	ErrorTextMissingID              = "request is missing mandatory path parameter 'id'"
+	ErrorTextImageNotFound          = "there is no image with the given id"
+	ErrorTextUnsupportedImageFormat = "unsupported image format, please use a JPEG, PNG, GIF, WebP or BMP image"
+	ErrorTextInvalidImage           = "the image could not be decoded"
+	ErrorTextServiceBusy            = "too many predictions at the moment, please try again later"
+)
+
+// An ErrorResponse is sent back to the client in case an error occurred
+type ErrorResponse struct {
+	ErrorType string
+	Message   string
+}
+
+// ServerErrorResponse is sent for errors the client cannot do anything about
+var ServerErrorResponse = ErrorResponse{
+	ErrorType: ErrorTypeServerError,
+}
+
+// ErrorResponseFor maps an error of reading or predicting an uploaded image
+// to the http status and the response sent to the client
+func ErrorResponseFor(err error) (int, *ErrorResponse) {
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return fiber.StatusNotFound, &ErrorResponse{
+			ErrorType: ErrorTypeClientError,
+			Message:   ErrorTextImageNotFound,
+		}
+	}
+	if errors.Is(err, prediction.ErrUnsupportedImageFormat) {
+		return fiber.StatusUnsupportedMediaType, &ErrorResponse{
+			ErrorType: ErrorTypeClientError,
+			Message:   ErrorTextUnsupportedImageFormat,
+		}
+	}
+	if errors.Is(err, prediction.ErrInvalidImage) {
+		return fiber.StatusBadRequest, &ErrorResponse{
+			ErrorType: ErrorTypeClientError,
+			Message:   ErrorTextInvalidImage,
+		}
+	}
+	if errors.Is(err, prediction.ErrQueueFull) || errors.Is(err, context.DeadlineExceeded) {
+		return fiber.StatusServiceUnavailable, &ErrorResponse{
+			ErrorType: ErrorTypeServerError,
+			Message:   ErrorTextServiceBusy,
+		}
+	}
+
+	return fiber.StatusInternalServerError, &ServerErrorResponse
+}
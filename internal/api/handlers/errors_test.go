@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	pkgErrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ErrorResponseFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		status   int
+		response *ErrorResponse
+	}{
+		{"image not found", pkgErrors.Wrap(storage.ErrObjectNotFound, "cat"), fiber.StatusNotFound, &ErrorResponse{ErrorTypeClientError, ErrorTextImageNotFound}},
+		{"unsupported format", pkgErrors.Wrap(prediction.ErrUnsupportedImageFormat, "tiff"), fiber.StatusUnsupportedMediaType, &ErrorResponse{ErrorTypeClientError, ErrorTextUnsupportedImageFormat}},
+		{"invalid image", prediction.ErrInvalidImage, fiber.StatusBadRequest, &ErrorResponse{ErrorTypeClientError, ErrorTextInvalidImage}},
+		{"queue full", prediction.ErrQueueFull, fiber.StatusServiceUnavailable, &ErrorResponse{ErrorTypeServerError, ErrorTextServiceBusy}},
+		{"deadline", context.DeadlineExceeded, fiber.StatusServiceUnavailable, &ErrorResponse{ErrorTypeServerError, ErrorTextServiceBusy}},
+		{"other", errors.New("everything went to hell"), fiber.StatusInternalServerError, &ServerErrorResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := ErrorResponseFor(tt.err)
+
+			assert.Equal(t, tt.status, status)
+			assert.Equal(t, tt.response, response)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package experimentstats
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+)
+
+const (
+	errorTextNoExperiment = "no secondary model is configured"
+)
+
+type handlerDependencies interface {
+	dep.CanForwardDependencies
+}
+
+// Handler reports how the secondary model compares to the primary one
+type Handler struct {
+	deps handlerDependencies
+}
+
+// An ErrorResponse is sent back to the client in case an error occurred
+type ErrorResponse = handlers.ErrorResponse
+
+// NewHandler creates an instance of the experiment stats handler
+func NewHandler(deps handlerDependencies) *Handler {
+	return &Handler{deps}
+}
+
+// Handle responds with the stats of the running experiment
+func (h *Handler) Handle(ctx *fiber.Ctx) error {
+	experiment := h.deps.Forward().Experiment()
+	if experiment == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   errorTextNoExperiment,
+		})
+	}
+
+	return ctx.JSON(experiment.Stats())
+}
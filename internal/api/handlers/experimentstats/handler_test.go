@@ -0,0 +1,66 @@
+package experimentstats
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/experimentstats/mocks"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/experiment"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const experimentURL = "/experiment"
+
+func newTestApp(deps dep.AppDependencies) *fiber.App {
+	app := fiber.New()
+	app.Get(experimentURL, NewHandler(deps.Forward()).Handle)
+	return app
+}
+
+func Test_Handle_good_case(t *testing.T) {
+	primaryMock := new(mocks.ImagePredictor)
+	secondaryMock := new(mocks.ImagePredictor)
+	primaryMock.On("PredictImage", mock.Anything, mock.Anything).Return(&pkgPrediction.Result{Class: "cats"}, nil)
+	secondaryMock.On("PredictImage", mock.Anything, mock.Anything).Return(&pkgPrediction.Result{Class: "cats"}, nil)
+	primaryMock.On("Stop").Return(nil)
+	secondaryMock.On("Stop").Return(nil)
+
+	deps := dep.NewAppDependencies().
+		WithImagePredictor(primaryMock).
+		WithSecondaryImagePredictor(secondaryMock, experiment.Config{Mode: experiment.ModeShadow})
+	_, _ = deps.ImagePredictor().PredictImage(context.Background(), []byte{1})
+	_ = deps.ImagePredictor().Stop()
+
+	resp, err := newTestApp(deps).Test(httptest.NewRequest(http.MethodGet, experimentURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := experiment.Stats{}
+	_ = json.NewDecoder(resp.Body).Decode(&stats)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, experiment.ModeShadow, stats.Mode)
+	assert.Equal(t, uint64(1), stats.Compared)
+	assert.Equal(t, 1.0, stats.AgreementRate)
+}
+
+func Test_Handle_no_experiment(t *testing.T) {
+	resp, err := newTestApp(dep.NewAppDependencies()).Test(httptest.NewRequest(http.MethodGet, experimentURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, errorTextNoExperiment, errorResponse.Message)
+}
@@ -0,0 +1,81 @@
+package explanation
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/prediction"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	errorTextUnsupported = "the model cannot explain its predictions"
+
+	headerPredictedClass = "X-Predicted-Class"
+	headerProbability    = "X-Probability"
+	headerConfidence     = "X-Confidence"
+)
+
+type handlerDependencies interface {
+	dep.CanForwardDependencies
+}
+
+// Handler explains predictions with a heatmap of the image regions they
+// depend on
+type Handler struct {
+	deps handlerDependencies
+}
+
+// An ErrorResponse is sent back to the client in case an error occurred
+type ErrorResponse = handlers.ErrorResponse
+
+// NewHandler creates an instance of the explanation handler
+func NewHandler(deps handlerDependencies) *Handler {
+	return &Handler{deps}
+}
+
+// Handle responds with the heatmap drawn over the resized image as PNG. The
+// prediction it explains is sent in the X-Predicted-Class, X-Probability and
+// X-Confidence headers.
+func (h *Handler) Handle(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+
+	if id == "" {
+		log.Println(handlers.ErrorTextMissingID)
+		return ctx.Status(fiber.StatusBadRequest).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   handlers.ErrorTextMissingID,
+		})
+	}
+
+	explanation, err := prediction.ExplainPrediction(ctx.UserContext(), h.deps.Forward(), id)
+	if err != nil {
+		log.Printf("Error explaining prediction: %v\n", err)
+		status, errorResponse := errorResponseFor(err)
+		return ctx.Status(status).JSON(errorResponse)
+	}
+
+	ctx.Set(headerPredictedClass, explanation.Result.Class)
+	ctx.Set(headerProbability, strconv.FormatFloat(float64(explanation.Result.Probability), 'f', -1, 32))
+	ctx.Set(headerConfidence, string(explanation.Result.Confidence))
+	ctx.Type("png")
+
+	return ctx.Send(explanation.Overlay)
+}
+
+// errorResponseFor maps an error of the prediction service to the http status
+// and the response sent to the client
+func errorResponseFor(err error) (int, *ErrorResponse) {
+	if errors.Is(err, pkgPrediction.ErrExplanationUnsupported) {
+		return fiber.StatusNotImplemented, &ErrorResponse{
+			ErrorType: handlers.ErrorTypeServerError,
+			Message:   errorTextUnsupported,
+		}
+	}
+
+	return handlers.ErrorResponseFor(err)
+}
@@ -0,0 +1,133 @@
+package explanation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/explanation/mocks"
+	getpredictionmocks "github.com/pdstuber/isit-a-cat/internal/api/handlers/getprediction/mocks"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	explanationURL = "/predictions/12345/explanation"
+	testID         = "12345"
+	mockErrorText  = "everything went to hell"
+)
+
+var (
+	mockImage       = []byte{1, 2, 3, 4, 5}
+	mockOverlay     = []byte("\x89PNG overlay")
+	mockExplanation = pkgPrediction.Explanation{
+		Result:  &pkgPrediction.Result{Class: "cats", Probability: 0.75, Confidence: pkgPrediction.ConfidenceUncertain},
+		Size:    256,
+		Overlay: mockOverlay,
+	}
+	errMock = errors.New(mockErrorText)
+)
+
+func newTestApp(imagePredictor dep.ImagePredictor, storageService *mocks.StorageService) *fiber.App {
+	deps := dep.NewAppDependencies().
+		WithStorageService(storageService).
+		WithImagePredictor(imagePredictor)
+
+	app := fiber.New()
+	app.Get("/predictions/:id/explanation", NewHandler(deps.Forward()).Handle)
+
+	return app
+}
+
+func Test_Handle_good_case(t *testing.T) {
+	explainerMock := new(mocks.ImageExplainer)
+	storageMock := new(mocks.StorageService)
+	storageMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	explainerMock.On("ExplainImage", mock.Anything, mockImage).Return(&mockExplanation, nil)
+
+	resp, err := newTestApp(explainerMock, storageMock).Test(httptest.NewRequest(http.MethodGet, explanationURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/png", resp.Header.Get(fiber.HeaderContentType))
+	assert.Equal(t, "cats", resp.Header.Get(headerPredictedClass))
+	assert.Equal(t, "0.75", resp.Header.Get(headerProbability))
+	assert.Equal(t, "uncertain", resp.Header.Get(headerConfidence))
+	assert.Equal(t, mockOverlay, body)
+}
+
+func Test_Handle_unsupported(t *testing.T) {
+	predictorMock := new(getpredictionmocks.ImagePredictor)
+	storageMock := new(mocks.StorageService)
+
+	resp, err := newTestApp(predictorMock, storageMock).Test(httptest.NewRequest(http.MethodGet, explanationURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+	assert.Equal(t, errorTextUnsupported, errorResponse.Message)
+	storageMock.AssertNotCalled(t, "ReadFromBucketObject", mock.Anything)
+}
+
+func Test_Handle_errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		explainErr     error
+		expectedStatus int
+		expectedText   string
+	}{
+		{"unsupported image", pkgPrediction.ErrUnsupportedImageFormat, http.StatusUnsupportedMediaType, handlers.ErrorTextUnsupportedImageFormat},
+		{"invalid image", pkgPrediction.ErrInvalidImage, http.StatusBadRequest, handlers.ErrorTextInvalidImage},
+		{"busy", pkgPrediction.ErrQueueFull, http.StatusServiceUnavailable, handlers.ErrorTextServiceBusy},
+		{"unsupported by the current model", pkgPrediction.ErrExplanationUnsupported, http.StatusNotImplemented, errorTextUnsupported},
+		{"model failed", errMock, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explainerMock := new(mocks.ImageExplainer)
+			storageMock := new(mocks.StorageService)
+			storageMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+			explainerMock.On("ExplainImage", mock.Anything, mockImage).Return(nil, tt.explainErr)
+
+			resp, err := newTestApp(explainerMock, storageMock).Test(httptest.NewRequest(http.MethodGet, explanationURL, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errorResponse := ErrorResponse{}
+			_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedText, errorResponse.Message)
+		})
+	}
+}
+
+func Test_Handle_storage_error(t *testing.T) {
+	explainerMock := new(mocks.ImageExplainer)
+	storageMock := new(mocks.StorageService)
+	storageMock.On("ReadFromBucketObject", testID).Return(nil, errMock)
+
+	resp, err := newTestApp(explainerMock, storageMock).Test(httptest.NewRequest(http.MethodGet, explanationURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	explainerMock.AssertNotCalled(t, "ExplainImage", mock.Anything, mock.Anything)
+}
@@ -1,26 +1,18 @@
 package getprediction
 
 import (
+	"context"
 	"log"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
 	"github.com/pdstuber/isit-a-cat/internal/dep"
 	"github.com/pdstuber/isit-a-cat/internal/service/prediction"
 	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
 	"github.com/pkg/errors"
 )
 
-const (
-	errorTypeServerError = "SERVER_ERROR"
-	errorTypeClientError = "CLIENT_ERROR"
-	errorTextMissingID   = "request is missing mandatory path parameter 'id'"
-)
-
-var serverErrorResponse = ErrorResponse{
-	ErrorType: errorTypeServerError,
-}
-
 type handerDependencies interface {
 	dep.CanForwardDependencies
 }
@@ -37,26 +29,31 @@ type Result struct {
 }
 
 // An ErrorResponse is sent back to the client in case an error occurred
-type ErrorResponse struct {
-	ErrorType string
-	Message   string
-}
+type ErrorResponse = handlers.ErrorResponse
 
 // NewHandler creates an instance of the prediction handler
 func NewHandler(deps handerDependencies) *Handler {
 	return &Handler{deps}
 }
 
-// ServeHTTP requests on the image prediction endpoint
+// ServeHTTP requests on the image prediction endpoint. Websocket upgrade
+// requests get the result pushed over the socket, all others receive json.
 func (h *Handler) Handle(ctx *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(ctx) {
+		return h.handleHTTP(ctx)
+	}
+
+	// closed when the server shuts down, the request context must not be used
+	// once the connection is upgraded
+	shutdown := ctx.Context().Done()
 	websocketHandler := websocket.New(func(c *websocket.Conn) {
 		id := c.Params("id")
 
 		if id == "" {
-			log.Println(errorTextMissingID)
+			log.Println(handlers.ErrorTextMissingID)
 			clientErrorResponse := ErrorResponse{
-				ErrorType: errorTypeClientError,
-				Message:   errorTextMissingID,
+				ErrorType: handlers.ErrorTypeClientError,
+				Message:   handlers.ErrorTextMissingID,
 			}
 			err := c.WriteJSON(&clientErrorResponse)
 			if err != nil {
@@ -65,29 +62,56 @@ func (h *Handler) Handle(ctx *fiber.Ctx) error {
 			return
 		}
 
-		h.triggerPrediction(id, c)
+		h.triggerPrediction(id, c, shutdown)
 	})
 	return websocketHandler(ctx)
 }
 
 // TODO better websocket errors
-func (h *Handler) triggerPrediction(id string, ws *websocket.Conn) {
+// The prediction is cancelled when the client closes the connection or the
+// server shuts down.
+func (h *Handler) triggerPrediction(id string, ws *websocket.Conn, shutdown <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	readerDone := make(chan struct{})
 	defer func() {
+		cancel()
 		err := ws.Close()
 		if err != nil {
 			log.Printf("Error closing websocket: %v\n", err)
 		}
+		// the connection is released once the handler returns
+		<-readerDone
+	}()
+
+	go func() {
+		defer close(readerDone)
+		// clients do not send messages, so reading only fails once the
+		// connection is closed
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+	go func() {
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
 	}()
 
 	predictionResultChannel := make(chan Result)
 
-	go h.getPredictionFromService(id, predictionResultChannel)
+	go h.getPredictionFromService(ctx, id, predictionResultChannel)
 
 	prediction := <-predictionResultChannel
 
 	if prediction.error != nil {
 		log.Printf("Error getting predictions: %v\n", prediction.error)
-		err := ws.WriteJSON(&serverErrorResponse)
+		_, errorResponse := handlers.ErrorResponseFor(prediction.error)
+		err := ws.WriteJSON(errorResponse)
 		if err != nil {
 			log.Printf("error in writing websocket error response: %v\n", err)
 		}
@@ -96,7 +120,7 @@ func (h *Handler) triggerPrediction(id string, ws *websocket.Conn) {
 	if err := ws.WriteJSON(prediction.Result); err != nil {
 		log.Printf("error writing json response to websocket : %v\n", err)
 
-		err := ws.WriteJSON(&serverErrorResponse)
+		err := ws.WriteJSON(&handlers.ServerErrorResponse)
 		if err != nil {
 			log.Printf("error in writing websocket error response: %v\n", err)
 		}
@@ -104,12 +128,34 @@ func (h *Handler) triggerPrediction(id string, ws *websocket.Conn) {
 	}
 }
 
-func (h Handler) getPredictionFromService(id string, predictionResultChannel chan Result) {
-	imagePrediction, err := prediction.CalculatePrediction(h.deps.Forward(), id)
+func (h Handler) getPredictionFromService(ctx context.Context, id string, predictionResultChannel chan Result) {
+	imagePrediction, err := prediction.CalculatePrediction(ctx, h.deps.Forward(), id)
 
 	if err != nil {
 		predictionResultChannel <- Result{nil, errors.Wrap(err, "Error getting prediction from prediction service")}
+		return
 	}
 
 	predictionResultChannel <- Result{imagePrediction, nil}
 }
+
+func (h *Handler) handleHTTP(ctx *fiber.Ctx) error {
+	id := ctx.Params("id")
+
+	if id == "" {
+		log.Println(handlers.ErrorTextMissingID)
+		return ctx.Status(fiber.StatusBadRequest).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   handlers.ErrorTextMissingID,
+		})
+	}
+
+	imagePrediction, err := prediction.CalculatePrediction(ctx.UserContext(), h.deps.Forward(), id)
+	if err != nil {
+		log.Printf("Error getting predictions: %v\n", err)
+		status, errorResponse := handlers.ErrorResponseFor(err)
+		return ctx.Status(status).JSON(errorResponse)
+	}
+
+	return ctx.JSON(imagePrediction)
+}
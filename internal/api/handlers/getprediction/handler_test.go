@@ -1,107 +1,416 @@
 package getprediction
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
 	"net/http/httptest"
-	"strings"
 	"testing"
+	"time"
 
-	"github.com/gorilla/mux"
+	"github.com/gofiber/fiber/v2"
 	"github.com/gorilla/websocket"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/getprediction/mocks"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	pkgErrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"gitlab.com/pdstuber/isit-a-cat-bff/prediction/mocks"
-	"gitlab.com/pdstuber/isit-a-cat-bff/prediction/predictionservice"
 )
 
 const (
 	predictionURL = "/predictions"
+	predictURL    = "/predict"
 	testID        = "12345"
 	mockErrorText = "everything went to hell"
 )
 
 var (
-	mockPrediction = predictionservice.Prediction{
-		Class:       "cat",
+	mockImage      = []byte{1, 2, 3, 4, 5}
+	mockPrediction = pkgPrediction.Result{
+		Class:       "cats",
 		Probability: 0.99,
+		Scores: []pkgPrediction.ClassScore{
+			{Class: "cats", Probability: 0.99},
+			{Class: "non_cats", Probability: 0.01},
+		},
 	}
 	errMock = errors.New(mockErrorText)
 )
 
-func Test_ServeHTTP_good_case(t *testing.T) {
+func newTestApp(imagePredictor *mocks.ImagePredictor, storageService *mocks.StorageService) *fiber.App {
+	deps := dep.NewAppDependencies().
+		WithStorageService(storageService).
+		WithImagePredictor(imagePredictor)
 
-	predictionServiceMock := new(mocks.PredictionService)
+	predictionHandler := NewHandler(deps.Forward())
 
-	predictionServiceMock.On("GetPredictionForImageID", mock.Anything).Return(&mockPrediction, nil)
-	predictionHandler := NewHandler(predictionServiceMock, &websocket.Upgrader{})
+	app := fiber.New()
+	app.Get(predictionURL, predictionHandler.Handle)
+	app.Get(predictionURL+"/:id", predictionHandler.Handle)
+	app.Post(predictURL, predictionHandler.HandleUpload)
 
-	router := mux.NewRouter()
-	router.HandleFunc(predictionURL+"/{id}", predictionHandler.ServeHTTP)
+	return app
+}
+
+func dialWebsocket(t *testing.T, app *fiber.App, path string) *websocket.Conn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { _ = app.Shutdown() })
 
-	s := httptest.NewServer(router)
-	defer s.Close()
+	ws, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s%s", ln.Addr().String(), path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
 
-	url := fmt.Sprintf("%s%s%s", "ws", strings.TrimPrefix(s.URL, "http"), fmt.Sprintf(predictionURL+"/%s", testID))
+	return ws
+}
 
-	ws, _, _ := websocket.DefaultDialer.Dial(url, nil)
+func Test_Handle_websocket_good_case(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
 
-	defer ws.Close()
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&mockPrediction, nil)
 
-	prediction := predictionservice.Prediction{}
+	ws := dialWebsocket(t, newTestApp(imagePredictorMock, storageServiceMock), predictionURL+"/"+testID)
 
+	prediction := pkgPrediction.Result{}
 	_ = ws.ReadJSON(&prediction)
 
 	assert.Equal(t, mockPrediction, prediction)
 }
 
-func Test_ServeHTTP_error_prediction_service(t *testing.T) {
-	predictionServiceMock := new(mocks.PredictionService)
+func Test_Handle_websocket_error_prediction_service(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
 
-	predictionServiceMock.On("GetPredictionForImageID", mock.Anything).Return(nil, errMock)
-	predictionHandler := NewHandler(predictionServiceMock, &websocket.Upgrader{})
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(nil, errMock)
 
-	router := mux.NewRouter()
-	router.HandleFunc(predictionURL+"/{id}", predictionHandler.ServeHTTP)
+	ws := dialWebsocket(t, newTestApp(imagePredictorMock, storageServiceMock), predictionURL+"/"+testID)
 
-	s := httptest.NewServer(router)
-	defer s.Close()
+	errorResponse := ErrorResponse{}
+	_ = ws.ReadJSON(&errorResponse)
 
-	url := fmt.Sprintf("%s%s%s", "ws", strings.TrimPrefix(s.URL, "http"), fmt.Sprintf(predictionURL+"/%s", testID))
+	assert.Equal(t, handlers.ErrorTypeServerError, errorResponse.ErrorType)
+}
 
-	ws, _, _ := websocket.DefaultDialer.Dial(url, nil)
+func Test_Handle_websocket_cancelled_when_client_closes(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+	started, cancelled := make(chan struct{}), make(chan struct{})
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(context.Context).Done()
+		close(cancelled)
+	}).Return(nil, context.Canceled)
+
+	ws := dialWebsocket(t, newTestApp(imagePredictorMock, storageServiceMock), predictionURL+"/"+testID)
+	<-started
+	ws.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prediction was not cancelled after the client closed the connection")
+	}
+}
 
-	defer ws.Close()
+func Test_Handle_websocket_cancelled_on_shutdown(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+	started, cancelled := make(chan struct{}), make(chan struct{})
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Run(func(args mock.Arguments) {
+		close(started)
+		<-args.Get(0).(context.Context).Done()
+		close(cancelled)
+	}).Return(nil, context.Canceled)
+
+	app := newTestApp(imagePredictorMock, storageServiceMock)
+	dialWebsocket(t, app, predictionURL+"/"+testID)
+	<-started
+	go app.Shutdown()
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("prediction was not cancelled when the server shut down")
+	}
+}
 
-	errorResponse := ErrorResponse{}
+func Test_Handle_websocket_missing_id(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	ws := dialWebsocket(t, newTestApp(imagePredictorMock, storageServiceMock), predictionURL)
 
+	errorResponse := ErrorResponse{}
 	_ = ws.ReadJSON(&errorResponse)
 
-	assert.Equal(t, errorTypeServerError, errorResponse.ErrorType)
+	storageServiceMock.AssertNumberOfCalls(t, "ReadFromBucketObject", 0)
+	assert.Equal(t, handlers.ErrorTypeClientError, errorResponse.ErrorType)
+	assert.Equal(t, handlers.ErrorTextMissingID, errorResponse.Message)
+}
+
+func Test_Handle_http_good_case(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&mockPrediction, nil)
+
+	req := httptest.NewRequest(http.MethodGet, predictionURL+"/"+testID, nil)
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prediction := pkgPrediction.Result{}
+	_ = json.NewDecoder(resp.Body).Decode(&prediction)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, mockPrediction, prediction)
 }
 
-func Test_ServeHTTP_missing_id(t *testing.T) {
-	predictionServiceMock := new(mocks.PredictionService)
+func Test_Handle_http_stored_prediction(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+	store := predictionstore.NewMemory()
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&mockPrediction, nil)
 
-	predictionServiceMock.On("GetPredictionForImageID", mock.Anything).Return(&mockPrediction, nil)
-	predictionHandler := NewHandler(predictionServiceMock, &websocket.Upgrader{})
+	deps := dep.NewAppDependencies().
+		WithStorageService(storageServiceMock).
+		WithImagePredictor(imagePredictorMock).
+		WithPredictionStore(store)
+	app := fiber.New()
+	app.Get(predictionURL+"/:id", NewHandler(deps.Forward()).Handle)
 
-	router := mux.NewRouter()
-	router.HandleFunc(predictionURL, predictionHandler.ServeHTTP)
+	for i := 0; i < 2; i++ {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, predictionURL+"/"+testID, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	s := httptest.NewServer(router)
-	defer s.Close()
+		prediction := pkgPrediction.Result{}
+		_ = json.NewDecoder(resp.Body).Decode(&prediction)
 
-	url := fmt.Sprintf("%s%s%s", "ws", strings.TrimPrefix(s.URL, "http"), predictionURL)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, mockPrediction, prediction)
+	}
 
-	ws, _, _ := websocket.DefaultDialer.Dial(url, nil)
+	imagePredictorMock.AssertNumberOfCalls(t, "PredictImage", 1)
+	storageServiceMock.AssertNumberOfCalls(t, "ReadFromBucketObject", 1)
+	record, err := store.Get(testID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testID, record.ImageID)
+		assert.Equal(t, &mockPrediction, record.Result)
+		assert.False(t, record.CreatedAt.IsZero())
+	}
+}
 
-	defer ws.Close()
+// versionedPredictor reports the version of the model like a loaded model
+type versionedPredictor struct {
+	*mocks.ImagePredictor
+	version string
+}
+
+func (v versionedPredictor) ModelVersion(context.Context) string {
+	return v.version
+}
+
+func Test_Handle_http_stored_prediction_of_other_version(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+	store := predictionstore.NewMemory()
+
+	v2Prediction := mockPrediction
+	v2Prediction.ModelVersion = "v2"
+	v1Prediction := mockPrediction
+	v1Prediction.ModelVersion = "v1"
+	v1Prediction.Class = "non_cats"
+	assert.NoError(t, store.Put(&predictionstore.Record{ImageID: testID, Result: &v1Prediction}))
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&v2Prediction, nil)
+
+	deps := dep.NewAppDependencies().
+		WithStorageService(storageServiceMock).
+		WithImagePredictor(versionedPredictor{imagePredictorMock, "v2"}).
+		WithPredictionStore(store)
+	app := fiber.New()
+	app.Get(predictionURL+"/:id", NewHandler(deps.Forward()).Handle)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, predictionURL+"/"+testID, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prediction := pkgPrediction.Result{}
+	_ = json.NewDecoder(resp.Body).Decode(&prediction)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, v2Prediction, prediction)
+	imagePredictorMock.AssertNumberOfCalls(t, "PredictImage", 1)
+	record, err := store.Get(testID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "v2", record.ModelVersion)
+	}
+}
+
+func Test_Handle_http_error_storage(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(nil, errMock)
+
+	req := httptest.NewRequest(http.MethodGet, predictionURL+"/"+testID, nil)
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
 
-	_ = ws.ReadJSON(&errorResponse)
+	imagePredictorMock.AssertNumberOfCalls(t, "PredictImage", 0)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, handlers.ErrorTypeServerError, errorResponse.ErrorType)
+}
+
+func Test_Handle_http_image_not_found(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(nil, pkgErrors.Wrap(storage.ErrObjectNotFound, testID))
+
+	req := httptest.NewRequest(http.MethodGet, predictionURL+"/"+testID, nil)
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	imagePredictorMock.AssertNumberOfCalls(t, "PredictImage", 0)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, handlers.ErrorTypeClientError, errorResponse.ErrorType)
+	assert.Equal(t, handlers.ErrorTextImageNotFound, errorResponse.Message)
+}
+
+func Test_HandleUpload_raw_body(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&mockPrediction, nil)
+
+	req := httptest.NewRequest(http.MethodPost, predictURL, bytes.NewReader(mockImage))
+	req.Header.Set(fiber.HeaderContentType, "image/jpeg")
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prediction := pkgPrediction.Result{}
+	_ = json.NewDecoder(resp.Body).Decode(&prediction)
+
+	storageServiceMock.AssertNumberOfCalls(t, "ReadFromBucketObject", 0)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, mockPrediction, prediction)
+}
+
+func Test_HandleUpload_multipart_form(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&mockPrediction, nil)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile(fileFormKey, "image.jpg")
+	_, _ = part.Write(mockImage)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, predictURL, &body)
+	req.Header.Set(fiber.HeaderContentType, writer.FormDataContentType())
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imagePredictorMock.AssertCalled(t, "PredictImage", mock.Anything, mockImage)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func Test_HandleUpload_missing_image(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	req := httptest.NewRequest(http.MethodPost, predictURL, nil)
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	imagePredictorMock.AssertNumberOfCalls(t, "PredictImage", 0)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, handlers.ErrorTypeClientError, errorResponse.ErrorType)
+	assert.Equal(t, errorTextMissingImage, errorResponse.Message)
+}
+
+func Test_HandleUpload_error_prediction(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	imagePredictorMock.On("PredictImage", mock.Anything, mock.Anything).Return(nil, errMock)
+
+	req := httptest.NewRequest(http.MethodPost, predictURL, bytes.NewReader(mockImage))
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func Test_HandleUpload_unsupported_image_format(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	imagePredictorMock.On("PredictImage", mock.Anything, mock.Anything).Return(nil, pkgErrors.Wrap(pkgPrediction.ErrUnsupportedImageFormat, "could not process input image"))
+
+	req := httptest.NewRequest(http.MethodPost, predictURL, bytes.NewReader(mockImage))
+	resp, err := newTestApp(imagePredictorMock, storageServiceMock).Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
 
-	assert.Equal(t, errorTypeClientError, errorResponse.ErrorType)
-	assert.Equal(t, errorTextMissingID, errorResponse.Message)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	assert.Equal(t, handlers.ErrorTypeClientError, errorResponse.ErrorType)
+	assert.Equal(t, handlers.ErrorTextUnsupportedImageFormat, errorResponse.Message)
 }
@@ -0,0 +1,67 @@
+package getprediction
+
+import (
+	"io"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/service/prediction"
+)
+
+const (
+	fileFormKey                    = "file"
+	contentTypeMultipartForm       = "multipart/form-data"
+	errorTextMissingImage          = "request body is missing the image to predict"
+	errorTextInvalidFormFile       = "invalid form key. Please provide an image file under the key 'file'"
+	errorTextCouldNotReadImageForm = "could not read image from http form"
+)
+
+// HandleUpload predicts the image sent in the request body, either raw or as
+// multipart form file, and answers with the result in a single round trip.
+func (h *Handler) HandleUpload(ctx *fiber.Ctx) error {
+	image, errorResponse := readImage(ctx)
+	if errorResponse != nil {
+		log.Println(errorResponse.Message)
+		return ctx.Status(fiber.StatusBadRequest).JSON(errorResponse)
+	}
+
+	imagePrediction, err := prediction.CalculatePredictionForImage(ctx.UserContext(), h.deps.Forward(), image)
+	if err != nil {
+		log.Printf("Error getting predictions: %v\n", err)
+		status, errorResponse := handlers.ErrorResponseFor(err)
+		return ctx.Status(status).JSON(errorResponse)
+	}
+
+	return ctx.JSON(imagePrediction)
+}
+
+func readImage(ctx *fiber.Ctx) ([]byte, *ErrorResponse) {
+	if !strings.HasPrefix(ctx.Get(fiber.HeaderContentType), contentTypeMultipartForm) {
+		body := ctx.Body()
+		if len(body) == 0 {
+			return nil, &ErrorResponse{ErrorType: handlers.ErrorTypeClientError, Message: errorTextMissingImage}
+		}
+		// the body is only valid until the handler returns
+		return append([]byte(nil), body...), nil
+	}
+
+	fileHeader, err := ctx.FormFile(fileFormKey)
+	if err != nil {
+		return nil, &ErrorResponse{ErrorType: handlers.ErrorTypeClientError, Message: errorTextInvalidFormFile}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, &ErrorResponse{ErrorType: handlers.ErrorTypeClientError, Message: errorTextCouldNotReadImageForm}
+	}
+	defer file.Close()
+
+	image, err := io.ReadAll(file)
+	if err != nil || len(image) == 0 {
+		return nil, &ErrorResponse{ErrorType: handlers.ErrorTypeClientError, Message: errorTextCouldNotReadImageForm}
+	}
+
+	return image, nil
+}
@@ -2,15 +2,15 @@ package imageretrieval
 
 import (
 	"log"
+	"net/http"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/pdstuber/isit-a-cat/internal/dep"
 )
 
 const (
-	headerNameContentType      = "Content-Type"
-	headerValueContentTypeJpeg = "image/jpeg"
-	errorTextMissingID         = "request is missing mandatory path parameter 'id'"
+	headerNameContentType = "Content-Type"
+	errorTextMissingID    = "request is missing mandatory path parameter 'id'"
 )
 
 type handlerDependencies interface {
@@ -50,6 +50,6 @@ func (h *Handler) Handle(c *fiber.Ctx) error {
 		return fiber.ErrInternalServerError
 	}
 
-	c.Set(headerNameContentType, headerValueContentTypeJpeg)
+	c.Set(headerNameContentType, http.DetectContentType(image))
 	return c.Send(image)
 }
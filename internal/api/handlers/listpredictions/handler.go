@@ -0,0 +1,164 @@
+package listpredictions
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+	dateLayout   = "2006-01-02"
+
+	errorTextNoStore           = "predictions are not stored"
+	errorTextInvalidOffset     = "query parameter 'offset' must be a number of at least 0"
+	errorTextInvalidLimit      = "query parameter 'limit' must be a number between 1 and 100"
+	errorTextInvalidRange      = "query parameters 'minProbability' and 'maxProbability' must be numbers between 0 and 1"
+	errorTextEmptyRange        = "query parameter 'minProbability' must not be greater than 'maxProbability'"
+	errorTextInvalidDate       = "query parameters 'from' and 'to' must be dates like 2024-01-31 or times like 2024-01-31T12:00:00Z"
+	errorTextInvalidConfidence = "query parameter 'confidence' must be one of confident, uncertain or rejected"
+)
+
+type handlerDependencies interface {
+	dep.CanForwardDependencies
+}
+
+// Handler lists stored predictions
+type Handler struct {
+	deps handlerDependencies
+}
+
+// An ErrorResponse is sent back to the client in case an error occurred
+type ErrorResponse = handlers.ErrorResponse
+
+// NewHandler creates an instance of the prediction history handler
+func NewHandler(deps handlerDependencies) *Handler {
+	return &Handler{deps}
+}
+
+// Handle responds with a page of the stored predictions, the newest first.
+// They can be filtered by class, confidence, a probability range and the
+// time they were made.
+func (h *Handler) Handle(ctx *fiber.Ctx) error {
+	store := h.deps.Forward().PredictionStore()
+	if store == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   errorTextNoStore,
+		})
+	}
+
+	query, errorText := parseQuery(ctx)
+	if errorText != "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   errorText,
+		})
+	}
+
+	page, err := store.List(query)
+	if err != nil {
+		log.Printf("Error listing predictions: %v\n", err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&handlers.ServerErrorResponse)
+	}
+
+	return ctx.JSON(page)
+}
+
+// parseQuery returns the query of the request, or the error text sent to the
+// client if it is invalid
+func parseQuery(ctx *fiber.Ctx) (predictionstore.Query, string) {
+	query := predictionstore.Query{
+		Filter: predictionstore.Filter{
+			Class:      ctx.Query("class"),
+			Confidence: pkgPrediction.Confidence(ctx.Query("confidence")),
+		},
+		Limit: defaultLimit,
+	}
+
+	switch query.Confidence {
+	case "", pkgPrediction.ConfidenceConfident, pkgPrediction.ConfidenceUncertain, pkgPrediction.ConfidenceRejected:
+	default:
+		return query, errorTextInvalidConfidence
+	}
+
+	var ok bool
+	if query.Offset, ok = intParam(ctx, "offset", 0, 0, -1); !ok {
+		return query, errorTextInvalidOffset
+	}
+	if query.Limit, ok = intParam(ctx, "limit", defaultLimit, 1, maxLimit); !ok {
+		return query, errorTextInvalidLimit
+	}
+	minProbability, ok := probabilityParam(ctx, "minProbability")
+	if !ok {
+		return query, errorTextInvalidRange
+	}
+	if minProbability != nil {
+		query.MinProbability = *minProbability
+	}
+	if query.MaxProbability, ok = probabilityParam(ctx, "maxProbability"); !ok {
+		return query, errorTextInvalidRange
+	}
+	if query.MaxProbability != nil && query.MinProbability > *query.MaxProbability {
+		return query, errorTextEmptyRange
+	}
+	if query.From, ok = timeParam(ctx, "from"); !ok {
+		return query, errorTextInvalidDate
+	}
+	if query.To, ok = timeParam(ctx, "to"); !ok {
+		return query, errorTextInvalidDate
+	}
+
+	return query, ""
+}
+
+// intParam parses the query parameter, a negative maximum means no limit
+func intParam(ctx *fiber.Ctx, key string, fallback, minimum, maximum int) (int, bool) {
+	value := ctx.Query(key)
+	if value == "" {
+		return fallback, true
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < minimum || (maximum >= 0 && parsed > maximum) {
+		return 0, false
+	}
+	return parsed, true
+}
+
+// probabilityParam parses the query parameter, nil if it is not set
+func probabilityParam(ctx *fiber.Ctx, key string) (*float32, bool) {
+	value := ctx.Query(key)
+	if value == "" {
+		return nil, true
+	}
+
+	parsed, err := strconv.ParseFloat(value, 32)
+	if err != nil || parsed < 0 || parsed > 1 {
+		return nil, false
+	}
+	probability := float32(parsed)
+	return &probability, true
+}
+
+// timeParam accepts RFC 3339 times and dates, which start at midnight UTC
+func timeParam(ctx *fiber.Ctx, key string) (time.Time, bool) {
+	value := ctx.Query(key)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	for _, layout := range []string{time.RFC3339, dateLayout} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
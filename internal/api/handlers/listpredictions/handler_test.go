@@ -0,0 +1,150 @@
+package listpredictions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+const predictionsURL = "/predictions"
+
+var baseTime = time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+func newTestApp(t *testing.T) *fiber.App {
+	store := predictionstore.NewMemory()
+	for i, record := range []struct {
+		id          string
+		class       string
+		probability float32
+		confidence  pkgPrediction.Confidence
+	}{
+		{"a", "cats", 0.95, pkgPrediction.ConfidenceConfident},
+		{"b", "non_cats", 0.7, pkgPrediction.ConfidenceUncertain},
+		{"c", "cats", 0.55, pkgPrediction.ConfidenceRejected},
+	} {
+		assert.NoError(t, store.Put(&predictionstore.Record{
+			ImageID:   record.id,
+			Result:    &pkgPrediction.Result{Class: record.class, Probability: record.probability, Confidence: record.confidence},
+			CreatedAt: baseTime.AddDate(0, 0, i),
+		}))
+	}
+
+	return newApp(dep.NewAppDependencies().WithPredictionStore(store))
+}
+
+func newApp(deps dep.AppDependencies) *fiber.App {
+	app := fiber.New()
+	app.Get(predictionsURL, NewHandler(deps.Forward()).Handle)
+	return app
+}
+
+func listPredictions(t *testing.T, app *fiber.App, query string) (*http.Response, predictionstore.Page) {
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, predictionsURL+query, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := predictionstore.Page{}
+	_ = json.NewDecoder(resp.Body).Decode(&page)
+	return resp, page
+}
+
+func imageIDs(page predictionstore.Page) []string {
+	ids := []string{}
+	for _, record := range page.Records {
+		ids = append(ids, record.ImageID)
+	}
+	return ids
+}
+
+func Test_Handle_good_case(t *testing.T) {
+	resp, page := listPredictions(t, newTestApp(t), "")
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, defaultLimit, page.Limit)
+	assert.Equal(t, []string{"c", "b", "a"}, imageIDs(page))
+	assert.Equal(t, "cats", page.Records[0].Class)
+	assert.True(t, baseTime.AddDate(0, 0, 2).Equal(page.Records[0].CreatedAt))
+}
+
+func Test_Handle_filters(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+		total    int
+	}{
+		{"?limit=1&offset=1", []string{"b"}, 3},
+		{"?class=cats", []string{"c", "a"}, 2},
+		{"?confidence=uncertain", []string{"b"}, 1},
+		{"?minProbability=0.6&maxProbability=0.9", []string{"b"}, 1},
+		{"?maxProbability=0", []string{}, 0},
+		{"?minProbability=0.7&maxProbability=0.7", []string{"b"}, 1},
+		{"?from=2024-02-01", []string{"c", "b"}, 2},
+		{"?to=2024-02-01T12:00:00Z", []string{"a"}, 1},
+		{"?class=cats&from=2024-02-02&limit=5", []string{"c"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, page := listPredictions(t, newTestApp(t), tt.query)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, tt.expected, imageIDs(page))
+			assert.Equal(t, tt.total, page.Total)
+		})
+	}
+}
+
+func Test_Handle_invalid_query(t *testing.T) {
+	tests := []struct {
+		query        string
+		expectedText string
+	}{
+		{"?offset=-1", errorTextInvalidOffset},
+		{"?limit=0", errorTextInvalidLimit},
+		{"?limit=101", errorTextInvalidLimit},
+		{"?minProbability=high", errorTextInvalidRange},
+		{"?maxProbability=1.5", errorTextInvalidRange},
+		{"?minProbability=0.9&maxProbability=0.6", errorTextEmptyRange},
+		{"?from=yesterday", errorTextInvalidDate},
+		{"?to=31.01.2024", errorTextInvalidDate},
+		{"?confidence=certain", errorTextInvalidConfidence},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := newTestApp(t).Test(httptest.NewRequest(http.MethodGet, predictionsURL+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errorResponse := ErrorResponse{}
+			_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, tt.expectedText, errorResponse.Message)
+		})
+	}
+}
+
+func Test_Handle_no_store(t *testing.T) {
+	resp, err := newApp(dep.NewAppDependencies()).Test(httptest.NewRequest(http.MethodGet, predictionsURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, errorTextNoStore, errorResponse.Message)
+}
@@ -1,11 +1,15 @@
 package postimage
 
 import (
+	"context"
 	"io"
 	"log"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/pdstuber/isit-a-cat/internal/dep"
+	servicePrediction "github.com/pdstuber/isit-a-cat/internal/service/prediction"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -15,17 +19,29 @@ const (
 	staticPictureName          = "picture.jpg"
 	errorTextInvalidForm       = "invalid or missing http form data"
 	errorTextInvalidFormFile   = "invalid form key. Please provide an image file under they key 'file'"
+	errorTextUnsupportedFormat = "unsupported image format, please use a JPEG, PNG, GIF, WebP or BMP image"
+	errorTextInvalidImage      = "the image could not be decoded"
 )
 
 // ImgParams are parameters that identify an image
 type ImgParams struct {
 	ID           string `json:"id"`
 	OriginalName string `json:"originalName"`
+	// Deduplicated is set when the image was uploaded before and ID is the
+	// one of the earlier upload
+	Deduplicated bool `json:"deduplicated"`
+	// Prediction of the earlier upload, if the current model predicted it
+	// and it was stored
+	Prediction *prediction.Result `json:"prediction,omitempty"`
 }
 
 type handerDependencies interface {
 	dep.HasStorageWriter
 	dep.HasIDGenerator
+	dep.HasSimilarity
+	dep.HasDeduplication
+	dep.HasPredictionStore
+	dep.HasImagePredictor
 }
 
 // Handler handles http requests for uploading images
@@ -69,11 +85,57 @@ func (h *Handler) Handle(c *fiber.Ctx) error {
 		return fiber.ErrInternalServerError
 	}
 
+	if _, err := prediction.DetectImageFormat(data); err != nil {
+		log.Printf("Rejected uploaded image: %v\n", err)
+		if errors.Is(err, prediction.ErrUnsupportedImageFormat) {
+			return fiber.NewError(fiber.StatusUnsupportedMediaType, errorTextUnsupportedFormat)
+		}
+		return fiber.NewError(fiber.StatusBadRequest, errorTextInvalidImage)
+	}
+
+	deduplication := h.deps.Deduplication()
+	var hash uint64
+	if deduplication != nil {
+		hash, err = prediction.PerceptualHash(data)
+		if err != nil {
+			log.Printf("Rejected uploaded image: %v\n", err)
+			return fiber.NewError(fiber.StatusBadRequest, errorTextInvalidImage)
+		}
+
+		if duplicate, ok := deduplication.Find(hash); ok {
+			log.Printf("Uploaded image is a duplicate of %s with distance %d\n", duplicate.ID, duplicate.Distance)
+			imgParams := ImgParams{
+				ID:           duplicate.ID,
+				OriginalName: staticPictureName,
+				Deduplicated: true,
+			}
+			if record, ok := servicePrediction.StoredPrediction(c.UserContext(), h.deps, duplicate.ID); ok {
+				imgParams.Prediction = record.Result
+			}
+			return c.JSON(imgParams, headerValueContentTypeJSON)
+		}
+	}
+
 	err = h.deps.StorageWriter().WriteToBucketObject(id, data)
 	if err != nil {
 		log.Printf("Could not upload image to object storage: %v\n", err)
 		return fiber.ErrInternalServerError
 	}
+	if deduplication != nil {
+		if err := deduplication.Add(id, hash); err != nil {
+			log.Printf("Could not index uploaded image %s for deduplication: %v\n", id, err)
+		}
+	}
+	if similarity := h.deps.Similarity(); similarity != nil {
+		// the upload does not wait for the embedding, images that are not
+		// indexed yet are indexed when similar images are requested
+		go func(ctx context.Context) {
+			if err := similarity.IndexImage(ctx, id, data); err != nil {
+				log.Printf("Could not index uploaded image %s: %v\n", id, err)
+			}
+		}(context.WithoutCancel(c.UserContext()))
+	}
+
 	imgParams := ImgParams{
 		ID:           id,
 		OriginalName: staticPictureName,
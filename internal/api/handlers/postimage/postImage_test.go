@@ -1,166 +1,299 @@
-package postimage_test
+package postimage
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"image"
+	"image/color"
+	"image/png"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
-	"github.com/gorilla/mux"
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/postimage/mocks"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/dedup"
+	"github.com/pdstuber/isit-a-cat/internal/service/objects/objectstest"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
-	"gitlab.com/pdstuber/isit-a-cat-bff/imageretrieval"
-	"gitlab.com/pdstuber/isit-a-cat-bff/imageupload/mocks"
 )
 
 const (
 	postImageURL       = "/images"
 	mockID             = "12346"
+	duplicateID        = "12345"
 	mockErrorText      = "everything went to hell"
 	invalidFormFileKey = "image"
 )
 
 var (
-	mockImage     = []byte{9, 10, 17, 12, 16}
+	mockImage     = gradientPNG()
 	errMock       = errors.New(mockErrorText)
-	mockImgParams = imageretrieval.ImgParams{
+	mockImgParams = ImgParams{
 		ID:           mockID,
 		OriginalName: staticPictureName,
 	}
+	mockPrediction = pkgPrediction.Result{Class: "cats", Probability: 0.97}
 )
 
-func Test_ServeHTTP_good_case(t *testing.T) {
-	storageServiceMock := new(mocks.StorageServiceMock)
+// gradientPNG is an image with a perceptual hash that is not zero
+func gradientPNG() []byte {
+	img := image.NewGray(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8((x*x + 3*y) % 256)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+func newMocks() (*mocks.StorageService, *mocks.IDGenerator) {
+	storageServiceMock := new(mocks.StorageService)
 	storageServiceMock.On("WriteToBucketObject", mock.Anything, mock.Anything).Return(nil)
 
 	idGenerator := new(mocks.IDGenerator)
-	idGenerator.On("GenerateID", mock.Anything).Return(mockID)
+	idGenerator.On("GenerateID").Return(mockID)
+
+	return storageServiceMock, idGenerator
+}
 
-	postImageHandler := NewHandler(storageServiceMock, idGenerator)
+func newApp(deps dep.AppDependencies) *fiber.App {
+	app := fiber.New()
+	app.Post(postImageURL, NewHandler(deps.Forward()).Handle)
+	return app
+}
 
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
-	go func() {
-		defer writer.Close()
-		part, _ := writer.CreateFormFile("file", "image.jpg")
-		_, _ = part.Write(mockImage)
-	}()
+func newTestApp(storageService *mocks.StorageService, idGenerator *mocks.IDGenerator) *fiber.App {
+	return newApp(dep.NewAppDependencies().
+		WithStorageService(storageService).
+		WithIDGenerator(idGenerator))
+}
 
-	req, err := http.NewRequest("POST", postImageURL, pr)
+func newUploadRequest(t *testing.T, formFileKey string, image []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(formFileKey, "image.png")
 	if err != nil {
 		t.Fatal(err)
 	}
+	_, _ = part.Write(image)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
 
-	req.Header.Add("Content-Type", writer.FormDataContentType())
+	req := httptest.NewRequest(http.MethodPost, postImageURL, &body)
+	req.Header.Add(headerNameContentType, writer.FormDataContentType())
+	return req
+}
 
-	rr := httptest.NewRecorder()
-	router := mux.NewRouter()
-	router.HandleFunc(postImageURL, postImageHandler.ServeHTTP)
-	router.ServeHTTP(rr, req)
+func readBody(t *testing.T, resp *http.Response) string {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
 
-	storageServiceMock.AssertNumberOfCalls(t, "WriteToBucketObject", 1)
+func Test_Handle_good_case(t *testing.T) {
+	storageServiceMock, idGenerator := newMocks()
+
+	resp, err := newTestApp(storageServiceMock, idGenerator).Test(newUploadRequest(t, fileFormKey, mockImage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storageServiceMock.AssertCalled(t, "WriteToBucketObject", mockID, mockImage)
 
-	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Equal(t, headerValueContentTypeJSON, rr.Header().Get(headerNameContentType))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, headerValueContentTypeJSON, resp.Header.Get(headerNameContentType))
 
 	expectedImgParams, _ := json.Marshal(mockImgParams)
-	assert.Equal(t, expectedImgParams, rr.Body.Bytes())
+	assert.Equal(t, string(expectedImgParams), readBody(t, resp))
 }
 
-func Test_ServeHTTP_Form_File_Error(t *testing.T) {
-	storageServiceMock := new(mocks.StorageServiceMock)
-	storageServiceMock.On("WriteToBucketObject", mock.Anything, mock.Anything).Return(nil)
+func Test_Handle_form_file_error(t *testing.T) {
+	storageServiceMock, idGenerator := newMocks()
 
-	idGenerator := new(mocks.IDGenerator)
-	idGenerator.On("GenerateID", mock.Anything).Return(mockID)
+	resp, err := newTestApp(storageServiceMock, idGenerator).Test(newUploadRequest(t, invalidFormFileKey, mockImage))
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	postImageHandler := NewHandler(storageServiceMock, idGenerator)
+	storageServiceMock.AssertNotCalled(t, "WriteToBucketObject", mock.Anything, mock.Anything)
 
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
-	go func() {
-		defer writer.Close()
-		part, _ := writer.CreateFormFile(invalidFormFileKey, "image.jpg")
-		_, _ = part.Write(mockImage)
-	}()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, errorTextInvalidFormFile, readBody(t, resp))
+}
 
-	req, err := http.NewRequest("POST", postImageURL, pr)
+func Test_Handle_multi_part_form_parsing_error(t *testing.T) {
+	storageServiceMock, idGenerator := newMocks()
+
+	resp, err := newTestApp(storageServiceMock, idGenerator).Test(httptest.NewRequest(http.MethodPost, postImageURL, nil))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	req.Header.Add("Content-Type", writer.FormDataContentType())
+	storageServiceMock.AssertNotCalled(t, "WriteToBucketObject", mock.Anything, mock.Anything)
 
-	rr := httptest.NewRecorder()
-	router := mux.NewRouter()
-	router.HandleFunc(postImageURL, postImageHandler.ServeHTTP)
-	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, errorTextInvalidForm, readBody(t, resp))
+}
 
-	storageServiceMock.AssertNumberOfCalls(t, "WriteToBucketObject", 0)
+func Test_Handle_invalid_images(t *testing.T) {
+	tests := []struct {
+		name           string
+		image          []byte
+		expectedStatus int
+		expectedText   string
+	}{
+		{"unsupported format", []byte("%PDF-1.7"), http.StatusUnsupportedMediaType, errorTextUnsupportedFormat},
+		{"broken header", mockImage[:20], http.StatusBadRequest, errorTextInvalidImage},
+	}
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	assert.Equal(t, errorTextInvalidFormFile+"\n", rr.Body.String())
-}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storageServiceMock, idGenerator := newMocks()
 
-func Test_ServeHTTP_multi_part_form_parsing_error(t *testing.T) {
-	storageServiceMock := new(mocks.StorageServiceMock)
-	storageServiceMock.On("WriteToBucketObject", mock.Anything, mock.Anything).Return(nil)
+			resp, err := newTestApp(storageServiceMock, idGenerator).Test(newUploadRequest(t, fileFormKey, tt.image))
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	idGenerator := new(mocks.IDGenerator)
-	idGenerator.On("GenerateID", mock.Anything).Return(mockID)
+			storageServiceMock.AssertNotCalled(t, "WriteToBucketObject", mock.Anything, mock.Anything)
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedText, readBody(t, resp))
+		})
+	}
+}
 
-	postImageHandler := NewHandler(storageServiceMock, idGenerator)
+func Test_Handle_error_storage_service(t *testing.T) {
+	storageServiceMock := new(mocks.StorageService)
+	storageServiceMock.On("WriteToBucketObject", mock.Anything, mock.Anything).Return(errMock)
+	_, idGenerator := newMocks()
 
-	req, err := http.NewRequest("POST", postImageURL, nil)
+	resp, err := newTestApp(storageServiceMock, idGenerator).Test(newUploadRequest(t, fileFormKey, mockImage))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	rr := httptest.NewRecorder()
-	router := mux.NewRouter()
-	router.HandleFunc(postImageURL, postImageHandler.ServeHTTP)
-	router.ServeHTTP(rr, req)
-
-	storageServiceMock.AssertNumberOfCalls(t, "WriteToBucketObject", 0)
+	storageServiceMock.AssertNumberOfCalls(t, "WriteToBucketObject", 1)
 
-	assert.Equal(t, http.StatusBadRequest, rr.Code)
-	assert.Equal(t, errorTextInvalidForm+"\n", rr.Body.String())
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), readBody(t, resp))
 }
 
-func Test_ServeHTTP_error_storage_service(t *testing.T) {
-	storageServiceMock := new(mocks.StorageServiceMock)
-	storageServiceMock.On("WriteToBucketObject", mock.Anything, mock.Anything).Return(errMock)
+func Test_Handle_deduplication_miss(t *testing.T) {
+	storageServiceMock, idGenerator := newMocks()
+	hashStorage := objectstest.NewStorage()
 
-	idGenerator := new(mocks.IDGenerator)
-	idGenerator.On("GenerateID", mock.Anything).Return(mockID)
+	deps := dep.NewAppDependencies().
+		WithStorageService(storageServiceMock).
+		WithIDGenerator(idGenerator).
+		WithDeduplication(dedup.New(hashStorage, 0))
+	resp, err := newApp(deps).Test(newUploadRequest(t, fileFormKey, mockImage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imgParams := ImgParams{}
+	_ = json.NewDecoder(resp.Body).Decode(&imgParams)
 
-	postImageHandler := NewHandler(storageServiceMock, idGenerator)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, mockImgParams, imgParams)
+	storageServiceMock.AssertCalled(t, "WriteToBucketObject", mockID, mockImage)
 
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
-	go func() {
-		defer writer.Close()
-		part, _ := writer.CreateFormFile("file", "image.jpg")
-		_, _ = part.Write(mockImage)
-	}()
+	hash, err := pkgPrediction.PerceptualHash(mockImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duplicate, ok := deps.Deduplication().Find(hash)
+	if assert.True(t, ok) {
+		assert.Equal(t, mockID, duplicate.ID)
+	}
+	assert.Contains(t, hashStorage.Objects, mockID+dedup.HashSuffix)
+}
 
-	req, err := http.NewRequest("POST", postImageURL, pr)
+func Test_Handle_deduplication_hit(t *testing.T) {
+	hash, err := pkgPrediction.PerceptualHash(mockImage)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	req.Header.Add("Content-Type", writer.FormDataContentType())
+	tests := []struct {
+		name               string
+		records            []*predictionstore.Record
+		expectedPrediction *pkgPrediction.Result
+	}{
+		{"with stored prediction", []*predictionstore.Record{{ImageID: duplicateID, Result: &mockPrediction}}, &mockPrediction},
+		{"without stored prediction", nil, nil},
+	}
 
-	rr := httptest.NewRecorder()
-	router := mux.NewRouter()
-	router.HandleFunc(postImageURL, postImageHandler.ServeHTTP)
-	router.ServeHTTP(rr, req)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storageServiceMock, idGenerator := newMocks()
+			deduplication := dedup.New(objectstest.NewStorage(), 0)
+			assert.NoError(t, deduplication.Add(duplicateID, hash))
+			store := predictionstore.NewMemory()
+			for _, record := range tt.records {
+				assert.NoError(t, store.Put(record))
+			}
+
+			deps := dep.NewAppDependencies().
+				WithStorageService(storageServiceMock).
+				WithIDGenerator(idGenerator).
+				WithDeduplication(deduplication).
+				WithPredictionStore(store)
+			resp, err := newApp(deps).Test(newUploadRequest(t, fileFormKey, mockImage))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			imgParams := ImgParams{}
+			_ = json.NewDecoder(resp.Body).Decode(&imgParams)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, ImgParams{
+				ID:           duplicateID,
+				OriginalName: staticPictureName,
+				Deduplicated: true,
+				Prediction:   tt.expectedPrediction,
+			}, imgParams)
+			storageServiceMock.AssertNotCalled(t, "WriteToBucketObject", mock.Anything, mock.Anything)
+		})
+	}
+}
 
-	storageServiceMock.AssertNumberOfCalls(t, "WriteToBucketObject", 1)
+func Test_Handle_deduplication_hash_error(t *testing.T) {
+	storageServiceMock, idGenerator := newMocks()
+
+	// the header of the image is intact, so only decoding the pixels fails
+	truncatedImage := mockImage[:len(mockImage)-20]
+	_, err := pkgPrediction.DetectImageFormat(truncatedImage)
+	assert.NoError(t, err)
+
+	deps := dep.NewAppDependencies().
+		WithStorageService(storageServiceMock).
+		WithIDGenerator(idGenerator).
+		WithDeduplication(dedup.New(objectstest.NewStorage(), 0))
+	resp, err := newApp(deps).Test(newUploadRequest(t, fileFormKey, truncatedImage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storageServiceMock.AssertNotCalled(t, "WriteToBucketObject", mock.Anything, mock.Anything)
 
-	assert.Equal(t, http.StatusInternalServerError, rr.Code)
-	assert.Equal(t, http.StatusText(http.StatusInternalServerError)+"\n", rr.Body.String())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, errorTextInvalidImage, readBody(t, resp))
 }
@@ -0,0 +1,110 @@
+package predictionfeedback
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	"github.com/pdstuber/isit-a-cat/internal/service/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	errorTextInvalidBody = "request body must be json with the correct class under the key 'class'"
+	errorTextNoFeedback  = "feedback is not collected"
+)
+
+type handlerDependencies interface {
+	dep.CanForwardDependencies
+}
+
+// Handler accepts feedback on predictions and reports how often they were
+// right
+type Handler struct {
+	deps handlerDependencies
+}
+
+// An ErrorResponse is sent back to the client in case an error occurred
+type ErrorResponse = handlers.ErrorResponse
+
+// Request is the body of a feedback request
+type Request struct {
+	// Class the image really shows, one of the labels of the model
+	Class string `json:"class"`
+}
+
+// Stats is the response of the stats endpoint
+type Stats struct {
+	Versions []feedback.VersionStats `json:"versions"`
+}
+
+// NewHandler creates an instance of the feedback handler
+func NewHandler(deps handlerDependencies) *Handler {
+	return &Handler{deps}
+}
+
+// Handle stores the correct class of an uploaded image next to the image and
+// responds with the stored feedback
+func (h *Handler) Handle(ctx *fiber.Ctx) error {
+	if h.deps.Forward().Feedback() == nil {
+		return h.feedbackDisabled(ctx)
+	}
+
+	id := ctx.Params("id")
+	if id == "" {
+		log.Println(handlers.ErrorTextMissingID)
+		return ctx.Status(fiber.StatusBadRequest).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   handlers.ErrorTextMissingID,
+		})
+	}
+
+	request := Request{}
+	if err := ctx.BodyParser(&request); err != nil || request.Class == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   errorTextInvalidBody,
+		})
+	}
+
+	imageFeedback, err := prediction.RecordFeedback(ctx.UserContext(), h.deps.Forward(), id, request.Class, feedback.SourceAPI)
+	if err != nil {
+		log.Printf("Error recording feedback: %v\n", err)
+		status, errorResponse := errorResponseFor(err)
+		return ctx.Status(status).JSON(errorResponse)
+	}
+
+	return ctx.Status(fiber.StatusCreated).JSON(imageFeedback)
+}
+
+// HandleStats responds with the feedback stats of every model version
+func (h *Handler) HandleStats(ctx *fiber.Ctx) error {
+	feedbackService := h.deps.Forward().Feedback()
+	if feedbackService == nil {
+		return h.feedbackDisabled(ctx)
+	}
+
+	return ctx.JSON(&Stats{Versions: feedbackService.Stats()})
+}
+
+func (h *Handler) feedbackDisabled(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusNotFound).JSON(&ErrorResponse{
+		ErrorType: handlers.ErrorTypeClientError,
+		Message:   errorTextNoFeedback,
+	})
+}
+
+// errorResponseFor maps an error of the prediction service to the http status
+// and the response sent to the client
+func errorResponseFor(err error) (int, *ErrorResponse) {
+	if errors.Is(err, feedback.ErrUnknownClass) {
+		return fiber.StatusBadRequest, &ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   err.Error(),
+		}
+	}
+
+	return handlers.ErrorResponseFor(err)
+}
@@ -0,0 +1,182 @@
+package predictionfeedback
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/predictionfeedback/mocks"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	feedbackURL   = "/predictions/12345/feedback"
+	statsURL      = "/feedback/stats"
+	testID        = "12345"
+	mockErrorText = "everything went to hell"
+)
+
+var (
+	mockImage      = []byte{1, 2, 3, 4, 5}
+	mockPrediction = pkgPrediction.Result{
+		Class:        "cats",
+		Probability:  0.99,
+		ModelVersion: "v1",
+	}
+	labels  = []pkgPrediction.Label{{Index: 0, ClassName: "cats"}, {Index: 1, ClassName: "non_cats"}}
+	errMock = errors.New(mockErrorText)
+)
+
+func newTestApp(imagePredictor *mocks.ImagePredictor, storageService *mocks.StorageService) *fiber.App {
+	return newApp(dep.NewAppDependencies().
+		WithStorageService(storageService).
+		WithImagePredictor(imagePredictor).
+		WithFeedback(feedback.New(storageService, labels)))
+}
+
+func newApp(deps dep.AppDependencies) *fiber.App {
+	handler := NewHandler(deps.Forward())
+
+	app := fiber.New()
+	app.Post("/predictions/:id/feedback", handler.Handle)
+	app.Get(statsURL, handler.HandleStats)
+	return app
+}
+
+func postFeedback(t *testing.T, app *fiber.App, body string) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, feedbackURL, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func Test_Handle_good_case(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	storageServiceMock.On("WriteToBucketObject", testID+feedback.FeedbackSuffix, mock.Anything).Return(nil)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&mockPrediction, nil)
+	app := newTestApp(imagePredictorMock, storageServiceMock)
+
+	resp := postFeedback(t, app, `{"class":"non_cats"}`)
+
+	imageFeedback := feedback.Feedback{}
+	_ = json.NewDecoder(resp.Body).Decode(&imageFeedback)
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, testID, imageFeedback.ImageID)
+	assert.Equal(t, "cats", imageFeedback.PredictedClass)
+	assert.Equal(t, "non_cats", imageFeedback.CorrectClass)
+	assert.Equal(t, "v1", imageFeedback.ModelVersion)
+	assert.Equal(t, feedback.SourceAPI, imageFeedback.Source)
+	storageServiceMock.AssertExpectations(t)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, statsURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := Stats{}
+	_ = json.NewDecoder(resp.Body).Decode(&stats)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	if assert.Len(t, stats.Versions, 1) {
+		assert.Equal(t, "v1", stats.Versions[0].ModelVersion)
+		assert.Equal(t, 1, stats.Versions[0].Wrong)
+		assert.Equal(t, map[string]map[string]int{"cats": {"non_cats": 1}}, stats.Versions[0].Corrections)
+	}
+}
+
+func Test_Handle_invalid_body(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	for _, body := range []string{`{}`, `{"class":`, `{"class":""}`} {
+		resp := postFeedback(t, newTestApp(imagePredictorMock, storageServiceMock), body)
+
+		errorResponse := ErrorResponse{}
+		_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, errorTextInvalidBody, errorResponse.Message)
+	}
+	imagePredictorMock.AssertNotCalled(t, "PredictImage", mock.Anything, mock.Anything)
+}
+
+func Test_Handle_unknown_class(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	resp := postFeedback(t, newTestApp(imagePredictorMock, storageServiceMock), `{"class":"dogs"}`)
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, handlers.ErrorTypeClientError, errorResponse.ErrorType)
+	assert.Equal(t, `"dogs" is not one of cats, non_cats: unknown class`, errorResponse.Message)
+	imagePredictorMock.AssertNotCalled(t, "PredictImage", mock.Anything, mock.Anything)
+}
+
+func Test_Handle_storage_error(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+	storageServiceMock.On("WriteToBucketObject", testID+feedback.FeedbackSuffix, mock.Anything).Return(errMock)
+	imagePredictorMock.On("PredictImage", mock.Anything, mockImage).Return(&mockPrediction, nil)
+
+	resp := postFeedback(t, newTestApp(imagePredictorMock, storageServiceMock), `{"class":"cats"}`)
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, handlers.ErrorTypeServerError, errorResponse.ErrorType)
+}
+
+func Test_Handle_image_not_found(t *testing.T) {
+	imagePredictorMock := new(mocks.ImagePredictor)
+	storageServiceMock := new(mocks.StorageService)
+
+	storageServiceMock.On("ReadFromBucketObject", testID).Return(nil, errMock)
+
+	resp := postFeedback(t, newTestApp(imagePredictorMock, storageServiceMock), `{"class":"cats"}`)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	storageServiceMock.AssertNotCalled(t, "WriteToBucketObject", mock.Anything, mock.Anything)
+}
+
+func Test_Handle_no_feedback(t *testing.T) {
+	app := newApp(dep.NewAppDependencies())
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, feedbackURL, strings.NewReader(`{"class":"cats"}`)),
+		httptest.NewRequest(http.MethodGet, statsURL, nil),
+	} {
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		errorResponse := ErrorResponse{}
+		_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, errorTextNoFeedback, errorResponse.Message)
+	}
+}
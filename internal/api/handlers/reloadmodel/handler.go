@@ -0,0 +1,52 @@
+package reloadmodel
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+)
+
+const (
+	errorTextCouldNotReload   = "could not reload the model, the previous model is still in use"
+	errorTextReloadNotEnabled = "the image predictor does not support reloading the model"
+)
+
+type handlerDependencies interface {
+	dep.CanForwardDependencies
+}
+
+// Handler reloads the model of the image predictor
+type Handler struct {
+	deps handlerDependencies
+}
+
+// An ErrorResponse is sent back to the client in case an error occurred
+type ErrorResponse = handlers.ErrorResponse
+
+// NewHandler creates an instance of the reload handler
+func NewHandler(deps handlerDependencies) *Handler {
+	return &Handler{deps}
+}
+
+// Handle reloads the model and responds once the new model is in use
+func (h *Handler) Handle(ctx *fiber.Ctx) error {
+	modelReloader := h.deps.Forward().ModelReloader()
+	if modelReloader == nil {
+		return ctx.Status(fiber.StatusNotImplemented).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeServerError,
+			Message:   errorTextReloadNotEnabled,
+		})
+	}
+
+	if err := modelReloader.Reload(ctx.UserContext()); err != nil {
+		log.Printf("could not reload model: %v\n", err)
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeServerError,
+			Message:   errorTextCouldNotReload,
+		})
+	}
+
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
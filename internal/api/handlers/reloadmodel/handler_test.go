@@ -0,0 +1,66 @@
+package reloadmodel
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/reloadmodel/mocks"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const reloadURL = "/admin/model/reload"
+
+func newTestApp(deps dep.AppDependencies) *fiber.App {
+	app := fiber.New()
+	app.Post(reloadURL, NewHandler(deps.Forward()).Handle)
+	return app
+}
+
+func Test_Handle_good_case(t *testing.T) {
+	modelReloaderMock := new(mocks.ModelReloader)
+	modelReloaderMock.On("Reload", mock.Anything).Return(nil)
+
+	app := newTestApp(dep.NewAppDependencies().WithModelReloader(modelReloaderMock))
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, reloadURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modelReloaderMock.AssertNumberOfCalls(t, "Reload", 1)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func Test_Handle_reload_failed(t *testing.T) {
+	modelReloaderMock := new(mocks.ModelReloader)
+	modelReloaderMock.On("Reload", mock.Anything).Return(errors.New("warm-up failed"))
+
+	app := newTestApp(dep.NewAppDependencies().WithModelReloader(modelReloaderMock))
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, reloadURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, handlers.ErrorTypeServerError, errorResponse.ErrorType)
+	assert.Equal(t, errorTextCouldNotReload, errorResponse.Message)
+}
+
+func Test_Handle_reload_not_enabled(t *testing.T) {
+	app := newTestApp(dep.NewAppDependencies())
+	resp, err := app.Test(httptest.NewRequest(http.MethodPost, reloadURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
@@ -0,0 +1,100 @@
+package similarimages
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/similarity"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultK = 10
+	maxK     = 100
+
+	errorTextInvalidK     = "query parameter 'k' must be a number between 1 and 100"
+	errorTextNoSimilarity = "no embedding tensor is configured"
+	errorTextUnsupported  = "the model cannot extract embeddings"
+)
+
+type handlerDependencies interface {
+	dep.CanForwardDependencies
+}
+
+// Handler finds uploaded images similar to a given one
+type Handler struct {
+	deps handlerDependencies
+}
+
+// An ErrorResponse is sent back to the client in case an error occurred
+type ErrorResponse = handlers.ErrorResponse
+
+// SimilarImages is the response of the handler
+type SimilarImages struct {
+	ID      string             `json:"id"`
+	Similar []similarity.Match `json:"similar"`
+}
+
+// NewHandler creates an instance of the similar images handler
+func NewHandler(deps handlerDependencies) *Handler {
+	return &Handler{deps}
+}
+
+// Handle responds with the k previously uploaded images whose embeddings are
+// closest to the one of the image, the most similar first
+func (h *Handler) Handle(ctx *fiber.Ctx) error {
+	similarityService := h.deps.Forward().Similarity()
+	if similarityService == nil {
+		return ctx.Status(fiber.StatusNotFound).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   errorTextNoSimilarity,
+		})
+	}
+
+	id := ctx.Params("id")
+	if id == "" {
+		log.Println(handlers.ErrorTextMissingID)
+		return ctx.Status(fiber.StatusBadRequest).JSON(&ErrorResponse{
+			ErrorType: handlers.ErrorTypeClientError,
+			Message:   handlers.ErrorTextMissingID,
+		})
+	}
+
+	k := defaultK
+	if value := ctx.Query("k"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxK {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&ErrorResponse{
+				ErrorType: handlers.ErrorTypeClientError,
+				Message:   errorTextInvalidK,
+			})
+		}
+		k = parsed
+	}
+
+	matches, err := similarityService.Similar(ctx.UserContext(), id, k)
+	if err != nil {
+		log.Printf("Error finding similar images: %v\n", err)
+		status, errorResponse := errorResponseFor(err)
+		return ctx.Status(status).JSON(errorResponse)
+	}
+
+	return ctx.JSON(&SimilarImages{ID: id, Similar: matches})
+}
+
+// errorResponseFor maps an error of the similarity service to the http status
+// and the response sent to the client
+func errorResponseFor(err error) (int, *ErrorResponse) {
+	if errors.Is(err, pkgPrediction.ErrEmbeddingUnsupported) {
+		return fiber.StatusNotImplemented, &ErrorResponse{
+			ErrorType: handlers.ErrorTypeServerError,
+			Message:   errorTextUnsupported,
+		}
+	}
+
+	return handlers.ErrorResponseFor(err)
+}
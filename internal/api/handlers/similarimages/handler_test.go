@@ -0,0 +1,182 @@
+package similarimages
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/similarimages/mocks"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/similarity"
+	pkgPrediction "github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const (
+	similarURL    = "/images/12345/similar"
+	testID        = "12345"
+	mockErrorText = "everything went to hell"
+)
+
+var (
+	mockImage      = []byte{1, 2, 3, 4, 5}
+	mockCatImage   = []byte{6, 7, 8}
+	mockDogImage   = []byte{9, 10, 11}
+	errMock        = errors.New(mockErrorText)
+	mockEmbeddings = map[string][]float32{
+		string(mockImage):    {1, 0},
+		string(mockCatImage): {0.9, 0.1},
+		string(mockDogImage): {0, 1},
+	}
+)
+
+// newTestApp indexes a cat and a dog before serving the handler
+func newTestApp(t *testing.T, embedder *mocks.Embedder, storage *mocks.StorageService) *fiber.App {
+	similarityService := similarity.New(embedder, storage)
+	assert.NoError(t, similarityService.IndexImage(context.Background(), "cat", mockCatImage))
+	assert.NoError(t, similarityService.IndexImage(context.Background(), "dog", mockDogImage))
+
+	return newApp(dep.NewAppDependencies().WithSimilarity(similarityService))
+}
+
+func newApp(deps dep.AppDependencies) *fiber.App {
+	app := fiber.New()
+	app.Get("/images/:id/similar", NewHandler(deps.Forward()).Handle)
+	return app
+}
+
+func newMocks() (*mocks.Embedder, *mocks.StorageService) {
+	embedderMock := new(mocks.Embedder)
+	storageMock := new(mocks.StorageService)
+	embedderMock.On("EmbedImage", mock.Anything, mock.Anything).Return(func(_ context.Context, imageBytes []byte) (*pkgPrediction.Embedding, error) {
+		return &pkgPrediction.Embedding{Vector: mockEmbeddings[string(imageBytes)], ModelVersion: "v1"}, nil
+	})
+	storageMock.On("WriteToBucketObject", mock.Anything, mock.Anything).Return(nil)
+	return embedderMock, storageMock
+}
+
+func Test_Handle_good_case(t *testing.T) {
+	embedderMock, storageMock := newMocks()
+	storageMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+
+	resp, err := newTestApp(t, embedderMock, storageMock).Test(httptest.NewRequest(http.MethodGet, similarURL+"?k=1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	similarImages := SimilarImages{}
+	_ = json.NewDecoder(resp.Body).Decode(&similarImages)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, testID, similarImages.ID)
+	if assert.Len(t, similarImages.Similar, 1) {
+		assert.Equal(t, "cat", similarImages.Similar[0].ID)
+		assert.InDelta(t, 0.9939, similarImages.Similar[0].Similarity, 1e-4)
+	}
+	storageMock.AssertCalled(t, "WriteToBucketObject", testID+similarity.EmbeddingSuffix, mock.Anything)
+}
+
+func Test_Handle_default_k(t *testing.T) {
+	embedderMock, storageMock := newMocks()
+	storageMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+
+	resp, err := newTestApp(t, embedderMock, storageMock).Test(httptest.NewRequest(http.MethodGet, similarURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	similarImages := SimilarImages{}
+	_ = json.NewDecoder(resp.Body).Decode(&similarImages)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, similarImages.Similar, 2)
+}
+
+func Test_Handle_invalid_k(t *testing.T) {
+	for _, k := range []string{"0", "101", "many"} {
+		t.Run(k, func(t *testing.T) {
+			embedderMock, storageMock := newMocks()
+
+			resp, err := newTestApp(t, embedderMock, storageMock).Test(httptest.NewRequest(http.MethodGet, similarURL+"?k="+k, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errorResponse := ErrorResponse{}
+			_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, errorTextInvalidK, errorResponse.Message)
+		})
+	}
+}
+
+func Test_Handle_disabled(t *testing.T) {
+	resp, err := newApp(dep.NewAppDependencies()).Test(httptest.NewRequest(http.MethodGet, similarURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errorResponse := ErrorResponse{}
+	_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, errorTextNoSimilarity, errorResponse.Message)
+}
+
+func Test_Handle_errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		embedErr       error
+		expectedStatus int
+		expectedText   string
+	}{
+		{"unsupported by the current model", pkgPrediction.ErrEmbeddingUnsupported, http.StatusNotImplemented, errorTextUnsupported},
+		{"unsupported image", pkgPrediction.ErrUnsupportedImageFormat, http.StatusUnsupportedMediaType, handlers.ErrorTextUnsupportedImageFormat},
+		{"invalid image", pkgPrediction.ErrInvalidImage, http.StatusBadRequest, handlers.ErrorTextInvalidImage},
+		{"busy", pkgPrediction.ErrQueueFull, http.StatusServiceUnavailable, handlers.ErrorTextServiceBusy},
+		{"model failed", errMock, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedderMock := new(mocks.Embedder)
+			storageMock := new(mocks.StorageService)
+			storageMock.On("ReadFromBucketObject", testID).Return(mockImage, nil)
+			embedderMock.On("EmbedImage", mock.Anything, mockImage).Return(nil, tt.embedErr)
+
+			deps := dep.NewAppDependencies().WithSimilarity(similarity.New(embedderMock, storageMock))
+			resp, err := newApp(deps).Test(httptest.NewRequest(http.MethodGet, similarURL, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errorResponse := ErrorResponse{}
+			_ = json.NewDecoder(resp.Body).Decode(&errorResponse)
+
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedText, errorResponse.Message)
+		})
+	}
+}
+
+func Test_Handle_storage_error(t *testing.T) {
+	embedderMock := new(mocks.Embedder)
+	storageMock := new(mocks.StorageService)
+	storageMock.On("ReadFromBucketObject", testID).Return(nil, errMock)
+
+	deps := dep.NewAppDependencies().WithSimilarity(similarity.New(embedderMock, storageMock))
+	resp, err := newApp(deps).Test(httptest.NewRequest(http.MethodGet, similarURL, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	embedderMock.AssertNotCalled(t, "EmbedImage", mock.Anything, mock.Anything)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"log"
 	"time"
@@ -10,11 +11,18 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/healthcheck"
+	"github.com/gofiber/fiber/v2/middleware/keyauth"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/experimentstats"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/explanation"
 	"github.com/pdstuber/isit-a-cat/internal/api/handlers/getprediction"
 	"github.com/pdstuber/isit-a-cat/internal/api/handlers/imageretrieval"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/listpredictions"
 	"github.com/pdstuber/isit-a-cat/internal/api/handlers/postimage"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/predictionfeedback"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/reloadmodel"
+	"github.com/pdstuber/isit-a-cat/internal/api/handlers/similarimages"
 	"github.com/pdstuber/isit-a-cat/internal/dep"
 
 	"github.com/goccy/go-json"
@@ -32,17 +40,45 @@ type Router struct {
 	deps       routerDependencies
 }
 
-func NewRouter(deps routerDependencies, listenPort string) *Router {
+// NewRouter creates the router. The admin endpoints are only served when an
+// admin token is given, which clients have to send as bearer token.
+func NewRouter(deps routerDependencies, listenPort, adminToken string) *Router {
 	postImageHandler := postimage.NewHandler(deps.Forward())
 	getPredictionHandler := getprediction.NewHandler(deps.Forward())
 	getImageHandler := imageretrieval.NewHandler(deps.Forward())
+	experimentStatsHandler := experimentstats.NewHandler(deps.Forward())
+	explanationHandler := explanation.NewHandler(deps.Forward())
+	similarImagesHandler := similarimages.NewHandler(deps.Forward())
+	listPredictionsHandler := listpredictions.NewHandler(deps.Forward())
+	feedbackHandler := predictionfeedback.NewHandler(deps.Forward())
 
 	app := createFiberApp()
 
 	// TODO move bot to webhook and include here
 	app.Post("/images", postImageHandler.Handle)
+	app.Get("/predictions", listPredictionsHandler.Handle)
 	app.Get("/predictions/:id", getPredictionHandler.Handle)
+	app.Get("/predictions/:id/explanation", explanationHandler.Handle)
+	app.Post("/predictions/:id/feedback", feedbackHandler.Handle)
+	app.Get("/feedback/stats", feedbackHandler.HandleStats)
+	app.Post("/predict", getPredictionHandler.HandleUpload)
 	app.Get("/images/:id", getImageHandler.Handle)
+	app.Get("/images/:id/similar", similarImagesHandler.Handle)
+	app.Get("/experiment", experimentStatsHandler.Handle)
+
+	if adminToken != "" {
+		reloadModelHandler := reloadmodel.NewHandler(deps.Forward())
+
+		admin := app.Group("/admin", keyauth.New(keyauth.Config{
+			Validator: func(_ *fiber.Ctx, key string) (bool, error) {
+				if subtle.ConstantTimeCompare([]byte(key), []byte(adminToken)) != 1 {
+					return false, keyauth.ErrMissingOrMalformedAPIKey
+				}
+				return true, nil
+			},
+		}))
+		admin.Post("/model/reload", reloadModelHandler.Handle)
+	}
 
 	return &Router{
 		fiberApp:   app,
@@ -62,12 +98,12 @@ func createFiberApp() *fiber.App {
 	app.Use(cors.New())
 	app.Use(recover.New())
 
+	// plain http requests on /predictions are answered with json
 	app.Use("/predictions", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			c.Locals("allowed", true)
-			return c.Next()
 		}
-		return fiber.ErrUpgradeRequired
+		return c.Next()
 	})
 
 	return app
@@ -2,12 +2,12 @@ package bot
 
 import (
 	"context"
-	_ "image/gif"
-	_ "image/jpeg"
-	_ "image/png"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,11 +15,23 @@ import (
 	"github.com/pdstuber/isit-a-cat/pkg/prediction"
 )
 
-const telegramBotErrorMessage = "there was a problem in processing your request at this time"
+const (
+	telegramBotErrorMessage            = "there was a problem in processing your request at this time"
+	telegramBotUnsupportedImageMessage = "sorry, I can only look at JPEG, PNG, GIF, WebP and BMP images"
+	telegramBotBusyMessage             = "I am looking at too many pictures right now, please try again later"
+	telegramBotExplainUsageMessage     = "send /explain as caption of a picture, or reply with it to one, to see which parts of it my answer depends on"
+	telegramBotCannotExplainMessage    = "sorry, I cannot explain my answers at the moment"
+	telegramBotExplanationCaption      = "The highlighted parts of the picture mattered most for my answer."
+	explainCommand                     = "explain"
+	explanationFileName                = "explanation.png"
+	replyTopK                          = 3
+	// index of the photo size sent to the model, telegram orders them ascending
+	preferredPhotoSize = 2
+)
 
 // A ImagePredictor predicts the class of an image
 type ImagePredictor interface {
-	PredictImage(imageBytes []byte) (*prediction.Result, error)
+	PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error)
 	Stop() error
 }
 
@@ -31,6 +43,8 @@ type Bot struct {
 	shutdownChannel chan interface{}
 	imagePredictor  ImagePredictor
 	httpClient      *http.Client
+	// feedbackDeps are nil when no feedback is asked for
+	feedbackDeps feedbackDependencies
 }
 
 func New(botAPI *tgbotapi.BotAPI, imagePredictor ImagePredictor) *Bot {
@@ -94,15 +108,29 @@ func (b *Bot) StartWorkers(ctx context.Context, ch chan *tgbotapi.Update) {
 
 		go func() {
 			for update := range ch {
+				if update.CallbackQuery != nil {
+					b.handleCallback(ctx, update.CallbackQuery)
+					continue
+				}
 				if update.Message == nil {
 					continue
 				}
 
-				if len(update.Message.Photo) == 0 {
-					continue
+				// /explain is either the caption of the image or a reply to it
+				explain := isExplainCommand(update.Message)
+				imageMessage := update.Message
+				if explain && update.Message.ReplyToMessage != nil {
+					imageMessage = update.Message.ReplyToMessage
 				}
 
-				msg := b.handlePhoto(ctx, update.Message)
+				var msg tgbotapi.Chattable
+				if fileID, uniqueID, ok := imageFileID(imageMessage); ok {
+					msg = b.handlePhoto(ctx, update.Message, fileID, uniqueID, explain)
+				} else if explain {
+					msg = tgbotapi.NewMessage(update.Message.Chat.ID, telegramBotExplainUsageMessage)
+				} else {
+					continue
+				}
 
 				if _, err := b.botAPI.Send(msg); err != nil {
 					log.Println(err)
@@ -113,15 +141,43 @@ func (b *Bot) StartWorkers(ctx context.Context, ch chan *tgbotapi.Update) {
 	}
 }
 
+// imageFileID returns the id and the unique id of the image attached to the
+// message. Images sent as file keep their original format and arrive as
+// document.
+func imageFileID(message *tgbotapi.Message) (string, string, bool) {
+	if len(message.Photo) > 0 {
+		photo := message.Photo[min(preferredPhotoSize, len(message.Photo)-1)]
+		return photo.FileID, photo.FileUniqueID, true
+	}
+
+	if message.Document != nil && strings.HasPrefix(message.Document.MimeType, "image/") {
+		return message.Document.FileID, message.Document.FileUniqueID, true
+	}
+
+	return "", "", false
+}
+
+// isExplainCommand reports whether the text or the caption of the message is
+// the /explain command
+func isExplainCommand(message *tgbotapi.Message) bool {
+	if message.IsCommand() {
+		return message.Command() == explainCommand
+	}
+
+	command, _, _ := strings.Cut(strings.TrimSpace(message.Caption), " ")
+	command, _, _ = strings.Cut(command, "@")
+	return command == "/"+explainCommand
+}
+
 // TODO improve error messages
-func (b *Bot) handlePhoto(ctx context.Context, message *tgbotapi.Message) tgbotapi.MessageConfig {
+func (b *Bot) handlePhoto(ctx context.Context, message *tgbotapi.Message, fileID, uniqueID string, explain bool) tgbotapi.Chattable {
 	fileConfig := tgbotapi.FileConfig{
-		FileID: message.Photo[2].FileID,
+		FileID: fileID,
 	}
 
 	file, err := b.botAPI.GetFile(fileConfig)
 	if err != nil {
-		log.Printf("could not retrieve information about your uploaded photo from the server: %w\n", err)
+		log.Printf("could not retrieve information about your uploaded photo from the server: %v\n", err)
 		return tgbotapi.NewMessage(message.Chat.ID, telegramBotErrorMessage)
 	}
 
@@ -129,13 +185,13 @@ func (b *Bot) handlePhoto(ctx context.Context, message *tgbotapi.Message) tgbota
 
 	request, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
 	if err != nil {
-		log.Printf("could not create http request: %w\n", err)
+		log.Printf("could not create http request: %v\n", err)
 		return tgbotapi.NewMessage(message.Chat.ID, telegramBotErrorMessage)
 	}
 
 	response, err := b.httpClient.Do(request)
 	if err != nil {
-		log.Printf("could not perform http request: %w\n", err)
+		log.Printf("could not perform http request: %v\n", err)
 		return tgbotapi.NewMessage(message.Chat.ID, telegramBotErrorMessage)
 	}
 
@@ -143,15 +199,70 @@ func (b *Bot) handlePhoto(ctx context.Context, message *tgbotapi.Message) tgbota
 
 	photoBytes, err := io.ReadAll(response.Body)
 	if err != nil {
-		log.Printf("could read http response body: %w\n", err)
+		log.Printf("could read http response body: %v\n", err)
 		return tgbotapi.NewMessage(message.Chat.ID, telegramBotErrorMessage)
 	}
 
-	result, err := b.imagePredictor.PredictImage(photoBytes)
+	if explain {
+		return b.explainPhoto(ctx, message.Chat.ID, photoBytes)
+	}
+
+	start := time.Now()
+	result, err := b.imagePredictor.PredictImage(ctx, photoBytes)
 	if err != nil {
-		log.Printf("could not retrieve information about your uploaded photo from the server: %w\n", err)
-		return tgbotapi.NewMessage(message.Chat.ID, telegramBotErrorMessage)
+		log.Printf("could not predict uploaded photo: %v\n", err)
+		return errorReply(message.Chat.ID, err)
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, formatReply(result))
+	if b.feedbackDeps != nil {
+		if keyboard, ok := b.keepForFeedback(uniqueID, photoBytes, result, time.Since(start)); ok {
+			reply.ReplyMarkup = keyboard
+		}
+	}
+	return reply
+}
+
+// explainPhoto replies with the explanation heatmap drawn over the photo
+func (b *Bot) explainPhoto(ctx context.Context, chatID int64, photoBytes []byte) tgbotapi.Chattable {
+	explainer, ok := b.imagePredictor.(prediction.Explainer)
+	if !ok {
+		return tgbotapi.NewMessage(chatID, telegramBotCannotExplainMessage)
+	}
+
+	explanation, err := explainer.ExplainImage(ctx, photoBytes)
+	if errors.Is(err, prediction.ErrExplanationUnsupported) {
+		return tgbotapi.NewMessage(chatID, telegramBotCannotExplainMessage)
+	} else if err != nil {
+		log.Printf("could not explain uploaded photo: %v\n", err)
+		return errorReply(chatID, err)
+	}
+
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: explanationFileName, Bytes: explanation.Overlay})
+	photo.Caption = formatReply(explanation.Result) + "\n\n" + telegramBotExplanationCaption
+	return photo
+}
+
+// errorReply tells the user why the photo could not be predicted
+func errorReply(chatID int64, err error) tgbotapi.MessageConfig {
+	if prediction.IsImageError(err) {
+		return tgbotapi.NewMessage(chatID, telegramBotUnsupportedImageMessage)
+	} else if errors.Is(err, prediction.ErrQueueFull) || errors.Is(err, context.DeadlineExceeded) {
+		return tgbotapi.NewMessage(chatID, telegramBotBusyMessage)
+	}
+
+	return tgbotapi.NewMessage(chatID, telegramBotErrorMessage)
+}
+
+// formatReply renders the verdict followed by the most likely classes
+func formatReply(result *prediction.Result) string {
+	var sb strings.Builder
+	sb.WriteString(result.String())
+	sb.WriteString("\n")
+
+	for _, score := range result.TopK(replyTopK) {
+		fmt.Fprintf(&sb, "\n%s: %.1f%%", score.Class, score.Probability*100)
 	}
 
-	return tgbotapi.NewMessage(message.Chat.ID, result.String())
+	return sb.String()
 }
@@ -1,22 +1,21 @@
 package bot
 
 import (
-	"fmt"
 	"os"
 	"strconv"
 
-	"github.com/gocarina/gocsv"
-	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
 	"github.com/pkg/errors"
 )
 
 type Config struct {
-	TelegramBotToken      string
-	Labels                []prediction.Label
-	Model                 []byte
-	TargetImageDimensions int
-	TFInputOperationName  string
-	TFOutputOperationName string
+	TelegramBotToken string
+	model.Config
+	// Feedback asks for feedback on every answer, the photos are kept in
+	// the object storage
+	Feedback      bool
+	ObjectStorage *storage.Config
 }
 
 func getEnv(key, fallback string) string {
@@ -32,36 +31,28 @@ func ConfigFromEnv() (*Config, error) {
 		return nil, errors.New("telegram bot token is mandatory")
 	}
 
-	var labels []prediction.Label
-
-	modelPath := getEnv("MODEL_PATH", "/model")
-	model, err := os.ReadFile(fmt.Sprintf("%s/model.pb", modelPath))
-	if err != nil {
-		return nil, errors.Wrap(err, "could not read model")
-	}
-	labelBytes, err := os.ReadFile(fmt.Sprintf("%s/labels.csv", modelPath))
+	modelConfig, err := model.ConfigFromEnv()
 	if err != nil {
-		return nil, errors.Wrap(err, "could not read labels")
+		return nil, err
 	}
 
-	if err := gocsv.UnmarshalBytes(labelBytes, &labels); err != nil {
-		return nil, errors.Wrap(err, "could not unmarshal labels csv")
-	}
-
-	targetImageDimensions, err := strconv.Atoi(getEnv("TARGET_IMAGE_DIMENSIONS", "256"))
+	feedback, err := strconv.ParseBool(getEnv("BOT_FEEDBACK", "false"))
 	if err != nil {
-		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+		return nil, errors.Wrap(err, "could not parse BOT_FEEDBACK as boolean")
 	}
 
-	inputOperationName := getEnv("TF_INPUT_OPERATION_NAME", "input_1")
-	outputOperationName := getEnv("TF_OUTPUT_OPERATION_NAME", "dense_3/Softmax")
+	var objectStorageConfig *storage.Config
+	if feedback {
+		objectStorageConfig, err = storage.ConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return &Config{
-		TelegramBotToken:      telegramBotToken,
-		Labels:                labels,
-		Model:                 model,
-		TargetImageDimensions: targetImageDimensions,
-		TFInputOperationName:  inputOperationName,
-		TFOutputOperationName: outputOperationName,
+		TelegramBotToken: telegramBotToken,
+		Config:           *modelConfig,
+		Feedback:         feedback,
+		ObjectStorage:    objectStorageConfig,
 	}, nil
 }
@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	servicePrediction "github.com/pdstuber/isit-a-cat/internal/service/prediction"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
+
+const (
+	telegramBotFeedbackThanksMessage = "Thanks for your feedback!"
+	telegramBotFeedbackErrorMessage  = "sorry, I could not save your feedback"
+	telegramBotCorrectButton         = "✅ Correct"
+	telegramBotWrongButton           = "❌ Wrong"
+	// photos are stored under the unique id telegram gives every file, so a
+	// photo sent twice is stored once
+	telegramImageIDPrefix = "telegram-"
+	// callback data of the feedback buttons is "fb:<image id>:<class index>",
+	// the class index is a question mark for the button asking for the class
+	callbackDataPrefix    = "fb"
+	callbackDataSeparator = ":"
+	callbackDataAskClass  = "?"
+)
+
+type feedbackDependencies interface {
+	dep.CanForwardDependencies
+}
+
+// WithFeedback keeps the photos and predictions in the object storage and
+// asks for feedback on every answer. The dependencies need a storage service,
+// a prediction store and the feedback service.
+func (b *Bot) WithFeedback(deps feedbackDependencies) *Bot {
+	b.feedbackDeps = deps
+	return b
+}
+
+// keepForFeedback stores the photo and its prediction and returns the buttons
+// to give feedback with. It returns false when the photo could not be stored.
+func (b *Bot) keepForFeedback(uniqueID string, photoBytes []byte, result *prediction.Result, latency time.Duration) (tgbotapi.InlineKeyboardMarkup, bool) {
+	deps := b.feedbackDeps.Forward()
+	id := telegramImageIDPrefix + uniqueID
+
+	if err := deps.StorageWriter().WriteToBucketObject(id, photoBytes); err != nil {
+		log.Printf("could not store uploaded photo %s: %v\n", id, err)
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	if err := deps.PredictionStore().Put(&predictionstore.Record{
+		ImageID:   id,
+		Result:    result,
+		Latency:   latency,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		log.Printf("could not store prediction of uploaded photo %s: %v\n", id, err)
+	}
+
+	classes := deps.Feedback().Classes()
+	predicted := classIndex(classes, result.Class)
+	if predicted < 0 {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+
+	// with two classes the wrong answer tells the correct one
+	wrong := callbackDataAskClass
+	if len(classes) == 2 {
+		wrong = strconv.Itoa(1 - predicted)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(telegramBotCorrectButton, callbackData(id, strconv.Itoa(predicted))),
+		tgbotapi.NewInlineKeyboardButtonData(telegramBotWrongButton, callbackData(id, wrong)),
+	)), true
+}
+
+// handleCallback records the feedback given with a button, or shows a button
+// per class when the user has to pick the correct one
+func (b *Bot) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	id, class, ok := parseCallbackData(query.Data)
+	if !ok || b.feedbackDeps == nil || query.Message == nil {
+		b.answerCallback(query.ID, "")
+		return
+	}
+
+	classes := b.feedbackDeps.Forward().Feedback().Classes()
+	if class == callbackDataAskClass {
+		rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(classes))
+		for index, className := range classes {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(className, callbackData(id, strconv.Itoa(index))),
+			))
+		}
+		b.editKeyboard(query.Message, tgbotapi.NewInlineKeyboardMarkup(rows...))
+		b.answerCallback(query.ID, "")
+		return
+	}
+
+	index, err := strconv.Atoi(class)
+	if err != nil || index < 0 || index >= len(classes) {
+		b.answerCallback(query.ID, "")
+		return
+	}
+
+	if _, err := servicePrediction.RecordFeedback(ctx, b.feedbackDeps.Forward(), id, classes[index], feedback.SourceTelegram); err != nil {
+		log.Printf("could not record feedback on photo %s: %v\n", id, err)
+		b.answerCallback(query.ID, telegramBotFeedbackErrorMessage)
+		return
+	}
+
+	b.editKeyboard(query.Message, tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+	b.answerCallback(query.ID, telegramBotFeedbackThanksMessage)
+}
+
+func (b *Bot) editKeyboard(message *tgbotapi.Message, keyboard tgbotapi.InlineKeyboardMarkup) {
+	if _, err := b.botAPI.Request(tgbotapi.NewEditMessageReplyMarkup(message.Chat.ID, message.MessageID, keyboard)); err != nil {
+		log.Println(err)
+	}
+}
+
+func (b *Bot) answerCallback(queryID, text string) {
+	if _, err := b.botAPI.Request(tgbotapi.NewCallback(queryID, text)); err != nil {
+		log.Println(err)
+	}
+}
+
+func callbackData(id, class string) string {
+	return strings.Join([]string{callbackDataPrefix, id, class}, callbackDataSeparator)
+}
+
+// parseCallbackData returns the image id and the class index of a feedback
+// button. The data is sent by the client and not trusted.
+func parseCallbackData(data string) (string, string, bool) {
+	parts := strings.Split(data, callbackDataSeparator)
+	if len(parts) != 3 || parts[0] != callbackDataPrefix || !strings.HasPrefix(parts[1], telegramImageIDPrefix) {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func classIndex(classes []string, class string) int {
+	for index, c := range classes {
+		if c == class {
+			return index
+		}
+	}
+	return -1
+}
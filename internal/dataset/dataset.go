@@ -0,0 +1,381 @@
+package dataset
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManifestFileName is the name of the manifest in the output folder
+	ManifestFileName = "manifest.csv"
+
+	// LabelSourceFeedback labels come from feedback on the prediction
+	LabelSourceFeedback = "feedback"
+	// LabelSourcePrediction labels are predictions the model was sure about
+	LabelSourcePrediction = "prediction"
+
+	// SubsetTrain and SubsetValidation are the folders of a split dataset
+	SubsetTrain      = "train"
+	SubsetValidation = "validation"
+
+	errorTextCouldNotCreateOutput  = "could not create output folder"
+	errorTextCouldNotWriteImage    = "could not write image"
+	errorTextCouldNotWriteManifest = "could not write manifest"
+	errorTextCouldNotLoadLabel     = "could not load label of image %s: %v\n"
+	errorTextCouldNotExportImage   = "could not export image %s: %v\n"
+)
+
+var (
+	// ErrOutputNotEmpty is returned when the output folder holds files
+	// already, which would end up in the dataset
+	ErrOutputNotEmpty = errors.New("output folder is not empty")
+	// ErrNoPredictions is returned when images are labelled by their
+	// predictions but no prediction is stored next to the images
+	ErrNoPredictions = errors.New("no predictions are stored, the backend needs PREDICTION_STORE=object, or export with feedback only")
+)
+
+// Options select the images of the dataset and how it is split
+type Options struct {
+	// MinProbability a prediction needs to label an image without feedback,
+	// next to being confident
+	MinProbability float32
+	// FeedbackOnly exports only images with feedback
+	FeedbackOnly bool
+	// MaxDistance is the largest Hamming distance between the perceptual
+	// hashes of two images that are exported once
+	MaxDistance int
+	// ValidationSplit is the share of images put into the validation
+	// subset, 0 writes the class folders without a split
+	ValidationSplit float64
+	// Seed of the split, the same seed puts an image into the same subset
+	// in every export
+	Seed int64
+}
+
+// An Entry is a row of the manifest
+type Entry struct {
+	// Path of the exported image relative to the output folder
+	Path           string
+	ImageID        string
+	Class          string
+	LabelSource    string
+	Subset         string
+	PredictedClass string
+	Probability    float32
+	ModelVersion   string
+	FeedbackSource string
+	LabelledAt     time.Time
+	Hash           uint64
+}
+
+// Summary counts the images of an export
+type Summary struct {
+	Exported   int
+	Duplicates int
+	Failed     int
+	// PerClass counts the exported images per class and subset
+	PerClass map[string]map[string]int
+}
+
+// Export writes the labelled images in the storage into one folder per class
+// below outPath, or below outPath/train and outPath/validation when a
+// validation split is set, and lists them in the manifest. Images with
+// feedback are labelled with the correct class, others with the predicted
+// class if the prediction is confident and the probability is high enough.
+// Of images with the same perceptual hash, only those with feedback and then
+// the first uploaded are kept. GIF and WebP images are written as PNG.
+func Export(storage objects.Reader, outPath string, options Options) (*Summary, error) {
+	if err := prepareOutput(outPath); err != nil {
+		return nil, err
+	}
+
+	entries, err := collectLabels(storage, options)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{PerClass: make(map[string]map[string]int)}
+	var exported []*Entry
+	for _, entry := range entries {
+		imageBytes, err := storage.ReadFromBucketObject(entry.ImageID)
+		if err != nil {
+			log.Printf(errorTextCouldNotExportImage, entry.ImageID, err)
+			summary.Failed++
+			continue
+		}
+		imageBytes, extension, err := trainingImage(imageBytes)
+		if err != nil {
+			log.Printf(errorTextCouldNotExportImage, entry.ImageID, err)
+			summary.Failed++
+			continue
+		}
+		entry.Hash, err = prediction.PerceptualHash(imageBytes)
+		if err != nil {
+			log.Printf(errorTextCouldNotExportImage, entry.ImageID, err)
+			summary.Failed++
+			continue
+		}
+		if isDuplicate(exported, entry.Hash, options.MaxDistance) {
+			summary.Duplicates++
+			continue
+		}
+
+		entry.Subset = subset(entry.ImageID, options)
+		entry.Path = filepath.Join(entry.Subset, entry.Class, entry.ImageID+extension)
+		if err := writeFile(filepath.Join(outPath, entry.Path), imageBytes); err != nil {
+			return nil, errors.Wrap(err, errorTextCouldNotWriteImage)
+		}
+
+		exported = append(exported, entry)
+		if summary.PerClass[entry.Class] == nil {
+			summary.PerClass[entry.Class] = make(map[string]int)
+		}
+		summary.PerClass[entry.Class][entry.Subset]++
+		summary.Exported++
+	}
+
+	if err := writeManifest(filepath.Join(outPath, ManifestFileName), exported); err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotWriteManifest)
+	}
+
+	return summary, nil
+}
+
+// prepareOutput creates the output folder, which has to be empty so that the
+// dataset only holds the exported images
+func prepareOutput(outPath string) error {
+	if err := os.MkdirAll(outPath, 0o755); err != nil {
+		return errors.Wrap(err, errorTextCouldNotCreateOutput)
+	}
+
+	files, err := os.ReadDir(outPath)
+	if err != nil {
+		return errors.Wrap(err, errorTextCouldNotCreateOutput)
+	}
+	if len(files) > 0 {
+		return errors.Wrap(ErrOutputNotEmpty, outPath)
+	}
+
+	return nil
+}
+
+// collectLabels returns an entry for every labelled image, those with
+// feedback first, each in the order of the image IDs, which are ordered by
+// upload time
+func collectLabels(storage objects.Reader, options Options) ([]*Entry, error) {
+	byID := make(map[string]*Entry)
+	var records []*predictionstore.Record
+
+	loaders := []objects.Loader{{
+		Suffix: feedback.FeedbackSuffix,
+		Kind:   "feedback",
+		Load: func(id string, data []byte) error {
+			imageFeedback := &feedback.Feedback{}
+			if err := json.Unmarshal(data, imageFeedback); err != nil {
+				return err
+			}
+			byID[imageFeedback.ImageID] = &Entry{
+				ImageID:        imageFeedback.ImageID,
+				Class:          imageFeedback.CorrectClass,
+				LabelSource:    LabelSourceFeedback,
+				PredictedClass: imageFeedback.PredictedClass,
+				ModelVersion:   imageFeedback.ModelVersion,
+				FeedbackSource: imageFeedback.Source,
+				LabelledAt:     imageFeedback.CreatedAt,
+			}
+			return nil
+		},
+	}}
+	if !options.FeedbackOnly {
+		loaders = append(loaders, objects.Loader{
+			Suffix: predictionstore.RecordSuffix,
+			Kind:   "predictions",
+			Load: func(id string, data []byte) error {
+				record := &predictionstore.Record{}
+				if err := json.Unmarshal(data, record); err != nil {
+					return err
+				}
+				records = append(records, record)
+				return nil
+			},
+		})
+	}
+	if err := objects.Load(storage, loaders...); err != nil {
+		return nil, err
+	}
+
+	if !options.FeedbackOnly && len(records) == 0 {
+		return nil, ErrNoPredictions
+	}
+	for _, record := range records {
+		if record.Result == nil {
+			continue
+		}
+
+		if entry, ok := byID[record.ImageID]; ok {
+			entry.Probability = record.Probability
+			continue
+		}
+		if record.Confidence != prediction.ConfidenceConfident || record.Probability < options.MinProbability {
+			continue
+		}
+		byID[record.ImageID] = &Entry{
+			ImageID:        record.ImageID,
+			Class:          record.Class,
+			LabelSource:    LabelSourcePrediction,
+			PredictedClass: record.Class,
+			Probability:    record.Probability,
+			ModelVersion:   record.ModelVersion,
+			LabelledAt:     record.CreatedAt,
+		}
+	}
+
+	entries := make([]*Entry, 0, len(byID))
+	for _, entry := range byID {
+		// the id and the class become part of the path and come from the
+		// storage
+		if !isPathElement(entry.ImageID) {
+			log.Printf(errorTextCouldNotLoadLabel, entry.ImageID, fmt.Errorf("invalid image id %q", entry.ImageID))
+			continue
+		}
+		if !isPathElement(entry.Class) {
+			log.Printf(errorTextCouldNotLoadLabel, entry.ImageID, fmt.Errorf("invalid class %q", entry.Class))
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].LabelSource != entries[j].LabelSource {
+			return entries[i].LabelSource == LabelSourceFeedback
+		}
+		return entries[i].ImageID < entries[j].ImageID
+	})
+
+	return entries, nil
+}
+
+// isPathElement reports whether the name can be used as a single element of
+// a path below the output folder
+func isPathElement(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// trainingImage returns the image in a format keras can read and the file
+// extension to write it with
+func trainingImage(imageBytes []byte) ([]byte, string, error) {
+	format, err := prediction.DetectImageFormat(imageBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch format {
+	case "jpeg":
+		return imageBytes, ".jpg", nil
+	case "png", "bmp":
+		return imageBytes, "." + format, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil, "", errors.Wrap(prediction.ErrInvalidImage, err.Error())
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), ".png", nil
+}
+
+func isDuplicate(exported []*Entry, hash uint64, maxDistance int) bool {
+	for _, entry := range exported {
+		if prediction.HammingDistance(entry.Hash, hash) <= maxDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// subset assigns the image by a hash of the seed and its ID, so that it stays
+// in its subset when the dataset grows
+func subset(imageID string, options Options) string {
+	if options.ValidationSplit <= 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", options.Seed, imageID)))
+	if float64(binary.BigEndian.Uint64(sum[:8]))/float64(^uint64(0)) < options.ValidationSplit {
+		return SubsetValidation
+	}
+	return SubsetTrain
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func writeManifest(path string, entries []*Entry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"path", "image_id", "class", "label_source", "subset", "predicted_class", "probability", "model_version", "feedback_source", "labelled_at", "phash"}); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		probability := ""
+		if entry.Probability > 0 {
+			probability = strconv.FormatFloat(float64(entry.Probability), 'f', -1, 32)
+		}
+		labelledAt := ""
+		if !entry.LabelledAt.IsZero() {
+			labelledAt = entry.LabelledAt.UTC().Format(time.RFC3339)
+		}
+
+		if err := w.Write([]string{
+			filepath.ToSlash(entry.Path),
+			entry.ImageID,
+			entry.Class,
+			entry.LabelSource,
+			entry.Subset,
+			entry.PredictedClass,
+			probability,
+			entry.ModelVersion,
+			entry.FeedbackSource,
+			labelledAt,
+			strconv.FormatUint(entry.Hash, 16),
+		}); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,233 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	"github.com/pdstuber/isit-a-cat/internal/service/objects/objectstest"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+func putJSON(t *testing.T, storage *objectstest.Storage, objectID string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.Objects[objectID] = data
+}
+
+func putPrediction(t *testing.T, storage *objectstest.Storage, id, class string, probability float32) {
+	confidence := prediction.ConfidenceConfident
+	if probability < 0.9 {
+		confidence = prediction.ConfidenceUncertain
+	}
+	putJSON(t, storage, id+predictionstore.RecordSuffix, &predictionstore.Record{
+		ImageID:   id,
+		Result:    &prediction.Result{Class: class, Probability: probability, Confidence: confidence, ModelVersion: "v1"},
+		CreatedAt: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+	})
+}
+
+func putFeedback(t *testing.T, storage *objectstest.Storage, id, predicted, correct string) {
+	putJSON(t, storage, id+feedback.FeedbackSuffix, &feedback.Feedback{
+		ImageID:        id,
+		PredictedClass: predicted,
+		CorrectClass:   correct,
+		ModelVersion:   "v1",
+		Source:         feedback.SourceTelegram,
+	})
+}
+
+// patternImage draws stripes whose perceptual hash differs for every seed
+func patternImage(seed int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 36, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 36; x++ {
+			brightness := uint8(0)
+			if (x*(seed+1)/9+y*seed/8)%2 == 0 {
+				brightness = 255
+			}
+			img.Set(x, y, color.RGBA{brightness, brightness, brightness, 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readManifest(t *testing.T, outPath string) [][]string {
+	file, err := os.Open(filepath.Join(outPath, ManifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func Test_Export(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["a"] = encodeJPEG(t, patternImage(1))
+	putPrediction(t, storage, "a", "cats", 0.99)
+	// feedback wins over the prediction
+	storage.Objects["b"] = encodeJPEG(t, patternImage(2))
+	putPrediction(t, storage, "b", "cats", 0.97)
+	putFeedback(t, storage, "b", "cats", "non_cats")
+	// not confident
+	storage.Objects["c"] = encodeJPEG(t, patternImage(3))
+	putPrediction(t, storage, "c", "cats", 0.6)
+	// confident, but below the minimum probability
+	storage.Objects["g"] = encodeJPEG(t, patternImage(5))
+	putPrediction(t, storage, "g", "cats", 0.92)
+	// a re-encoded copy of a
+	storage.Objects["d"] = encodeGIF(t, patternImage(1))
+	putPrediction(t, storage, "d", "cats", 0.99)
+	// the image is gone
+	putPrediction(t, storage, "e", "cats", 0.99)
+	storage.Objects["f"] = encodeGIF(t, patternImage(4))
+	putFeedback(t, storage, "f", "non_cats", "cats")
+
+	outPath := t.TempDir()
+	summary, err := Export(storage, outPath, Options{MinProbability: 0.95, MaxDistance: 4})
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Summary{
+		Exported:   3,
+		Duplicates: 1,
+		Failed:     1,
+		PerClass: map[string]map[string]int{
+			"cats":     {"": 2},
+			"non_cats": {"": 1},
+		},
+	}, summary)
+
+	for _, path := range []string{"cats/a.jpg", "non_cats/b.jpg", "cats/f.png"} {
+		assert.FileExists(t, filepath.Join(outPath, path))
+	}
+	assert.NoFileExists(t, filepath.Join(outPath, "cats/c.jpg"))
+	assert.NoFileExists(t, filepath.Join(outPath, "cats/g.jpg"))
+	assert.NoFileExists(t, filepath.Join(outPath, "cats/d.png"))
+
+	rows := readManifest(t, outPath)
+	if assert.Len(t, rows, 4) {
+		assert.Equal(t, []string{"path", "image_id", "class", "label_source", "subset", "predicted_class", "probability", "model_version", "feedback_source", "labelled_at", "phash"}, rows[0])
+		assert.Equal(t, []string{"non_cats/b.jpg", "b", "non_cats", LabelSourceFeedback, "", "cats", "0.97", "v1", feedback.SourceTelegram}, rows[1][:9])
+		assert.Equal(t, []string{"cats/f.png", "f", "cats", LabelSourceFeedback, "", "non_cats", "", "v1", feedback.SourceTelegram}, rows[2][:9])
+		assert.Equal(t, []string{"cats/a.jpg", "a", "cats", LabelSourcePrediction, "", "cats", "0.99", "v1", "", "2024-01-31T12:00:00Z"}, rows[3][:10])
+	}
+}
+
+func Test_Export_feedback_only(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["a"] = encodeJPEG(t, patternImage(1))
+	putPrediction(t, storage, "a", "cats", 0.99)
+	storage.Objects["b"] = encodeJPEG(t, patternImage(2))
+	putFeedback(t, storage, "b", "cats", "cats")
+
+	outPath := t.TempDir()
+	summary, err := Export(storage, outPath, Options{FeedbackOnly: true})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Exported)
+	assert.FileExists(t, filepath.Join(outPath, "cats/b.jpg"))
+	assert.NoFileExists(t, filepath.Join(outPath, "cats/a.jpg"))
+}
+
+func Test_Export_split_is_reproducible(t *testing.T) {
+	storage := objectstest.NewStorage()
+	for i := 0; i < 40; i++ {
+		id := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		storage.Objects[id] = encodeJPEG(t, patternImage(1))
+		putPrediction(t, storage, id, "cats", 0.99)
+	}
+	options := Options{MinProbability: 0.9, MaxDistance: -1, ValidationSplit: 0.25, Seed: 7}
+
+	first, second := t.TempDir(), t.TempDir()
+	summary, err := Export(storage, first, options)
+	assert.NoError(t, err)
+	_, err = Export(storage, second, options)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 40, summary.Exported)
+	assert.Equal(t, 40, summary.PerClass["cats"][SubsetTrain]+summary.PerClass["cats"][SubsetValidation])
+	assert.NotZero(t, summary.PerClass["cats"][SubsetTrain])
+	assert.NotZero(t, summary.PerClass["cats"][SubsetValidation])
+	assert.Equal(t, readManifest(t, first), readManifest(t, second))
+
+	for _, row := range readManifest(t, first)[1:] {
+		assert.Equal(t, filepath.ToSlash(filepath.Join(row[4], "cats", row[1]+".jpg")), row[0])
+		assert.FileExists(t, filepath.Join(first, row[0]))
+	}
+}
+
+func Test_Export_output_not_empty(t *testing.T) {
+	outPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outPath, "old.jpg"), []byte{1}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Export(objectstest.NewStorage(), outPath, Options{})
+
+	assert.ErrorIs(t, err, ErrOutputNotEmpty)
+}
+
+func Test_Export_invalid_path(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["a"] = encodeJPEG(t, patternImage(1))
+	putPrediction(t, storage, "a", "../cats", 0.99)
+	storage.Objects["../b"] = encodeJPEG(t, patternImage(2))
+	putPrediction(t, storage, "../b", "cats", 0.99)
+	storage.Objects[`..\c`] = encodeJPEG(t, patternImage(3))
+	putFeedback(t, storage, `..\c`, "cats", "cats")
+
+	outPath := t.TempDir()
+	summary, err := Export(storage, filepath.Join(outPath, "dataset"), Options{})
+
+	assert.NoError(t, err)
+	assert.Zero(t, summary.Exported)
+	assert.NoFileExists(t, filepath.Join(outPath, "b.jpg"))
+	assert.NoFileExists(t, filepath.Join(outPath, "dataset", "cats", "b.jpg"))
+}
+
+func Test_Export_no_predictions(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["a"] = encodeJPEG(t, patternImage(1))
+	putFeedback(t, storage, "a", "cats", "cats")
+
+	_, err := Export(storage, t.TempDir(), Options{})
+	assert.ErrorIs(t, err, ErrNoPredictions)
+
+	summary, err := Export(storage, t.TempDir(), Options{FeedbackOnly: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, summary.Exported)
+}
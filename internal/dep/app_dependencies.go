@@ -1,9 +1,23 @@
 package dep
 
+import (
+	"github.com/pdstuber/isit-a-cat/internal/service/dedup"
+	"github.com/pdstuber/isit-a-cat/internal/service/experiment"
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+	"github.com/pdstuber/isit-a-cat/internal/service/similarity"
+)
+
 type AppDependencies struct {
-	storageService StorageReaderWriter
-	idGenerator    IDGenerator
-	imagePredictor ImagePredictor
+	storageService  StorageReaderWriter
+	idGenerator     IDGenerator
+	imagePredictor  ImagePredictor
+	modelReloader   ModelReloader
+	experiment      *experiment.Predictor
+	similarity      *similarity.Service
+	deduplication   *dedup.Service
+	predictionStore predictionstore.Store
+	feedback        *feedback.Service
 }
 
 func NewAppDependencies() AppDependencies {
@@ -24,3 +38,16 @@ func (d AppDependencies) WithImagePredictor(imagePredictor ImagePredictor) AppDe
 	d.imagePredictor = imagePredictor
 	return d
 }
+
+func (d AppDependencies) WithModelReloader(modelReloader ModelReloader) AppDependencies {
+	d.modelReloader = modelReloader
+	return d
+}
+
+// WithSecondaryImagePredictor serves the secondary image predictor next to the
+// one set with WithImagePredictor, which has to be called first
+func (d AppDependencies) WithSecondaryImagePredictor(secondary ImagePredictor, config experiment.Config) AppDependencies {
+	d.experiment = experiment.New(d.imagePredictor, secondary, config)
+	d.imagePredictor = d.experiment
+	return d
+}
@@ -0,0 +1,19 @@
+package dep
+
+import "github.com/pdstuber/isit-a-cat/internal/service/dedup"
+
+// WithDeduplication answers uploads of images that were uploaded before with
+// the existing image
+func (d AppDependencies) WithDeduplication(deduplication *dedup.Service) AppDependencies {
+	d.deduplication = deduplication
+	return d
+}
+
+type HasDeduplication interface {
+	Deduplication() *dedup.Service
+}
+
+// Deduplication returns nil when uploads are not deduplicated
+func (d AppDependencies) Deduplication() *dedup.Service {
+	return d.deduplication
+}
@@ -0,0 +1,18 @@
+package dep
+
+import "github.com/pdstuber/isit-a-cat/internal/service/feedback"
+
+// WithFeedback accepts feedback on the predictions of uploaded images
+func (d AppDependencies) WithFeedback(feedback *feedback.Service) AppDependencies {
+	d.feedback = feedback
+	return d
+}
+
+type HasFeedback interface {
+	Feedback() *feedback.Service
+}
+
+// Feedback returns nil when feedback is not collected
+func (d AppDependencies) Feedback() *feedback.Service {
+	return d.feedback
+}
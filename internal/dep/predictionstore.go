@@ -0,0 +1,19 @@
+package dep
+
+import "github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
+
+// WithPredictionStore records every prediction of an uploaded image and
+// answers later requests for the image from the store
+func (d AppDependencies) WithPredictionStore(store predictionstore.Store) AppDependencies {
+	d.predictionStore = store
+	return d
+}
+
+type HasPredictionStore interface {
+	PredictionStore() predictionstore.Store
+}
+
+// PredictionStore returns nil when predictions are not stored
+func (d AppDependencies) PredictionStore() predictionstore.Store {
+	return d.predictionStore
+}
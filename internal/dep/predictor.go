@@ -1,12 +1,23 @@
 package dep
 
-import "github.com/pdstuber/isit-a-cat/pkg/prediction"
+import (
+	"context"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/experiment"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
 
 type ImagePredictor interface {
-	PredictImage(imageBytes []byte) (*prediction.Result, error)
+	PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error)
 	Stop() error
 }
 
+// ImageExplainer is an image predictor that can explain its predictions
+type ImageExplainer interface {
+	ImagePredictor
+	prediction.Explainer
+}
+
 type HasImagePredictor interface {
 	ImagePredictor() ImagePredictor
 }
@@ -14,3 +25,23 @@ type HasImagePredictor interface {
 func (d AppDependencies) ImagePredictor() ImagePredictor {
 	return d.imagePredictor
 }
+
+type ModelReloader interface {
+	Reload(ctx context.Context) error
+}
+
+type HasModelReloader interface {
+	ModelReloader() ModelReloader
+}
+
+func (d AppDependencies) ModelReloader() ModelReloader {
+	return d.modelReloader
+}
+
+type HasExperiment interface {
+	Experiment() *experiment.Predictor
+}
+
+func (d AppDependencies) Experiment() *experiment.Predictor {
+	return d.experiment
+}
@@ -0,0 +1,18 @@
+package dep
+
+import "github.com/pdstuber/isit-a-cat/internal/service/similarity"
+
+// WithSimilarity indexes uploaded images and serves similar ones
+func (d AppDependencies) WithSimilarity(similarity *similarity.Service) AppDependencies {
+	d.similarity = similarity
+	return d
+}
+
+type HasSimilarity interface {
+	Similarity() *similarity.Service
+}
+
+// Similarity returns nil when no embedding tensor is configured
+func (d AppDependencies) Similarity() *similarity.Service {
+	return d.similarity
+}
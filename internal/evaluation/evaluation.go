@@ -0,0 +1,206 @@
+package evaluation
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
+
+// A Sample is a prediction for an image whose actual class is known
+type Sample struct {
+	Path   string
+	Class  string
+	Result *prediction.Result
+}
+
+// Report summarizes how well the model predicted a set of labelled images
+type Report struct {
+	Classes                 []string            `json:"classes"`
+	Samples                 int                 `json:"samples"`
+	Failed                  int                 `json:"failed"`
+	Accuracy                float64             `json:"accuracy"`
+	PerClass                []ClassMetrics      `json:"perClass"`
+	ConfusionMatrix         [][]int             `json:"confusionMatrix"`
+	WorstMisclassifications []Misclassification `json:"worstMisclassifications"`
+}
+
+// ClassMetrics are the precision, recall and F1 score of a single class
+type ClassMetrics struct {
+	Class     string  `json:"class"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// A Misclassification is an image the model assigned to the wrong class
+type Misclassification struct {
+	Path                   string  `json:"path"`
+	Actual                 string  `json:"actual"`
+	Predicted              string  `json:"predicted"`
+	Probability            float32 `json:"probability"`
+	ActualClassProbability float32 `json:"actualClassProbability"`
+}
+
+// NewReport evaluates the samples against the classes of the given labels.
+// The rows of the confusion matrix are the actual classes, the columns the
+// predicted ones, both in label index order. Only the worst misclassifications,
+// those predicted with the highest probability, are kept, ties ordered by
+// path.
+func NewReport(labels []prediction.Label, samples []Sample, failed, worst int) *Report {
+	sortedLabels := append([]prediction.Label(nil), labels...)
+	sort.Slice(sortedLabels, func(i, j int) bool { return sortedLabels[i].Index < sortedLabels[j].Index })
+
+	classes := make([]string, len(sortedLabels))
+	classIndex := make(map[string]int, len(sortedLabels))
+	for i, label := range sortedLabels {
+		classes[i] = label.ClassName
+		classIndex[label.ClassName] = i
+	}
+
+	confusionMatrix := make([][]int, len(classes))
+	for i := range confusionMatrix {
+		confusionMatrix[i] = make([]int, len(classes))
+	}
+
+	var misclassifications []Misclassification
+	correct := 0
+	for _, sample := range samples {
+		actual, ok := classIndex[sample.Class]
+		if !ok {
+			continue
+		}
+		predicted, ok := classIndex[sample.Result.Class]
+		if !ok {
+			continue
+		}
+
+		confusionMatrix[actual][predicted]++
+		if actual == predicted {
+			correct++
+			continue
+		}
+
+		misclassifications = append(misclassifications, Misclassification{
+			Path:                   sample.Path,
+			Actual:                 sample.Class,
+			Predicted:              sample.Result.Class,
+			Probability:            sample.Result.Probability,
+			ActualClassProbability: probabilityOf(sample.Result, sample.Class),
+		})
+	}
+
+	sort.Slice(misclassifications, func(i, j int) bool {
+		if misclassifications[i].Probability != misclassifications[j].Probability {
+			return misclassifications[i].Probability > misclassifications[j].Probability
+		}
+		return misclassifications[i].Path < misclassifications[j].Path
+	})
+	if len(misclassifications) > worst {
+		misclassifications = misclassifications[:worst]
+	}
+
+	evaluated := 0
+	for i := range confusionMatrix {
+		for j := range confusionMatrix[i] {
+			evaluated += confusionMatrix[i][j]
+		}
+	}
+
+	return &Report{
+		Classes:                 classes,
+		Samples:                 evaluated,
+		Failed:                  failed,
+		Accuracy:                ratio(correct, evaluated),
+		PerClass:                classMetrics(classes, confusionMatrix),
+		ConfusionMatrix:         confusionMatrix,
+		WorstMisclassifications: misclassifications,
+	}
+}
+
+func classMetrics(classes []string, confusionMatrix [][]int) []ClassMetrics {
+	metrics := make([]ClassMetrics, len(classes))
+
+	for c := range classes {
+		truePositives := confusionMatrix[c][c]
+		predicted, actual := 0, 0
+		for i := range classes {
+			predicted += confusionMatrix[i][c]
+			actual += confusionMatrix[c][i]
+		}
+
+		precision := ratio(truePositives, predicted)
+		recall := ratio(truePositives, actual)
+		var f1 float64
+		if precision+recall > 0 {
+			f1 = 2 * precision * recall / (precision + recall)
+		}
+
+		metrics[c] = ClassMetrics{
+			Class:     classes[c],
+			Precision: precision,
+			Recall:    recall,
+			F1:        f1,
+			Support:   actual,
+		}
+	}
+
+	return metrics
+}
+
+func probabilityOf(result *prediction.Result, class string) float32 {
+	for _, score := range result.Scores {
+		if score.Class == class {
+			return score.Probability
+		}
+	}
+
+	return 0
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+
+	return float64(a) / float64(b)
+}
+
+// WriteSummary prints the report in human readable form
+func (r *Report) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Evaluated %d images (%d failed)\n", r.Samples, r.Failed)
+	fmt.Fprintf(tw, "Accuracy: %.4f\n\n", r.Accuracy)
+
+	fmt.Fprintln(tw, "CLASS\tPRECISION\tRECALL\tF1\tSUPPORT")
+	for _, m := range r.PerClass {
+		fmt.Fprintf(tw, "%s\t%.4f\t%.4f\t%.4f\t%d\n", m.Class, m.Precision, m.Recall, m.F1, m.Support)
+	}
+
+	fmt.Fprintln(tw, "\nConfusion matrix (rows: actual, columns: predicted)")
+	for _, class := range r.Classes {
+		fmt.Fprintf(tw, "\t%s", class)
+	}
+	fmt.Fprintln(tw)
+	for i, row := range r.ConfusionMatrix {
+		fmt.Fprint(tw, r.Classes[i])
+		for _, count := range row {
+			fmt.Fprintf(tw, "\t%d", count)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	if len(r.WorstMisclassifications) > 0 {
+		fmt.Fprintln(tw, "\nWorst misclassifications")
+		fmt.Fprintln(tw, "PATH\tACTUAL\tPREDICTED\tPROBABILITY")
+		for _, m := range r.WorstMisclassifications {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.4f\n", m.Path, m.Actual, m.Predicted, m.Probability)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,113 @@
+package evaluation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+var testLabels = []prediction.Label{
+	{Index: 1, ClassName: "non_cats"},
+	{Index: 0, ClassName: "cats"},
+}
+
+func sample(path, actual, predicted string, probability float32) Sample {
+	other := "cats"
+	if predicted == "cats" {
+		other = "non_cats"
+	}
+
+	return Sample{
+		Path:  path,
+		Class: actual,
+		Result: &prediction.Result{
+			Class:       predicted,
+			Probability: probability,
+			Scores: []prediction.ClassScore{
+				{Class: predicted, Probability: probability},
+				{Class: other, Probability: 1 - probability},
+			},
+		},
+	}
+}
+
+func Test_NewReport(t *testing.T) {
+	samples := []Sample{
+		sample("cat1.jpg", "cats", "cats", 0.9),
+		sample("cat2.jpg", "cats", "cats", 0.8),
+		sample("cat3.jpg", "cats", "non_cats", 0.6),
+		sample("dog1.jpg", "non_cats", "non_cats", 0.95),
+		sample("dog2.jpg", "non_cats", "cats", 0.7),
+	}
+
+	report := NewReport(testLabels, samples, 1, 10)
+
+	assert.Equal(t, []string{"cats", "non_cats"}, report.Classes)
+	assert.Equal(t, 5, report.Samples)
+	assert.Equal(t, 1, report.Failed)
+	assert.InDelta(t, 0.6, report.Accuracy, 1e-9)
+	assert.Equal(t, [][]int{{2, 1}, {1, 1}}, report.ConfusionMatrix)
+
+	cats := report.PerClass[0]
+	assert.Equal(t, "cats", cats.Class)
+	assert.InDelta(t, 2.0/3.0, cats.Precision, 1e-9)
+	assert.InDelta(t, 2.0/3.0, cats.Recall, 1e-9)
+	assert.InDelta(t, 2.0/3.0, cats.F1, 1e-9)
+	assert.Equal(t, 3, cats.Support)
+
+	nonCats := report.PerClass[1]
+	assert.InDelta(t, 0.5, nonCats.Precision, 1e-9)
+	assert.InDelta(t, 0.5, nonCats.Recall, 1e-9)
+	assert.Equal(t, 2, nonCats.Support)
+
+	assert.Len(t, report.WorstMisclassifications, 2)
+	assert.Equal(t, "dog2.jpg", report.WorstMisclassifications[0].Path)
+	assert.InDelta(t, 0.3, report.WorstMisclassifications[0].ActualClassProbability, 1e-6)
+	assert.Equal(t, "cat3.jpg", report.WorstMisclassifications[1].Path)
+}
+
+func Test_NewReport_limits_misclassifications(t *testing.T) {
+	samples := []Sample{
+		sample("cat1.jpg", "cats", "non_cats", 0.6),
+		sample("cat2.jpg", "cats", "non_cats", 0.9),
+	}
+
+	report := NewReport(testLabels, samples, 0, 1)
+
+	assert.Len(t, report.WorstMisclassifications, 1)
+	assert.Equal(t, "cat2.jpg", report.WorstMisclassifications[0].Path)
+}
+
+func Test_NewReport_orders_ties_by_path(t *testing.T) {
+	samples := []Sample{
+		sample("cat3.jpg", "cats", "non_cats", 0.8),
+		sample("cat1.jpg", "cats", "non_cats", 0.8),
+		sample("cat2.jpg", "cats", "non_cats", 0.8),
+	}
+
+	report := NewReport(testLabels, samples, 0, 2)
+
+	if assert.Len(t, report.WorstMisclassifications, 2) {
+		assert.Equal(t, "cat1.jpg", report.WorstMisclassifications[0].Path)
+		assert.Equal(t, "cat2.jpg", report.WorstMisclassifications[1].Path)
+	}
+}
+
+func Test_NewReport_no_samples(t *testing.T) {
+	report := NewReport(testLabels, nil, 0, 10)
+
+	assert.Equal(t, 0.0, report.Accuracy)
+	assert.Equal(t, 0.0, report.PerClass[0].F1)
+}
+
+func Test_WriteSummary(t *testing.T) {
+	report := NewReport(testLabels, []Sample{sample("dog.jpg", "non_cats", "cats", 0.7)}, 0, 10)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteSummary(&buf))
+
+	assert.Contains(t, buf.String(), "Accuracy: 0.0000")
+	assert.Contains(t, buf.String(), "dog.jpg")
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const calibrationFileName = "calibration.json"
+
+// readCalibration returns the calibration in the model path, or nil if there
+// is none. It fails if the calibration does not fit the number of classes.
+func readCalibration(modelPath string, numClasses int) (*prediction.Calibration, error) {
+	calibrationBytes, err := os.ReadFile(filepath.Join(modelPath, calibrationFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not read calibration")
+	}
+
+	var calibration prediction.Calibration
+	if err := json.Unmarshal(calibrationBytes, &calibration); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal calibration")
+	}
+	if err := calibration.Validate(numClasses); err != nil {
+		return nil, errors.Wrap(err, "invalid calibration")
+	}
+
+	return &calibration, nil
+}
+
+// WriteCalibration writes the calibration next to the labels in the model
+// path, replacing the file at once so a watching process reloads it fully
+func WriteCalibration(modelPath string, calibration *prediction.Calibration) error {
+	calibrationBytes, err := json.MarshalIndent(calibration, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not marshal calibration")
+	}
+
+	path := filepath.Join(modelPath, calibrationFileName)
+	if err := os.WriteFile(path+".tmp", calibrationBytes, 0o644); err != nil {
+		return errors.Wrap(err, "could not write calibration")
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return errors.Wrap(err, "could not write calibration")
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocarina/gocsv"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultModelPath    = "/model"
+	modelFileName       = "model.pb"
+	labelsFileName      = "labels.csv"
+	savedModelVariables = "variables/variables.index"
+)
+
+// Config holds the model and everything needed to run predictions with it
+type Config struct {
+	ModelPath    string
+	ModelVersion string
+	// Format of the model in ModelPath. Model is empty for a SavedModel,
+	// whose operation names are optional.
+	Format              prediction.ModelFormat
+	SavedModelTags      []string
+	SavedModelSignature string
+	ModelWatchInterval  time.Duration
+	Labels              []prediction.Label
+	// Calibration of the probabilities, nil if there is no calibration.json
+	Calibration           *prediction.Calibration
+	Model                 []byte
+	TargetImageDimensions int
+	TFInputOperationName  string
+	TFOutputOperationName string
+	ResizeMode            prediction.ResizeMode
+	AspectPolicy          prediction.AspectPolicy
+	Thresholds            prediction.Thresholds
+	PredictionWorkers     int
+	PredictionQueueSize   int
+	PredictionTimeout     time.Duration
+	MaxBatchSize          int
+	MaxBatchWait          time.Duration
+	ExplanationPatchSize  int
+	ExplanationStride     int
+	// EmbeddingTensor is fetched as the embedding of images, empty disables
+	// embeddings and the search for similar images
+	EmbeddingTensor string
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// PathFromEnv returns the model directory set in MODEL_PATH
+func PathFromEnv() string {
+	return getEnv("MODEL_PATH", defaultModelPath)
+}
+
+// ConfigFromEnv loads the model from the directory in MODEL_PATH
+func ConfigFromEnv() (*Config, error) {
+	return ConfigFromPath(PathFromEnv())
+}
+
+// ReadFiles returns the model and the labels in the model path
+func ReadFiles(modelPath string) ([]byte, []byte, error) {
+	model, err := os.ReadFile(filepath.Join(modelPath, modelFileName))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not read model")
+	}
+	labels, err := os.ReadFile(filepath.Join(modelPath, labelsFileName))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not read labels")
+	}
+
+	return model, labels, nil
+}
+
+// ConfigFromPath loads the model from the given directory. The settings of a
+// manifest.json in the directory take precedence, all others are taken from
+// the environment.
+func ConfigFromPath(modelPath string) (*Config, error) {
+	var labels []prediction.Label
+
+	format := prediction.DetectModelFormat(modelPath)
+	var model, labelBytes []byte
+	var err error
+	switch format {
+	case prediction.FormatFrozenGraph:
+		model, labelBytes, err = ReadFiles(modelPath)
+		if err != nil {
+			return nil, err
+		}
+	case prediction.FormatWeights:
+		model, err = os.ReadFile(filepath.Join(modelPath, format.FileName()))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read model")
+		}
+		fallthrough
+	default:
+		labelBytes, err = os.ReadFile(filepath.Join(modelPath, labelsFileName))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read labels")
+		}
+	}
+
+	if err := gocsv.UnmarshalBytes(labelBytes, &labels); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal labels csv")
+	}
+
+	calibration, err := readCalibration(modelPath, len(labels))
+	if err != nil {
+		return nil, err
+	}
+
+	targetImageDimensions, err := strconv.Atoi(getEnv("TARGET_IMAGE_DIMENSIONS", "256"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+	}
+
+	// a SavedModel names its input and output in the signature
+	defaultInputOperationName, defaultOutputOperationName := "input_1", "dense_3/Softmax"
+	if format == prediction.FormatSavedModel {
+		defaultInputOperationName, defaultOutputOperationName = "", ""
+	}
+	inputOperationName := getEnv("TF_INPUT_OPERATION_NAME", defaultInputOperationName)
+	outputOperationName := getEnv("TF_OUTPUT_OPERATION_NAME", defaultOutputOperationName)
+	savedModelTags := strings.Split(getEnv("SAVED_MODEL_TAGS", prediction.DefaultSavedModelTag), ",")
+	savedModelSignature := getEnv("SAVED_MODEL_SIGNATURE", prediction.DefaultSignatureName)
+
+	manifest, err := readManifest(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	var modelVersion string
+	if manifest != nil {
+		if model != nil && manifest.ModelSHA256 != "" && manifest.ModelSHA256 != Checksum(model) {
+			return nil, errors.Errorf("%s does not match the checksum in %s", format.FileName(), manifestFileName)
+		}
+		modelVersion = manifest.Version
+		if manifest.TargetImageDimensions > 0 {
+			targetImageDimensions = manifest.TargetImageDimensions
+		}
+		if manifest.TFInputOperationName != "" {
+			inputOperationName = manifest.TFInputOperationName
+		}
+		if manifest.TFOutputOperationName != "" {
+			outputOperationName = manifest.TFOutputOperationName
+		}
+	}
+
+	resizeMode, err := prediction.ParseResizeMode(getEnv("RESIZE_MODE", string(prediction.ResizeNearest)))
+	if err != nil {
+		return nil, err
+	}
+	aspectPolicy, err := prediction.ParseAspectPolicy(getEnv("ASPECT_POLICY", string(prediction.AspectStretch)))
+	if err != nil {
+		return nil, err
+	}
+
+	thresholds, err := thresholdsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	predictionWorkers, err := strconv.Atoi(getEnv("PREDICTION_WORKERS", "4"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+	}
+	predictionQueueSize, err := strconv.Atoi(getEnv("PREDICTION_QUEUE_SIZE", "100"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+	}
+	predictionTimeout, err := time.ParseDuration(getEnv("PREDICTION_TIMEOUT", "30s"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse as duration, please use correct format")
+	}
+	maxBatchSize, err := strconv.Atoi(getEnv("PREDICTION_MAX_BATCH_SIZE", "1"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+	}
+	maxBatchWait, err := time.ParseDuration(getEnv("PREDICTION_MAX_BATCH_WAIT", "5ms"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse as duration, please use correct format")
+	}
+	explanationPatchSize, err := strconv.Atoi(getEnv("EXPLANATION_PATCH_SIZE", "0"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+	}
+	explanationStride, err := strconv.Atoi(getEnv("EXPLANATION_STRIDE", "0"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+	}
+	modelWatchInterval, err := time.ParseDuration(getEnv("MODEL_WATCH_INTERVAL", "0s"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse as duration, please use correct format")
+	}
+
+	if modelVersion == "" {
+		modelFiles := [][]byte{model, labelBytes}
+		if format == prediction.FormatSavedModel {
+			modelFiles, err = readSavedModelFiles(modelPath, labelBytes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		modelVersion, err = derivedVersion(modelFiles, []any{
+			calibration, thresholds, resizeMode, aspectPolicy, targetImageDimensions,
+			inputOperationName, outputOperationName, savedModelTags, savedModelSignature,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Config{
+		ModelPath:             modelPath,
+		ModelVersion:          modelVersion,
+		Format:                format,
+		SavedModelTags:        savedModelTags,
+		SavedModelSignature:   savedModelSignature,
+		ModelWatchInterval:    modelWatchInterval,
+		Labels:                labels,
+		Calibration:           calibration,
+		Model:                 model,
+		TargetImageDimensions: targetImageDimensions,
+		TFInputOperationName:  inputOperationName,
+		TFOutputOperationName: outputOperationName,
+		ResizeMode:            resizeMode,
+		AspectPolicy:          aspectPolicy,
+		Thresholds:            thresholds,
+		PredictionWorkers:     predictionWorkers,
+		PredictionQueueSize:   predictionQueueSize,
+		PredictionTimeout:     predictionTimeout,
+		MaxBatchSize:          maxBatchSize,
+		MaxBatchWait:          maxBatchWait,
+		ExplanationPatchSize:  explanationPatchSize,
+		ExplanationStride:     explanationStride,
+		EmbeddingTensor:       getEnv("EMBEDDING_TENSOR", ""),
+	}, nil
+}
+
+// readSavedModelFiles returns the graph and the variable index of the
+// SavedModel, which change with every export, and the labels
+func readSavedModelFiles(modelPath string, labels []byte) ([][]byte, error) {
+	files := [][]byte{labels}
+	for _, name := range []string{prediction.FormatSavedModel.FileName(), savedModelVariables} {
+		file, err := os.ReadFile(filepath.Join(modelPath, name))
+		if errors.Is(err, os.ErrNotExist) && name == savedModelVariables {
+			// a SavedModel without variables holds its weights in the graph
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "could not read model")
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// thresholdsFromEnv reads the probabilities deciding the confidence of results
+// from CONFIDENT_THRESHOLD and REJECTED_THRESHOLD
+func thresholdsFromEnv() (prediction.Thresholds, error) {
+	thresholds := prediction.DefaultThresholds()
+
+	confident, err := strconv.ParseFloat(getEnv("CONFIDENT_THRESHOLD", strconv.FormatFloat(prediction.DefaultConfidentThreshold, 'f', -1, 32)), 32)
+	if err != nil {
+		return thresholds, errors.Wrap(err, "could not convert to float, please use correct format")
+	}
+	rejected, err := strconv.ParseFloat(getEnv("REJECTED_THRESHOLD", strconv.FormatFloat(prediction.DefaultRejectedThreshold, 'f', -1, 32)), 32)
+	if err != nil {
+		return thresholds, errors.Wrap(err, "could not convert to float, please use correct format")
+	}
+
+	thresholds.Confident = float32(confident)
+	thresholds.Rejected = float32(rejected)
+
+	return thresholds, thresholds.Validate()
+}
+
+// Files returns the paths of the model, the labels and the calibration
+func (c *Config) Files() []string {
+	files := []string{filepath.Join(c.ModelPath, c.Format.FileName())}
+	if c.Format == prediction.FormatSavedModel {
+		files = append(files, filepath.Join(c.ModelPath, savedModelVariables))
+	}
+
+	return append(files, filepath.Join(c.ModelPath, labelsFileName), filepath.Join(c.ModelPath, calibrationFileName))
+}
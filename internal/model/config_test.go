@@ -0,0 +1,185 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testModel  = []byte("model")
+	testLabels = []byte("index,class_name\n0,cats\n1,non_cats\n")
+)
+
+func Test_ConfigFromPath_without_manifest(t *testing.T) {
+	modelPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modelPath, modelFileName), testModel, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelPath, labelsFileName), testLabels, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ConfigFromPath(modelPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, modelPath, config.ModelPath)
+	assert.Regexp(t, `^local-[0-9a-f]{12}$`, config.ModelVersion)
+	assert.Equal(t, "input_1", config.TFInputOperationName)
+	assert.Len(t, config.Labels, 2)
+}
+
+func Test_ConfigFromPath_derived_version_changes_with_model_and_settings(t *testing.T) {
+	modelPath := t.TempDir()
+	writeModel := func(model []byte) {
+		if err := os.WriteFile(filepath.Join(modelPath, modelFileName), model, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(modelPath, labelsFileName), testLabels, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	version := func() string {
+		config, err := ConfigFromPath(modelPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config.ModelVersion
+	}
+
+	writeModel(testModel)
+	first := version()
+	assert.Equal(t, first, version())
+
+	writeModel([]byte("retrained"))
+	retrained := version()
+	assert.NotEqual(t, first, retrained)
+
+	t.Setenv("CONFIDENT_THRESHOLD", "0.95")
+	assert.NotEqual(t, retrained, version())
+}
+
+func Test_ConfigFromPath_with_manifest(t *testing.T) {
+	modelPath := t.TempDir()
+	manifest := &Manifest{
+		Version:               "v2",
+		TargetImageDimensions: 224,
+		TFInputOperationName:  "serving_input",
+		TFOutputOperationName: "serving_output",
+		ModelSHA256:           Checksum(testModel),
+	}
+	if err := WriteFiles(modelPath, manifest, testModel, testLabels); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ConfigFromPath(modelPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", config.ModelVersion)
+	assert.Equal(t, 224, config.TargetImageDimensions)
+	assert.Equal(t, "serving_input", config.TFInputOperationName)
+	assert.Equal(t, "serving_output", config.TFOutputOperationName)
+}
+
+func Test_ConfigFromPath_checksum_mismatch(t *testing.T) {
+	modelPath := t.TempDir()
+	if err := WriteFiles(modelPath, &Manifest{Version: "v2", ModelSHA256: Checksum([]byte("other"))}, testModel, testLabels); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ConfigFromPath(modelPath)
+
+	assert.EqualError(t, err, "model.pb does not match the checksum in manifest.json")
+}
+
+func Test_ConfigFromPath_saved_model(t *testing.T) {
+	modelPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modelPath, "saved_model.pb"), testModel, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelPath, labelsFileName), testLabels, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ConfigFromPath(modelPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, prediction.FormatSavedModel, config.Format)
+	assert.Empty(t, config.Model)
+	assert.Empty(t, config.TFInputOperationName)
+	assert.Empty(t, config.TFOutputOperationName)
+	assert.Equal(t, []string{"serve"}, config.SavedModelTags)
+	assert.Equal(t, "serving_default", config.SavedModelSignature)
+	assert.Contains(t, config.Files(), filepath.Join(modelPath, "variables/variables.index"))
+}
+
+func Test_ConfigFromPath_weights(t *testing.T) {
+	modelPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modelPath, "model.weights"), testModel, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelPath, labelsFileName), testLabels, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ConfigFromPath(modelPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, prediction.FormatWeights, config.Format)
+	assert.Equal(t, testModel, config.Model)
+	assert.Equal(t, []string{filepath.Join(modelPath, "model.weights"), filepath.Join(modelPath, labelsFileName), filepath.Join(modelPath, calibrationFileName)}, config.Files())
+}
+
+func Test_thresholdsFromEnv(t *testing.T) {
+	thresholds, err := thresholdsFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, prediction.DefaultThresholds(), thresholds)
+
+	t.Setenv("CONFIDENT_THRESHOLD", "0.75")
+	t.Setenv("REJECTED_THRESHOLD", "0.55")
+	thresholds, err = thresholdsFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, prediction.Thresholds{Confident: 0.75, Rejected: 0.55}, thresholds)
+
+	t.Setenv("REJECTED_THRESHOLD", "0.8")
+	_, err = thresholdsFromEnv()
+	assert.ErrorContains(t, err, "rejected <= confident")
+
+	t.Setenv("REJECTED_THRESHOLD", "half")
+	_, err = thresholdsFromEnv()
+	assert.Error(t, err)
+}
+
+func Test_ConfigFromPath_calibration(t *testing.T) {
+	modelPath := t.TempDir()
+	if err := WriteFiles(modelPath, &Manifest{Version: "v2"}, testModel, testLabels); err != nil {
+		t.Fatal(err)
+	}
+	calibration := &prediction.Calibration{Method: prediction.CalibrationPlatt, A: 0.5, B: -0.1}
+	if err := WriteCalibration(modelPath, calibration); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := ConfigFromPath(modelPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, calibration, config.Calibration)
+	assert.Contains(t, config.Files(), filepath.Join(modelPath, calibrationFileName))
+}
+
+func Test_ConfigFromPath_invalid_calibration(t *testing.T) {
+	modelPath := t.TempDir()
+	if err := WriteFiles(modelPath, &Manifest{Version: "v2"}, testModel, testLabels); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelPath, calibrationFileName), []byte(`{"method":"temperature","temperature":0}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ConfigFromPath(modelPath)
+
+	assert.ErrorContains(t, err, "invalid calibration")
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+// EnsembleConfig lists the models predicting together with the model in
+// MODEL_PATH
+type EnsembleConfig struct {
+	ModelPaths []string
+	Strategy   prediction.Strategy
+	// Weights of the model in MODEL_PATH followed by the ones in ModelPaths
+	Weights []float32
+}
+
+// Enabled reports whether more than the model in MODEL_PATH is used
+func (c *EnsembleConfig) Enabled() bool {
+	return len(c.ModelPaths) > 0
+}
+
+// EnsembleConfigFromEnv reads the ensemble from ENSEMBLE_MODEL_PATHS,
+// ENSEMBLE_STRATEGY and ENSEMBLE_WEIGHTS
+func EnsembleConfigFromEnv() (*EnsembleConfig, error) {
+	modelPaths := splitList(getEnv("ENSEMBLE_MODEL_PATHS", ""))
+
+	strategy, err := prediction.ParseStrategy(getEnv("ENSEMBLE_STRATEGY", string(prediction.StrategyAverage)))
+	if err != nil {
+		return nil, err
+	}
+
+	var weights []float32
+	for _, value := range splitList(getEnv("ENSEMBLE_WEIGHTS", "")) {
+		weight, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not convert to float, please use correct format")
+		}
+		weights = append(weights, float32(weight))
+	}
+	if len(weights) > 0 && len(weights) != len(modelPaths)+1 {
+		return nil, errors.Errorf("ENSEMBLE_WEIGHTS needs %d weights, one for MODEL_PATH and one per ENSEMBLE_MODEL_PATHS entry, got %d", len(modelPaths)+1, len(weights))
+	}
+
+	return &EnsembleConfig{
+		ModelPaths: modelPaths,
+		Strategy:   strategy,
+		Weights:    weights,
+	}, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
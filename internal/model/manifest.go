@@ -0,0 +1,104 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	manifestFileName = "manifest.json"
+
+	// derivedVersionPrefix marks versions derived from the files of a model
+	// without a manifest
+	derivedVersionPrefix = "local-"
+	derivedVersionLength = 12
+)
+
+// A Manifest describes a version of the model. It is stored next to the model
+// and the labels and takes precedence over the environment.
+type Manifest struct {
+	Version               string            `json:"version"`
+	CreatedAt             time.Time         `json:"createdAt"`
+	TargetImageDimensions int               `json:"targetImageDimensions"`
+	TFInputOperationName  string            `json:"tfInputOperationName"`
+	TFOutputOperationName string            `json:"tfOutputOperationName"`
+	ModelSHA256           string            `json:"modelSha256"`
+	Metadata              map[string]string `json:"metadata,omitempty"`
+}
+
+// Checksum returns the hex encoded sha256 of the model
+func Checksum(model []byte) string {
+	sum := sha256.Sum256(model)
+	return hex.EncodeToString(sum[:])
+}
+
+// derivedVersion names a model without a manifest by the checksum of its
+// files and of the settings that change its results. A replaced model, other
+// labels, calibration or thresholds get another version, so that results
+// stored for the old one are not served as current.
+func derivedVersion(files [][]byte, settings any) (string, error) {
+	settingsBytes, err := json.Marshal(settings)
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal model settings")
+	}
+
+	hash := sha256.New()
+	for _, file := range append(files, settingsBytes) {
+		hash.Write([]byte(Checksum(file)))
+	}
+	return derivedVersionPrefix + hex.EncodeToString(hash.Sum(nil))[:derivedVersionLength], nil
+}
+
+// readManifest returns the manifest in the model path, or nil if there is none
+func readManifest(modelPath string) (*Manifest, error) {
+	manifestBytes, err := os.ReadFile(filepath.Join(modelPath, manifestFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not read manifest")
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal manifest")
+	}
+
+	return &manifest, nil
+}
+
+// WriteFiles writes the model, the labels and the manifest into the model
+// path. Every file is written to a temporary file first and then renamed, so
+// a watching process never reads a partially written file.
+func WriteFiles(modelPath string, manifest *Manifest, model, labels []byte) error {
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not marshal manifest")
+	}
+
+	if err := os.MkdirAll(modelPath, 0o755); err != nil {
+		return errors.Wrap(err, "could not create model path")
+	}
+
+	files := map[string][]byte{
+		modelFileName:    model,
+		labelsFileName:   labels,
+		manifestFileName: manifestBytes,
+	}
+	for _, name := range []string{modelFileName, labelsFileName, manifestFileName} {
+		path := filepath.Join(modelPath, name)
+		if err := os.WriteFile(path+".tmp", files[name], 0o644); err != nil {
+			return errors.Wrapf(err, "could not write %s", name)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return errors.Wrapf(err, "could not write %s", name)
+		}
+	}
+
+	return nil
+}
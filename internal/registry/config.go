@@ -0,0 +1,40 @@
+package registry
+
+import (
+	"os"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+)
+
+type Config struct {
+	ObjectStorage storage.Config
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// ConfigFromEnv reads the object storage of the registry from the
+// environment. The registry uses the bucket of the uploaded images, in the
+// folder set in MODEL_REGISTRY_FOLDER.
+func ConfigFromEnv() (*Config, error) {
+	objectStorageConfig, err := storage.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	objectStorageConfig.ObjectFolder = getEnv("MODEL_REGISTRY_FOLDER", "models/")
+
+	return &Config{
+		ObjectStorage: *objectStorageConfig,
+	}, nil
+}
+
+// ModelVersionFromEnv returns the version set in MODEL_VERSION that the
+// backend and the bot load from the registry at startup. It is empty if the
+// model is read from MODEL_PATH only.
+func ModelVersionFromEnv() string {
+	return getEnv("MODEL_VERSION", "")
+}
@@ -0,0 +1,250 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Production refers to the version currently promoted to production
+	Production = "production"
+
+	indexObjectID       = "index.json"
+	modelObjectName     = "model.pb"
+	labelsObjectName    = "labels.csv"
+	manifestObjectName  = "manifest.json"
+	errorTextReadIndex  = "could not read model index"
+	errorTextWriteIndex = "could not write model index"
+)
+
+var (
+	// ErrVersionNotFound is returned for versions that were never published
+	ErrVersionNotFound = errors.New("model version not found")
+	// ErrVersionExists is returned when publishing a version a second time
+	ErrVersionExists = errors.New("model version already exists")
+	// ErrNoProductionVersion is returned when no version was promoted yet
+	ErrNoProductionVersion = errors.New("no model version was promoted to production")
+	// ErrNoRollback is returned when there is no earlier production version
+	ErrNoRollback = errors.New("there is no earlier production version to roll back to")
+)
+
+// Index lists the published versions and tracks which one is in production
+type Index struct {
+	Versions   []model.Manifest `json:"versions"`
+	Production string           `json:"production,omitempty"`
+	// History of earlier production versions, the most recent one last
+	History []string `json:"history,omitempty"`
+}
+
+// A Bundle is everything needed to serve a version of the model
+type Bundle struct {
+	Manifest model.Manifest
+	Model    []byte
+	Labels   []byte
+}
+
+// Registry stores versions of the model in object storage. Every version
+// lives in its own folder next to the index:
+//
+//	index.json
+//	<version>/model.pb
+//	<version>/labels.csv
+//	<version>/manifest.json
+//
+// The index is updated by reading and writing it, so only one publish,
+// promote or rollback should run at a time.
+type Registry struct {
+	storage dep.StorageReaderWriter
+}
+
+// New creates a registry in the given storage
+func New(storage dep.StorageReaderWriter) *Registry {
+	return &Registry{storage}
+}
+
+// Publish uploads a new version. The checksum of the manifest is computed from
+// the model, the creation time is set if it is missing.
+func (r *Registry) Publish(bundle *Bundle) (*model.Manifest, error) {
+	index, err := r.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := bundle.Manifest
+	if manifest.Version == "" {
+		return nil, errors.New("model version must not be empty")
+	} else if manifest.Version == Production || strings.ContainsAny(manifest.Version, "/\\") {
+		return nil, errors.Errorf("invalid model version %q", manifest.Version)
+	} else if index.find(manifest.Version) != nil {
+		return nil, errors.Wrap(ErrVersionExists, manifest.Version)
+	}
+	if manifest.CreatedAt.IsZero() {
+		manifest.CreatedAt = time.Now().UTC()
+	}
+	manifest.ModelSHA256 = model.Checksum(bundle.Model)
+
+	manifestBytes, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal manifest")
+	}
+
+	// the manifest is written last, a version without one was not published completely
+	for _, object := range []struct {
+		name string
+		data []byte
+	}{
+		{modelObjectName, bundle.Model},
+		{labelsObjectName, bundle.Labels},
+		{manifestObjectName, manifestBytes},
+	} {
+		if err := r.storage.WriteToBucketObject(objectID(manifest.Version, object.name), object.data); err != nil {
+			return nil, errors.Wrapf(err, "could not upload %s", object.name)
+		}
+	}
+
+	index.Versions = append(index.Versions, manifest)
+	if err := r.writeIndex(index); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// Index returns the published versions, oldest first
+func (r *Registry) Index() (*Index, error) {
+	indexBytes, err := r.storage.ReadFromBucketObject(indexObjectID)
+	if isNotFound(err) {
+		return &Index{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, errorTextReadIndex)
+	}
+
+	var index Index
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, errors.Wrap(err, errorTextReadIndex)
+	}
+
+	return &index, nil
+}
+
+// Promote makes the version the production version
+func (r *Registry) Promote(version string) error {
+	index, err := r.Index()
+	if err != nil {
+		return err
+	}
+	if index.find(version) == nil {
+		return errors.Wrap(ErrVersionNotFound, version)
+	}
+	if index.Production == version {
+		return nil
+	}
+
+	if index.Production != "" {
+		index.History = append(index.History, index.Production)
+	}
+	index.Production = version
+
+	return r.writeIndex(index)
+}
+
+// Rollback makes the previous production version the production version
+// again and returns it
+func (r *Registry) Rollback() (string, error) {
+	index, err := r.Index()
+	if err != nil {
+		return "", err
+	}
+	if len(index.History) == 0 {
+		return "", ErrNoRollback
+	}
+
+	index.Production = index.History[len(index.History)-1]
+	index.History = index.History[:len(index.History)-1]
+
+	return index.Production, r.writeIndex(index)
+}
+
+// Bundle downloads a version. Production is resolved to the version that is
+// currently in production.
+func (r *Registry) Bundle(version string) (*Bundle, error) {
+	index, err := r.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	if version == Production {
+		if index.Production == "" {
+			return nil, ErrNoProductionVersion
+		}
+		version = index.Production
+	}
+
+	manifest := index.find(version)
+	if manifest == nil {
+		return nil, errors.Wrap(ErrVersionNotFound, version)
+	}
+
+	modelBytes, err := r.storage.ReadFromBucketObject(objectID(version, modelObjectName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not download %s", modelObjectName)
+	}
+	if checksum := model.Checksum(modelBytes); checksum != manifest.ModelSHA256 {
+		return nil, errors.Errorf("checksum %s of the downloaded model does not match %s of version %s", checksum, manifest.ModelSHA256, version)
+	}
+
+	labelBytes, err := r.storage.ReadFromBucketObject(objectID(version, labelsObjectName))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not download %s", labelsObjectName)
+	}
+
+	return &Bundle{Manifest: *manifest, Model: modelBytes, Labels: labelBytes}, nil
+}
+
+// Download writes a version into the model path, where it is picked up by
+// model.ConfigFromPath
+func (r *Registry) Download(version, modelPath string) (*model.Manifest, error) {
+	bundle, err := r.Bundle(version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := model.WriteFiles(modelPath, &bundle.Manifest, bundle.Model, bundle.Labels); err != nil {
+		return nil, err
+	}
+
+	return &bundle.Manifest, nil
+}
+
+func (r *Registry) writeIndex(index *Index) error {
+	indexBytes, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errorTextWriteIndex)
+	}
+
+	return errors.Wrap(r.storage.WriteToBucketObject(indexObjectID, indexBytes), errorTextWriteIndex)
+}
+
+func (i *Index) find(version string) *model.Manifest {
+	for n := range i.Versions {
+		if i.Versions[n].Version == version {
+			return &i.Versions[n]
+		}
+	}
+	return nil
+}
+
+func objectID(version, name string) string {
+	return fmt.Sprintf("%s/%s", version, name)
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, storage.ErrObjectNotFound)
+}
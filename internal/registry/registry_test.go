@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/internal/model"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryStorage map[string][]byte
+
+func (m memoryStorage) WriteToBucketObject(objectID string, data []byte) error {
+	m[objectID] = data
+	return nil
+}
+
+func (m memoryStorage) ReadFromBucketObject(objectID string) ([]byte, error) {
+	data, ok := m[objectID]
+	if !ok {
+		return nil, errors.Wrap(storage.ErrObjectNotFound, objectID)
+	}
+	return data, nil
+}
+
+func testBundle(version string) *Bundle {
+	return &Bundle{
+		Manifest: model.Manifest{
+			Version:               version,
+			TargetImageDimensions: 256,
+			TFInputOperationName:  "input_1",
+			TFOutputOperationName: "dense_3/Softmax",
+			Metadata:              map[string]string{"epochs": "20"},
+		},
+		Model:  []byte("model " + version),
+		Labels: []byte("index,class_name\n0,cats\n1,non_cats\n"),
+	}
+}
+
+func publish(t *testing.T, registry *Registry, versions ...string) {
+	for _, version := range versions {
+		if _, err := registry.Publish(testBundle(version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_Publish(t *testing.T) {
+	storage := memoryStorage{}
+	registry := New(storage)
+
+	manifest, err := registry.Publish(testBundle("v1"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.Checksum([]byte("model v1")), manifest.ModelSHA256)
+	assert.False(t, manifest.CreatedAt.IsZero())
+	assert.Contains(t, storage, "v1/model.pb")
+	assert.Contains(t, storage, "v1/labels.csv")
+	assert.Contains(t, storage, "v1/manifest.json")
+
+	index, err := registry.Index()
+	assert.NoError(t, err)
+	assert.Len(t, index.Versions, 1)
+	assert.Equal(t, "v1", index.Versions[0].Version)
+	assert.Empty(t, index.Production)
+}
+
+func Test_Publish_existing_version(t *testing.T) {
+	registry := New(memoryStorage{})
+	publish(t, registry, "v1")
+
+	_, err := registry.Publish(testBundle("v1"))
+
+	assert.ErrorIs(t, err, ErrVersionExists)
+}
+
+func Test_Publish_invalid_version(t *testing.T) {
+	registry := New(memoryStorage{})
+
+	for _, version := range []string{"", Production, "v1/../v2"} {
+		_, err := registry.Publish(testBundle(version))
+		assert.Error(t, err, version)
+	}
+}
+
+func Test_Promote_and_Rollback(t *testing.T) {
+	registry := New(memoryStorage{})
+	publish(t, registry, "v1", "v2", "v3")
+
+	assert.NoError(t, registry.Promote("v1"))
+	assert.NoError(t, registry.Promote("v2"))
+	assert.NoError(t, registry.Promote("v3"))
+
+	version, err := registry.Rollback()
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", version)
+
+	version, err = registry.Rollback()
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", version)
+
+	_, err = registry.Rollback()
+	assert.ErrorIs(t, err, ErrNoRollback)
+
+	index, err := registry.Index()
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", index.Production)
+}
+
+func Test_Promote_unknown_version(t *testing.T) {
+	registry := New(memoryStorage{})
+
+	err := registry.Promote("v1")
+
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
+
+func Test_Bundle_production(t *testing.T) {
+	registry := New(memoryStorage{})
+	publish(t, registry, "v1", "v2")
+
+	_, err := registry.Bundle(Production)
+	assert.ErrorIs(t, err, ErrNoProductionVersion)
+
+	assert.NoError(t, registry.Promote("v2"))
+	bundle, err := registry.Bundle(Production)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", bundle.Manifest.Version)
+	assert.Equal(t, []byte("model v2"), bundle.Model)
+}
+
+func Test_Bundle_checksum_mismatch(t *testing.T) {
+	storage := memoryStorage{}
+	registry := New(storage)
+	publish(t, registry, "v1")
+	storage["v1/model.pb"] = []byte("tampered")
+
+	_, err := registry.Bundle("v1")
+
+	assert.ErrorContains(t, err, "does not match")
+}
+
+func Test_Download(t *testing.T) {
+	registry := New(memoryStorage{})
+	publish(t, registry, "v1")
+	modelPath := t.TempDir()
+
+	manifest, err := registry.Download("v1", modelPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", manifest.Version)
+
+	modelBytes, labelBytes, err := model.ReadFiles(modelPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("model v1"), modelBytes)
+	assert.Equal(t, testBundle("v1").Labels, labelBytes)
+}
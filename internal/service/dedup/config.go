@@ -0,0 +1,47 @@
+package dedup
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxDistance is the Hamming distance up to which uploads are
+// considered duplicates unless configured otherwise
+const DefaultMaxDistance = 4
+
+// Config decides whether uploads are deduplicated
+type Config struct {
+	Enabled bool
+	// MaxDistance is the largest number of differing hash bits of two images
+	// that are still considered the same, 0 only matches identical hashes
+	MaxDistance int
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// ConfigFromEnv reads the deduplication settings from the environment
+func ConfigFromEnv() (*Config, error) {
+	enabled, err := strconv.ParseBool(getEnv("DEDUPLICATE_UPLOADS", "false"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse as boolean")
+	}
+	maxDistance, err := strconv.Atoi(getEnv("DEDUPLICATE_MAX_DISTANCE", strconv.Itoa(DefaultMaxDistance)))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert to integer, please use correct format")
+	}
+	if maxDistance < 0 || maxDistance > 64 {
+		return nil, errors.Errorf("DEDUPLICATE_MAX_DISTANCE must be between 0 and 64, got %d", maxDistance)
+	}
+
+	return &Config{
+		Enabled:     enabled,
+		MaxDistance: maxDistance,
+	}, nil
+}
@@ -0,0 +1,32 @@
+package dedup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ConfigFromEnv(t *testing.T) {
+	t.Setenv("DEDUPLICATE_UPLOADS", "true")
+	t.Setenv("DEDUPLICATE_MAX_DISTANCE", "6")
+
+	config, err := ConfigFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{Enabled: true, MaxDistance: 6}, config)
+}
+
+func Test_ConfigFromEnv_defaults(t *testing.T) {
+	config, err := ConfigFromEnv()
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Config{MaxDistance: DefaultMaxDistance}, config)
+}
+
+func Test_ConfigFromEnv_invalid_distance(t *testing.T) {
+	t.Setenv("DEDUPLICATE_MAX_DISTANCE", "65")
+
+	_, err := ConfigFromEnv()
+
+	assert.EqualError(t, err, "DEDUPLICATE_MAX_DISTANCE must be between 0 and 64, got 65")
+}
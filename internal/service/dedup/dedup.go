@@ -0,0 +1,99 @@
+package dedup
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HashSuffix is appended to the ID of an image to name the object its
+	// perceptual hash is stored in
+	HashSuffix = ".phash"
+
+	errorTextCouldNotStoreHash = "could not store perceptual hash"
+)
+
+// A Duplicate is an uploaded image that looks like the one being uploaded
+type Duplicate struct {
+	ID       string
+	Distance int
+}
+
+type entry struct {
+	id   string
+	hash uint64
+}
+
+// Service finds images that were uploaded before by comparing their 64 bit
+// perceptual hashes, images whose hashes differ in at most maxDistance bits
+// count as the same. Every hash is kept as <id>.phash in hex, which Loader
+// reads back. It is safe for concurrent use.
+type Service struct {
+	storage     objects.Storage
+	maxDistance int
+
+	mu      sync.RWMutex
+	entries []entry
+}
+
+// New creates the deduplication service with an empty index
+func New(storage objects.Storage, maxDistance int) *Service {
+	return &Service{
+		storage:     storage,
+		maxDistance: maxDistance,
+	}
+}
+
+// Loader adds the stored hashes to the index
+func (s *Service) Loader() objects.Loader {
+	return objects.Loader{
+		Suffix: HashSuffix,
+		Kind:   "perceptual hashes",
+		Load: func(id string, data []byte) error {
+			hash, err := strconv.ParseUint(string(data), 16, 64)
+			if err != nil {
+				return err
+			}
+			s.add(id, hash)
+			return nil
+		},
+	}
+}
+
+// Find returns the uploaded image whose hash is closest to the given one, if
+// it is within the maximum distance. Of equally close images the first
+// uploaded one is returned.
+func (s *Service) Find(hash uint64) (*Duplicate, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var duplicate *Duplicate
+	for _, e := range s.entries {
+		distance := prediction.HammingDistance(hash, e.hash)
+		if distance <= s.maxDistance && (duplicate == nil || distance < duplicate.Distance) {
+			duplicate = &Duplicate{ID: e.id, Distance: distance}
+		}
+	}
+	return duplicate, duplicate != nil
+}
+
+// Add stores the hash of the uploaded image and adds it to the index
+func (s *Service) Add(id string, hash uint64) error {
+	if err := s.storage.WriteToBucketObject(id+HashSuffix, []byte(strconv.FormatUint(hash, 16))); err != nil {
+		return errors.Wrap(err, errorTextCouldNotStoreHash)
+	}
+	s.add(id, hash)
+
+	return nil
+}
+
+func (s *Service) add(id string, hash uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry{id: id, hash: hash})
+}
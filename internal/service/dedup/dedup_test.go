@@ -0,0 +1,79 @@
+package dedup
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/internal/service/objects/objectstest"
+	"github.com/stretchr/testify/assert"
+)
+
+var errMock = errors.New("everything went to hell")
+
+func Test_Find(t *testing.T) {
+	service := New(objectstest.NewStorage(), 2)
+	assert.NoError(t, service.Add("far", 0b1111))
+	assert.NoError(t, service.Add("close", 0b0011))
+	assert.NoError(t, service.Add("closest", 0b0001))
+
+	duplicate, ok := service.Find(0b0000)
+
+	assert.True(t, ok)
+	assert.Equal(t, &Duplicate{ID: "closest", Distance: 1}, duplicate)
+}
+
+func Test_Find_prefers_first_upload(t *testing.T) {
+	service := New(objectstest.NewStorage(), 0)
+	assert.NoError(t, service.Add("first", 42))
+	assert.NoError(t, service.Add("second", 42))
+
+	duplicate, ok := service.Find(42)
+
+	assert.True(t, ok)
+	assert.Equal(t, "first", duplicate.ID)
+}
+
+func Test_Find_none_within_distance(t *testing.T) {
+	service := New(objectstest.NewStorage(), 1)
+	assert.NoError(t, service.Add("far", 0b0111))
+
+	_, ok := service.Find(0)
+
+	assert.False(t, ok)
+}
+
+func Test_Add_stores_hash(t *testing.T) {
+	storage := objectstest.NewStorage()
+	service := New(storage, 0)
+
+	assert.NoError(t, service.Add("cat", 0xdeadbeef))
+
+	assert.Equal(t, []byte("deadbeef"), storage.Objects["cat"+HashSuffix])
+}
+
+func Test_Add_storage_error(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.WriteErr = errMock
+	service := New(storage, 0)
+
+	assert.ErrorIs(t, service.Add("cat", 1), errMock)
+
+	_, ok := service.Find(1)
+	assert.False(t, ok)
+}
+
+func Test_Load(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["cat"] = []byte{1}
+	storage.Objects["cat"+HashSuffix] = []byte("ff")
+	storage.Objects["broken"+HashSuffix] = []byte("no hash")
+	service := New(storage, 0)
+
+	assert.NoError(t, objects.Load(storage, service.Loader()))
+
+	duplicate, ok := service.Find(0xff)
+	assert.True(t, ok)
+	assert.Equal(t, "cat", duplicate.ID)
+	assert.Len(t, service.entries, 1)
+}
@@ -0,0 +1,271 @@
+package experiment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
+
+const (
+	// ModeAB serves a share of the images with the secondary predictor
+	ModeAB Mode = "ab"
+	// ModeShadow runs the secondary predictor on every image next to the
+	// primary one, only the result of the primary predictor is returned
+	ModeShadow Mode = "shadow"
+
+	shadowTimeout = 30 * time.Second
+)
+
+// Mode decides how the secondary predictor receives traffic
+type Mode string
+
+// ParseMode returns the mode with the given name
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(name); mode {
+	case ModeAB, ModeShadow:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown experiment mode %q, use one of %s or %s", name, ModeAB, ModeShadow)
+}
+
+// Config of an experiment
+type Config struct {
+	Mode Mode
+	// Percentage of images served by the secondary predictor in ModeAB
+	Percentage int
+}
+
+// A ImagePredictor predicts the class of an image
+type ImagePredictor interface {
+	PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error)
+	Stop() error
+}
+
+// VariantStats describe the predictions of one of the predictors
+type VariantStats struct {
+	Requests       uint64        `json:"requests"`
+	Errors         uint64        `json:"errors"`
+	AverageLatency time.Duration `json:"averageLatency"`
+}
+
+// Stats compare the two predictors. Agreements are only counted in
+// ModeShadow, where both predictors see the same images.
+type Stats struct {
+	Mode                     Mode          `json:"mode"`
+	Percentage               int           `json:"percentage,omitempty"`
+	Primary                  VariantStats  `json:"primary"`
+	Secondary                VariantStats  `json:"secondary"`
+	Compared                 uint64        `json:"compared"`
+	Agreements               uint64        `json:"agreements"`
+	AgreementRate            float64       `json:"agreementRate"`
+	AverageLatencyDifference time.Duration `json:"averageLatencyDifference"`
+}
+
+type variant struct {
+	predictor    ImagePredictor
+	requests     uint64
+	errors       uint64
+	totalLatency time.Duration
+}
+
+// Predictor serves a secondary image predictor next to the primary one
+type Predictor struct {
+	config    Config
+	primary   *variant
+	secondary *variant
+	shadows   sync.WaitGroup
+
+	mu         sync.Mutex
+	compared   uint64
+	agreements uint64
+}
+
+// New creates a predictor running the experiment
+func New(primary, secondary ImagePredictor, config Config) *Predictor {
+	return &Predictor{
+		config:    config,
+		primary:   &variant{predictor: primary},
+		secondary: &variant{predictor: secondary},
+	}
+}
+
+type imageIDKey struct{}
+
+// ContextWithImageID returns a context carrying the id of the image to
+// predict. In ModeAB an image is always served by the same predictor.
+func ContextWithImageID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, imageIDKey{}, id)
+}
+
+// PredictImage with the predictor chosen by the experiment
+func (p *Predictor) PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error) {
+	switch p.config.Mode {
+	case ModeAB:
+		if bucket(ctx, imageBytes) < p.config.Percentage {
+			return p.predict(ctx, p.secondary, imageBytes)
+		}
+		return p.predict(ctx, p.primary, imageBytes)
+	case ModeShadow:
+		return p.predictWithShadow(ctx, imageBytes)
+	}
+
+	return p.predict(ctx, p.primary, imageBytes)
+}
+
+// ModelVersion of the predictor the experiment chooses for the image with the
+// id in the context, empty if it does not tell its version
+func (p *Predictor) ModelVersion(ctx context.Context) string {
+	v := p.primary
+	if p.config.Mode == ModeAB && bucket(ctx, nil) < p.config.Percentage {
+		v = p.secondary
+	}
+
+	if versioned, ok := v.predictor.(prediction.Versioned); ok {
+		return versioned.ModelVersion(ctx)
+	}
+	return ""
+}
+
+// ExplainImage with the primary predictor, explanations are not part of the
+// experiment
+func (p *Predictor) ExplainImage(ctx context.Context, imageBytes []byte) (*prediction.Explanation, error) {
+	explainer, ok := p.primary.predictor.(prediction.Explainer)
+	if !ok {
+		return nil, prediction.ErrExplanationUnsupported
+	}
+	return explainer.ExplainImage(ctx, imageBytes)
+}
+
+// EmbedImage with the primary predictor, so that all embeddings are
+// comparable with each other
+func (p *Predictor) EmbedImage(ctx context.Context, imageBytes []byte) (*prediction.Embedding, error) {
+	embedder, ok := p.primary.predictor.(prediction.Embedder)
+	if !ok {
+		return nil, prediction.ErrEmbeddingUnsupported
+	}
+	return embedder.EmbedImage(ctx, imageBytes)
+}
+
+func (p *Predictor) predictWithShadow(ctx context.Context, imageBytes []byte) (*prediction.Result, error) {
+	shadowResult := make(chan *prediction.Result, 1)
+
+	// the shadow prediction must neither delay nor be canceled with the request
+	shadowCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+	p.shadows.Add(1)
+	go func() {
+		defer p.shadows.Done()
+		defer cancel()
+		result, _ := p.predict(shadowCtx, p.secondary, imageBytes)
+		shadowResult <- result
+	}()
+
+	result, err := p.predict(ctx, p.primary, imageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	p.shadows.Add(1)
+	go func() {
+		defer p.shadows.Done()
+		p.compare(ctx, result, <-shadowResult)
+	}()
+
+	return result, nil
+}
+
+func (p *Predictor) predict(ctx context.Context, v *variant, imageBytes []byte) (*prediction.Result, error) {
+	started := time.Now()
+	result, err := v.predictor.PredictImage(ctx, imageBytes)
+	latency := time.Since(started)
+
+	p.mu.Lock()
+	v.requests++
+	v.totalLatency += latency
+	if err != nil {
+		v.errors++
+	}
+	p.mu.Unlock()
+
+	return result, err
+}
+
+func (p *Predictor) compare(ctx context.Context, primary, secondary *prediction.Result) {
+	if secondary == nil {
+		return
+	}
+
+	agree := primary.Class == secondary.Class
+
+	p.mu.Lock()
+	p.compared++
+	if agree {
+		p.agreements++
+	}
+	p.mu.Unlock()
+
+	if !agree {
+		id, _ := ctx.Value(imageIDKey{}).(string)
+		log.Printf("shadow prediction disagrees for image %q: primary=[%s %.4f] secondary=[%s %.4f]\n",
+			id, primary.Class, primary.Probability, secondary.Class, secondary.Probability)
+	}
+}
+
+// Stats returns the comparison of the two predictors so far
+func (p *Predictor) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := Stats{
+		Mode:       p.config.Mode,
+		Primary:    p.primary.stats(),
+		Secondary:  p.secondary.stats(),
+		Compared:   p.compared,
+		Agreements: p.agreements,
+	}
+	if p.config.Mode == ModeAB {
+		stats.Percentage = p.config.Percentage
+	}
+	if p.compared > 0 {
+		stats.AgreementRate = float64(p.agreements) / float64(p.compared)
+	}
+	if stats.Primary.Requests > 0 && stats.Secondary.Requests > 0 {
+		stats.AverageLatencyDifference = stats.Secondary.AverageLatency - stats.Primary.AverageLatency
+	}
+
+	return stats
+}
+
+func (v *variant) stats() VariantStats {
+	stats := VariantStats{Requests: v.requests, Errors: v.errors}
+	if v.requests > 0 {
+		stats.AverageLatency = v.totalLatency / time.Duration(v.requests)
+	}
+	return stats
+}
+
+// Stop waits for running shadow predictions and stops both predictors
+func (p *Predictor) Stop() error {
+	p.shadows.Wait()
+	return errors.Join(p.primary.predictor.Stop(), p.secondary.predictor.Stop())
+}
+
+// bucket assigns the image to one of 100 buckets by its id, or by its content
+// if the id is unknown
+func bucket(ctx context.Context, imageBytes []byte) int {
+	var sum [sha256.Size]byte
+	if id, ok := ctx.Value(imageIDKey{}).(string); ok && id != "" {
+		sum = sha256.Sum256([]byte(id))
+	} else {
+		sum = sha256.Sum256(imageBytes)
+	}
+
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
@@ -0,0 +1,202 @@
+package experiment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+var errMock = errors.New("everything went to hell")
+
+type fakePredictor struct {
+	class string
+	err   error
+	calls atomic.Int32
+}
+
+func (f *fakePredictor) PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error) {
+	f.calls.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &prediction.Result{Class: f.class}, nil
+}
+
+func (f *fakePredictor) Stop() error {
+	return nil
+}
+
+func Test_ParseMode(t *testing.T) {
+	mode, err := ParseMode("shadow")
+	assert.NoError(t, err)
+	assert.Equal(t, ModeShadow, mode)
+
+	_, err = ParseMode("canary")
+	assert.EqualError(t, err, `unknown experiment mode "canary", use one of ab or shadow`)
+}
+
+func Test_PredictImage_ab_is_sticky_by_image_id(t *testing.T) {
+	primary, secondary := &fakePredictor{class: "cats"}, &fakePredictor{class: "non_cats"}
+	predictor := New(primary, secondary, Config{Mode: ModeAB, Percentage: 30})
+
+	servedBy := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("image-%d", i%100)
+		result, err := predictor.PredictImage(ContextWithImageID(context.Background(), id), []byte{byte(i)})
+		assert.NoError(t, err)
+
+		if previous, ok := servedBy[id]; ok {
+			assert.Equal(t, previous, result.Class, id)
+		}
+		servedBy[id] = result.Class
+	}
+
+	stats := predictor.Stats()
+	assert.Equal(t, uint64(1000), stats.Primary.Requests+stats.Secondary.Requests)
+	assert.InDelta(t, 300, stats.Secondary.Requests, 150)
+	assert.Equal(t, 30, stats.Percentage)
+	assert.Zero(t, stats.Compared)
+}
+
+func Test_PredictImage_ab_percentage_bounds(t *testing.T) {
+	for _, percentage := range []int{0, 100} {
+		primary, secondary := &fakePredictor{class: "cats"}, &fakePredictor{class: "non_cats"}
+		predictor := New(primary, secondary, Config{Mode: ModeAB, Percentage: percentage})
+
+		for i := 0; i < 50; i++ {
+			_, _ = predictor.PredictImage(context.Background(), []byte(fmt.Sprint(i)))
+		}
+
+		assert.Equal(t, int32(50*percentage/100), secondary.calls.Load())
+		assert.Equal(t, int32(50-50*percentage/100), primary.calls.Load())
+	}
+}
+
+func Test_PredictImage_shadow(t *testing.T) {
+	primary, secondary := &fakePredictor{class: "cats"}, &fakePredictor{class: "cats"}
+	predictor := New(primary, secondary, Config{Mode: ModeShadow})
+
+	result, err := predictor.PredictImage(context.Background(), []byte{1})
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", result.Class)
+
+	predictor.shadows.Wait()
+	secondary.class = "non_cats"
+	_, _ = predictor.PredictImage(context.Background(), []byte{2})
+	assert.NoError(t, predictor.Stop())
+
+	stats := predictor.Stats()
+	assert.Equal(t, uint64(2), stats.Primary.Requests)
+	assert.Equal(t, uint64(2), stats.Secondary.Requests)
+	assert.Equal(t, uint64(2), stats.Compared)
+	assert.Equal(t, uint64(1), stats.Agreements)
+	assert.Equal(t, 0.5, stats.AgreementRate)
+}
+
+func Test_PredictImage_shadow_error_does_not_affect_response(t *testing.T) {
+	primary, secondary := &fakePredictor{class: "cats"}, &fakePredictor{err: errMock}
+	predictor := New(primary, secondary, Config{Mode: ModeShadow})
+
+	result, err := predictor.PredictImage(context.Background(), []byte{1})
+	assert.NoError(t, predictor.Stop())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", result.Class)
+	stats := predictor.Stats()
+	assert.Equal(t, uint64(1), stats.Secondary.Errors)
+	assert.Zero(t, stats.Compared)
+}
+
+func Test_PredictImage_shadow_primary_error(t *testing.T) {
+	primary, secondary := &fakePredictor{err: errMock}, &fakePredictor{class: "cats"}
+	predictor := New(primary, secondary, Config{Mode: ModeShadow})
+
+	_, err := predictor.PredictImage(context.Background(), []byte{1})
+	assert.NoError(t, predictor.Stop())
+
+	assert.ErrorIs(t, err, errMock)
+	assert.Zero(t, predictor.Stats().Compared)
+}
+
+type fakeExplainer struct {
+	fakePredictor
+}
+
+func (f *fakeExplainer) ExplainImage(ctx context.Context, imageBytes []byte) (*prediction.Explanation, error) {
+	return &prediction.Explanation{Result: &prediction.Result{Class: f.class}}, nil
+}
+
+func Test_ExplainImage_uses_primary(t *testing.T) {
+	primary := &fakeExplainer{fakePredictor{class: "cats"}}
+	secondary := &fakeExplainer{fakePredictor{class: "non_cats"}}
+	predictor := New(primary, secondary, Config{Mode: ModeAB, Percentage: 100})
+
+	explanation, err := predictor.ExplainImage(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", explanation.Result.Class)
+
+	predictor = New(&fakePredictor{}, secondary, Config{Mode: ModeShadow})
+	_, err = predictor.ExplainImage(context.Background(), nil)
+	assert.ErrorIs(t, err, prediction.ErrExplanationUnsupported)
+}
+
+type fakeEmbedder struct {
+	fakePredictor
+	embedding []float32
+}
+
+func (f *fakeEmbedder) EmbedImage(ctx context.Context, imageBytes []byte) (*prediction.Embedding, error) {
+	return &prediction.Embedding{Vector: f.embedding}, nil
+}
+
+func Test_EmbedImage_uses_primary(t *testing.T) {
+	primary := &fakeEmbedder{embedding: []float32{1, 0}}
+	secondary := &fakeEmbedder{embedding: []float32{0, 1}}
+	predictor := New(primary, secondary, Config{Mode: ModeAB, Percentage: 100})
+
+	embedding, err := predictor.EmbedImage(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, 0}, embedding.Vector)
+
+	predictor = New(&fakePredictor{}, secondary, Config{Mode: ModeShadow})
+	_, err = predictor.EmbedImage(context.Background(), nil)
+	assert.ErrorIs(t, err, prediction.ErrEmbeddingUnsupported)
+}
+
+type fakeVersioned struct {
+	fakePredictor
+	version string
+}
+
+func (f *fakeVersioned) PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error) {
+	result, err := f.fakePredictor.PredictImage(ctx, imageBytes)
+	if err == nil {
+		result.ModelVersion = f.version
+	}
+	return result, err
+}
+
+func (f *fakeVersioned) ModelVersion(context.Context) string {
+	return f.version
+}
+
+func Test_ModelVersion_matches_predicting_model(t *testing.T) {
+	primary, secondary := &fakeVersioned{version: "v1"}, &fakeVersioned{version: "v2"}
+	predictor := New(primary, secondary, Config{Mode: ModeAB, Percentage: 50})
+
+	for i := 0; i < 20; i++ {
+		ctx := ContextWithImageID(context.Background(), fmt.Sprintf("image-%d", i))
+		result, err := predictor.PredictImage(ctx, nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, result.ModelVersion, predictor.ModelVersion(ctx))
+		}
+	}
+
+	shadow := New(primary, secondary, Config{Mode: ModeShadow})
+	assert.Equal(t, "v1", shadow.ModelVersion(context.Background()))
+}
@@ -0,0 +1,183 @@
+package feedback
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FeedbackSuffix is appended to the ID of an image to name the object its
+	// feedback is stored in
+	FeedbackSuffix = ".feedback"
+
+	// SourceAPI is feedback sent to the feedback endpoint
+	SourceAPI = "api"
+	// SourceTelegram is feedback given with the buttons of the bot
+	SourceTelegram = "telegram"
+
+	errorTextCouldNotStoreFeedback  = "could not store feedback"
+	errorTextCouldNotEncodeFeedback = "could not encode feedback"
+)
+
+// ErrUnknownClass is returned for feedback naming a class that is not one of
+// the labels of the model
+var ErrUnknownClass = errors.New("unknown class")
+
+// Feedback tells which class an image really shows
+type Feedback struct {
+	ImageID        string    `json:"imageId"`
+	PredictedClass string    `json:"predictedClass"`
+	CorrectClass   string    `json:"correctClass"`
+	ModelVersion   string    `json:"modelVersion,omitempty"`
+	Source         string    `json:"source"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// Correct reports whether the prediction was right
+func (f *Feedback) Correct() bool {
+	return f.PredictedClass == f.CorrectClass
+}
+
+// VersionStats summarize the feedback on the predictions of a model version
+type VersionStats struct {
+	ModelVersion string  `json:"modelVersion"`
+	Total        int     `json:"total"`
+	Correct      int     `json:"correct"`
+	Wrong        int     `json:"wrong"`
+	Accuracy     float64 `json:"accuracy"`
+	// Corrections counts the wrong predictions by predicted and correct class
+	Corrections map[string]map[string]int `json:"corrections"`
+}
+
+// Service stores feedback next to the image and keeps the latest feedback of
+// every image in memory for the stats. It is safe for concurrent use.
+type Service struct {
+	storage objects.Storage
+	classes []string
+
+	mu       sync.RWMutex
+	feedback map[string]*Feedback
+}
+
+// New creates the feedback service accepting the classes of the labels
+func New(storage objects.Storage, labels []prediction.Label) *Service {
+	classes := make([]string, 0, len(labels))
+	for _, label := range labels {
+		classes = append(classes, label.ClassName)
+	}
+
+	return &Service{
+		storage:  storage,
+		classes:  classes,
+		feedback: make(map[string]*Feedback),
+	}
+}
+
+// Classes returns the classes feedback may name
+func (s *Service) Classes() []string {
+	return s.classes
+}
+
+// Loader adds the feedback stored earlier. Feedback recorded while loading
+// is kept.
+func (s *Service) Loader() objects.Loader {
+	return objects.Loader{
+		Suffix: FeedbackSuffix,
+		Kind:   "feedback",
+		Load: func(id string, data []byte) error {
+			feedback := &Feedback{}
+			if err := json.Unmarshal(data, feedback); err != nil {
+				return err
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if _, ok := s.feedback[feedback.ImageID]; !ok {
+				s.feedback[feedback.ImageID] = feedback
+			}
+			return nil
+		},
+	}
+}
+
+// Record stores the feedback next to the image, replacing earlier feedback on
+// the same image. The correct class has to be one of the labels.
+func (s *Service) Record(feedback *Feedback) error {
+	if err := s.ValidateClass(feedback.CorrectClass); err != nil {
+		return err
+	}
+	if feedback.CreatedAt.IsZero() {
+		feedback.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(feedback)
+	if err != nil {
+		return errors.Wrap(err, errorTextCouldNotEncodeFeedback)
+	}
+	if err := s.storage.WriteToBucketObject(feedback.ImageID+FeedbackSuffix, data); err != nil {
+		return errors.Wrap(err, errorTextCouldNotStoreFeedback)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feedback[feedback.ImageID] = feedback
+	return nil
+}
+
+// ValidateClass fails with ErrUnknownClass when the class is not one of the
+// labels
+func (s *Service) ValidateClass(class string) error {
+	for _, c := range s.classes {
+		if c == class {
+			return nil
+		}
+	}
+	return errors.Wrap(ErrUnknownClass, fmt.Sprintf("%q is not one of %s", class, strings.Join(s.classes, ", ")))
+}
+
+// Stats returns the stats of every model version that received feedback,
+// ordered by version
+func (s *Service) Stats() []VersionStats {
+	s.mu.RLock()
+	byVersion := make(map[string]*VersionStats)
+	for _, feedback := range s.feedback {
+		stats, ok := byVersion[feedback.ModelVersion]
+		if !ok {
+			stats = &VersionStats{ModelVersion: feedback.ModelVersion, Corrections: make(map[string]map[string]int)}
+			byVersion[feedback.ModelVersion] = stats
+		}
+
+		stats.Total++
+		if feedback.Correct() {
+			stats.Correct++
+			continue
+		}
+		stats.Wrong++
+		if stats.Corrections[feedback.PredictedClass] == nil {
+			stats.Corrections[feedback.PredictedClass] = make(map[string]int)
+		}
+		stats.Corrections[feedback.PredictedClass][feedback.CorrectClass]++
+	}
+	s.mu.RUnlock()
+
+	versionStats := make([]VersionStats, 0, len(byVersion))
+	for _, stats := range byVersion {
+		stats.Accuracy = float64(stats.Correct) / float64(stats.Total)
+		versionStats = append(versionStats, *stats)
+	}
+	sort.Slice(versionStats, func(i, j int) bool {
+		return versionStats[i].ModelVersion < versionStats[j].ModelVersion
+	})
+
+	return versionStats
+}
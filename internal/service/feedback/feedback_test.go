@@ -0,0 +1,101 @@
+package feedback
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/internal/service/objects/objectstest"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+var errMock = errors.New("everything went to hell")
+
+var labels = []prediction.Label{{Index: 0, ClassName: "cats"}, {Index: 1, ClassName: "non_cats"}}
+
+func Test_Record(t *testing.T) {
+	storage := objectstest.NewStorage()
+	service := New(storage, labels)
+
+	err := service.Record(&Feedback{ImageID: "123", PredictedClass: "cats", CorrectClass: "non_cats", ModelVersion: "v1", Source: SourceAPI})
+
+	assert.NoError(t, err)
+	assert.Contains(t, storage.Objects, "123"+FeedbackSuffix)
+	assert.Contains(t, string(storage.Objects["123"+FeedbackSuffix]), `"correctClass":"non_cats"`)
+}
+
+func Test_Record_unknown_class(t *testing.T) {
+	storage := objectstest.NewStorage()
+	service := New(storage, labels)
+
+	err := service.Record(&Feedback{ImageID: "123", PredictedClass: "cats", CorrectClass: "dogs"})
+
+	assert.ErrorIs(t, err, ErrUnknownClass)
+	assert.EqualError(t, err, `"dogs" is not one of cats, non_cats: unknown class`)
+	assert.Empty(t, storage.Objects)
+}
+
+func Test_Record_storage_error(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.WriteErr = errMock
+	service := New(storage, labels)
+
+	err := service.Record(&Feedback{ImageID: "123", PredictedClass: "cats", CorrectClass: "cats"})
+
+	assert.ErrorIs(t, err, errMock)
+	assert.Empty(t, service.Stats())
+}
+
+func Test_Stats(t *testing.T) {
+	service := New(objectstest.NewStorage(), labels)
+	for _, feedback := range []*Feedback{
+		{ImageID: "1", PredictedClass: "cats", CorrectClass: "cats", ModelVersion: "v2"},
+		{ImageID: "2", PredictedClass: "cats", CorrectClass: "cats", ModelVersion: "v1"},
+		{ImageID: "3", PredictedClass: "cats", CorrectClass: "non_cats", ModelVersion: "v1"},
+		{ImageID: "4", PredictedClass: "non_cats", CorrectClass: "non_cats", ModelVersion: "v1"},
+		// replaces the earlier feedback on the same image
+		{ImageID: "4", PredictedClass: "non_cats", CorrectClass: "cats", ModelVersion: "v1"},
+	} {
+		assert.NoError(t, service.Record(feedback))
+	}
+
+	stats := service.Stats()
+
+	assert.Equal(t, []VersionStats{
+		{
+			ModelVersion: "v1",
+			Total:        3,
+			Correct:      1,
+			Wrong:        2,
+			Accuracy:     1.0 / 3,
+			Corrections: map[string]map[string]int{
+				"cats":     {"non_cats": 1},
+				"non_cats": {"cats": 1},
+			},
+		},
+		{
+			ModelVersion: "v2",
+			Total:        1,
+			Correct:      1,
+			Accuracy:     1,
+			Corrections:  map[string]map[string]int{},
+		},
+	}, stats)
+}
+
+func Test_Load(t *testing.T) {
+	storage := objectstest.NewStorage()
+	stored := New(storage, labels)
+	assert.NoError(t, stored.Record(&Feedback{ImageID: "1", PredictedClass: "cats", CorrectClass: "non_cats", ModelVersion: "v1", CreatedAt: time.Now()}))
+	storage.Objects["broken"+FeedbackSuffix] = []byte("{")
+
+	service := New(storage, labels)
+	assert.NoError(t, objects.Load(storage, service.Loader()))
+
+	stats := service.Stats()
+	if assert.Len(t, stats, 1) {
+		assert.Equal(t, 1, stats[0].Wrong)
+	}
+}
@@ -0,0 +1,78 @@
+package objects
+
+import (
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errorTextCouldNotListObjects = "could not list stored objects"
+	errorTextCouldNotLoadObject  = "could not load %s of image %s: %v\n"
+)
+
+// Storage holds the uploaded images and the objects stored next to them, named
+// by the ID of the image and a suffix per kind of object
+type Storage interface {
+	WriteToBucketObject(objectID string, data []byte) error
+	Reader
+}
+
+// Reader reads the stored objects
+type Reader interface {
+	ReadFromBucketObject(objectID string) ([]byte, error)
+	ListBucketObjects(suffix string) ([]string, error)
+}
+
+// Loader loads the stored objects of one kind
+type Loader struct {
+	// Suffix is appended to the ID of an image to name its object
+	Suffix string
+	// Kind names the objects in log messages
+	Kind string
+	// Load is called with the ID of the image and the data of every object
+	// with the suffix
+	Load func(id string, data []byte) error
+}
+
+// Load lists the stored objects once and passes every object to the loader
+// of its suffix. Objects that cannot be read or loaded are logged and
+// skipped.
+func Load(storage Reader, loaders ...Loader) error {
+	suffix := ""
+	if len(loaders) == 1 {
+		suffix = loaders[0].Suffix
+	}
+
+	objectIDs, err := storage.ListBucketObjects(suffix)
+	if err != nil {
+		return errors.Wrap(err, errorTextCouldNotListObjects)
+	}
+
+	loaded := make([]int, len(loaders))
+	for _, objectID := range objectIDs {
+		for i, loader := range loaders {
+			id, ok := strings.CutSuffix(objectID, loader.Suffix)
+			if !ok {
+				continue
+			}
+
+			data, err := storage.ReadFromBucketObject(objectID)
+			if err == nil {
+				err = loader.Load(id, data)
+			}
+			if err != nil {
+				log.Printf(errorTextCouldNotLoadObject, loader.Kind, id, err)
+				break
+			}
+			loaded[i]++
+			break
+		}
+	}
+
+	for i, loader := range loaders {
+		log.Printf("Loaded %d stored %s\n", loaded[i], loader.Kind)
+	}
+	return nil
+}
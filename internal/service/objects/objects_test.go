@@ -0,0 +1,61 @@
+package objects
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects/objectstest"
+	"github.com/stretchr/testify/assert"
+)
+
+var errMock = errors.New("everything went to hell")
+
+// listSpy records the suffixes the objects are listed by
+type listSpy struct {
+	*objectstest.Storage
+	listed []string
+}
+
+func (l *listSpy) ListBucketObjects(suffix string) ([]string, error) {
+	l.listed = append(l.listed, suffix)
+	return l.Storage.ListBucketObjects(suffix)
+}
+
+func Test_Load(t *testing.T) {
+	storage := &listSpy{Storage: objectstest.NewStorage()}
+	storage.Objects["a"] = []byte("image")
+	storage.Objects["a.x"] = []byte("1")
+	storage.Objects["b.x"] = []byte("bad")
+	storage.Objects["a.y"] = []byte("2")
+
+	loaded := make(map[string]string)
+	loader := func(suffix string) Loader {
+		return Loader{Suffix: suffix, Kind: suffix, Load: func(id string, data []byte) error {
+			if string(data) == "bad" {
+				return errMock
+			}
+			loaded[id+suffix] = string(data)
+			return nil
+		}}
+	}
+
+	assert.NoError(t, Load(storage, loader(".x"), loader(".y")))
+
+	assert.Equal(t, map[string]string{"a.x": "1", "a.y": "2"}, loaded)
+	assert.Equal(t, []string{""}, storage.listed)
+}
+
+func Test_Load_single_kind_lists_by_suffix(t *testing.T) {
+	storage := &listSpy{Storage: objectstest.NewStorage()}
+	storage.Objects["a"] = []byte("image")
+	storage.Objects["a.x"] = []byte("1")
+
+	var ids []string
+	assert.NoError(t, Load(storage, Loader{Suffix: ".x", Load: func(id string, data []byte) error {
+		ids = append(ids, id)
+		return nil
+	}}))
+
+	assert.Equal(t, []string{"a"}, ids)
+	assert.Equal(t, []string{".x"}, storage.listed)
+}
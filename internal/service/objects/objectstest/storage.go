@@ -0,0 +1,63 @@
+package objectstest
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/pkg/errors"
+)
+
+// Storage keeps the objects in memory. Reading a missing object fails with
+// storage.ErrObjectNotFound like the object storage service. It is safe for
+// concurrent use, Objects may only be accessed directly while no method runs.
+type Storage struct {
+	mu sync.Mutex
+	// Objects by their ID
+	Objects map[string][]byte
+	// WriteErr is returned by every write if set
+	WriteErr error
+}
+
+// NewStorage creates an empty storage
+func NewStorage() *Storage {
+	return &Storage{Objects: make(map[string][]byte)}
+}
+
+// WriteToBucketObject stores the object under the given ID
+func (s *Storage) WriteToBucketObject(objectID string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.WriteErr != nil {
+		return s.WriteErr
+	}
+	s.Objects[objectID] = data
+	return nil
+}
+
+// ReadFromBucketObject returns the object with the given ID
+func (s *Storage) ReadFromBucketObject(objectID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.Objects[objectID]
+	if !ok {
+		return nil, errors.Wrap(storage.ErrObjectNotFound, objectID)
+	}
+	return data, nil
+}
+
+// ListBucketObjects returns the IDs of the objects ending with suffix
+func (s *Storage) ListBucketObjects(suffix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var objectIDs []string
+	for objectID := range s.Objects {
+		if strings.HasSuffix(objectID, suffix) {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+	return objectIDs, nil
+}
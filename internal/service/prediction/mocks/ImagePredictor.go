@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	predict "github.com/pdstuber/isit-a-cat/pkg/prediction"
 	mock "github.com/stretchr/testify/mock"
 )
@@ -12,9 +14,9 @@ type ImagePredictor struct {
 	mock.Mock
 }
 
-// PredictImage provides a mock function with given fields: imageBytes
-func (_m *ImagePredictor) PredictImage(imageBytes []byte) (*predict.Result, error) {
-	ret := _m.Called(imageBytes)
+// PredictImage provides a mock function with given fields: ctx, imageBytes
+func (_m *ImagePredictor) PredictImage(ctx context.Context, imageBytes []byte) (*predict.Result, error) {
+	ret := _m.Called(ctx, imageBytes)
 
 	if len(ret) == 0 {
 		panic("no return value specified for PredictImage")
@@ -22,19 +24,19 @@ func (_m *ImagePredictor) PredictImage(imageBytes []byte) (*predict.Result, erro
 
 	var r0 *predict.Result
 	var r1 error
-	if rf, ok := ret.Get(0).(func([]byte) (*predict.Result, error)); ok {
-		return rf(imageBytes)
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) (*predict.Result, error)); ok {
+		return rf(ctx, imageBytes)
 	}
-	if rf, ok := ret.Get(0).(func([]byte) *predict.Result); ok {
-		r0 = rf(imageBytes)
+	if rf, ok := ret.Get(0).(func(context.Context, []byte) *predict.Result); ok {
+		r0 = rf(ctx, imageBytes)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*predict.Result)
 		}
 	}
 
-	if rf, ok := ret.Get(1).(func([]byte) error); ok {
-		r1 = rf(imageBytes)
+	if rf, ok := ret.Get(1).(func(context.Context, []byte) error); ok {
+		r1 = rf(ctx, imageBytes)
 	} else {
 		r1 = ret.Error(1)
 	}
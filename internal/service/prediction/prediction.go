@@ -1,7 +1,14 @@
 package prediction
 
 import (
+	"context"
+	"log"
+	"time"
+
 	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/internal/service/experiment"
+	"github.com/pdstuber/isit-a-cat/internal/service/feedback"
+	"github.com/pdstuber/isit-a-cat/internal/service/predictionstore"
 	"github.com/pdstuber/isit-a-cat/pkg/prediction"
 	"github.com/pkg/errors"
 )
@@ -11,24 +18,151 @@ const (
 	errorTextCouldNotUnmarshalIncomingMessage = "could not unmarshal incoming message"
 	errorTextCouldNotFetchImageFromStorage    = "could not fetch image from object storage"
 	errorTextCouldNotMakePredictionOnImage    = "could not make prediction on image"
+	errorTextCouldNotExplainImage             = "could not explain prediction on image"
+	errorTextCouldNotRecordFeedback           = "could not record feedback on prediction"
 )
 
 type serviceDependencies interface {
 	dep.HasStorageReader
 	dep.HasImagePredictor
+	dep.HasPredictionStore
+}
+
+type feedbackDependencies interface {
+	serviceDependencies
+	dep.HasFeedback
+}
+
+type imageDependencies interface {
+	dep.HasImagePredictor
+}
+
+type storeDependencies interface {
+	dep.HasImagePredictor
+	dep.HasPredictionStore
+}
+
+// StoredPrediction returns the stored prediction of the image with the given
+// id, if the model that would predict the image now made it. Predictions of
+// other model versions, e.g. from before a reload or of the other model of an
+// experiment, are not returned.
+func StoredPrediction(ctx context.Context, deps storeDependencies, id string) (*predictionstore.Record, bool) {
+	store := deps.PredictionStore()
+	if store == nil {
+		return nil, false
+	}
+
+	record, err := store.Get(id)
+	if err != nil {
+		if !errors.Is(err, predictionstore.ErrNotFound) {
+			log.Printf("Could not look up stored prediction of image %s: %v\n", id, err)
+		}
+		return nil, false
+	}
+
+	modelVersion := ""
+	if versioned, ok := deps.ImagePredictor().(prediction.Versioned); ok {
+		modelVersion = versioned.ModelVersion(experiment.ContextWithImageID(ctx, id))
+	}
+	if record.ModelVersion != modelVersion {
+		return nil, false
+	}
+
+	return record, true
 }
 
-func CalculatePrediction(deps serviceDependencies, id string) (*prediction.Result, error) {
+// CalculatePrediction for the image stored under the given id. When a
+// prediction store is configured, a stored prediction of the current model
+// version is returned without running the model and new predictions are
+// recorded, replacing those of other versions.
+func CalculatePrediction(ctx context.Context, deps serviceDependencies, id string) (*prediction.Result, error) {
+	if record, ok := StoredPrediction(ctx, deps, id); ok {
+		return record.Result, nil
+	}
+
 	image, err := deps.StorageReader().ReadFromBucketObject(id)
 
 	if err != nil {
 		return nil, errors.Wrap(err, errorTextCouldNotFetchImageFromStorage)
 	}
 
-	result, err := deps.ImagePredictor().PredictImage(image)
+	start := time.Now()
+	result, err := CalculatePredictionForImage(experiment.ContextWithImageID(ctx, id), deps, image)
+	if err != nil {
+		return nil, err
+	}
+
+	if store := deps.PredictionStore(); store != nil {
+		if err := store.Put(&predictionstore.Record{
+			ImageID:   id,
+			Result:    result,
+			Latency:   time.Since(start),
+			CreatedAt: time.Now(),
+		}); err != nil {
+			log.Printf("Could not store prediction of image %s: %v\n", id, err)
+		}
+	}
+
+	return result, nil
+}
+
+// CalculatePredictionForImage for an image that is not kept in object storage
+func CalculatePredictionForImage(ctx context.Context, deps imageDependencies, image []byte) (*prediction.Result, error) {
+	result, err := deps.ImagePredictor().PredictImage(ctx, image)
 	if err != nil {
 		return nil, errors.Wrap(err, errorTextCouldNotMakePredictionOnImage)
 	}
 
 	return result, nil
 }
+
+// ExplainPrediction computes the explanation heatmap for the image stored
+// under the given id. It fails with prediction.ErrExplanationUnsupported when
+// the image predictor cannot explain its predictions.
+func ExplainPrediction(ctx context.Context, deps serviceDependencies, id string) (*prediction.Explanation, error) {
+	explainer, ok := deps.ImagePredictor().(prediction.Explainer)
+	if !ok {
+		return nil, prediction.ErrExplanationUnsupported
+	}
+
+	image, err := deps.StorageReader().ReadFromBucketObject(id)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotFetchImageFromStorage)
+	}
+
+	explanation, err := explainer.ExplainImage(ctx, image)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotExplainImage)
+	}
+
+	return explanation, nil
+}
+
+// RecordFeedback stores the correct class of the image stored under the given
+// id together with the class the model predicted for it. It fails with
+// feedback.ErrUnknownClass when the class is not one of the labels.
+func RecordFeedback(ctx context.Context, deps feedbackDependencies, id, correctClass, source string) (*feedback.Feedback, error) {
+	feedbackService := deps.Feedback()
+	if err := feedbackService.ValidateClass(correctClass); err != nil {
+		return nil, err
+	}
+
+	result, err := CalculatePrediction(ctx, deps, id)
+	if err != nil {
+		return nil, err
+	}
+
+	imageFeedback := &feedback.Feedback{
+		ImageID:        id,
+		PredictedClass: result.Class,
+		CorrectClass:   correctClass,
+		ModelVersion:   result.ModelVersion,
+		Source:         source,
+		CreatedAt:      time.Now(),
+	}
+	if err := feedbackService.Record(imageFeedback); err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotRecordFeedback)
+	}
+
+	return imageFeedback, nil
+}
@@ -0,0 +1,32 @@
+package predictionstore
+
+import "os"
+
+// Config decides where predictions are stored
+type Config struct {
+	Backend Backend
+	// Path is the directory of BackendFile
+	Path string
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// ConfigFromEnv reads the prediction store settings from the environment.
+// Predictions are kept in the object storage by default, where upload
+// deduplication and the dataset export find them.
+func ConfigFromEnv() (*Config, error) {
+	backend, err := ParseBackend(getEnv("PREDICTION_STORE", string(BackendObject)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{
+		Backend: backend,
+		Path:    getEnv("PREDICTION_STORE_PATH", "predictions"),
+	}, nil
+}
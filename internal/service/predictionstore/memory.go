@@ -0,0 +1,67 @@
+package predictionstore
+
+import (
+	"sort"
+	"sync"
+)
+
+// Memory keeps the records in memory
+type Memory struct {
+	mu      sync.RWMutex
+	records map[string]*Record
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{records: make(map[string]*Record)}
+}
+
+// Get returns the prediction of the image, ErrNotFound if there is none
+func (m *Memory) Get(imageID string) (*Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, ok := m.records[imageID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+// Put stores the record, replacing an earlier one of the same image
+func (m *Memory) Put(record *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.records[record.ImageID] = record
+	return nil
+}
+
+// List returns the page of records selected by the query, the newest first
+func (m *Memory) List(query Query) (*Page, error) {
+	m.mu.RLock()
+	matching := make([]*Record, 0, len(m.records))
+	for _, record := range m.records {
+		if query.Matches(record) {
+			matching = append(matching, record)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(matching, func(i, j int) bool {
+		if !matching[i].CreatedAt.Equal(matching[j].CreatedAt) {
+			return matching[i].CreatedAt.After(matching[j].CreatedAt)
+		}
+		return matching[i].ImageID < matching[j].ImageID
+	})
+
+	start := min(max(0, query.Offset), len(matching))
+	end := min(start+max(0, query.Limit), len(matching))
+
+	return &Page{
+		Total:   len(matching),
+		Offset:  query.Offset,
+		Limit:   query.Limit,
+		Records: matching[start:end],
+	}, nil
+}
@@ -0,0 +1,114 @@
+package predictionstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+var baseTime = time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+func newRecord(id, class string, probability float32, confidence prediction.Confidence, hoursLater int) *Record {
+	return &Record{
+		ImageID: id,
+		Result: &prediction.Result{
+			Class:       class,
+			Probability: probability,
+			Confidence:  confidence,
+		},
+		Latency:   50 * time.Millisecond,
+		CreatedAt: baseTime.Add(time.Duration(hoursLater) * time.Hour),
+	}
+}
+
+func testRecords() []*Record {
+	return []*Record{
+		newRecord("a", "cats", 0.95, prediction.ConfidenceConfident, 0),
+		newRecord("b", "non_cats", 0.7, prediction.ConfidenceUncertain, 1),
+		newRecord("c", "cats", 0.55, prediction.ConfidenceRejected, 2),
+		newRecord("d", "cats", 0.8, prediction.ConfidenceUncertain, 24),
+	}
+}
+
+func probability(value float32) *float32 {
+	return &value
+}
+
+func recordIDs(page *Page) []string {
+	ids := make([]string, len(page.Records))
+	for i, record := range page.Records {
+		ids[i] = record.ImageID
+	}
+	return ids
+}
+
+func Test_Memory_Get(t *testing.T) {
+	store := NewMemory()
+	record := newRecord("a", "cats", 0.9, prediction.ConfidenceConfident, 0)
+
+	_, err := store.Get("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, store.Put(record))
+	stored, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.Same(t, record, stored)
+}
+
+func Test_Memory_List(t *testing.T) {
+	store := NewMemory()
+	for _, record := range testRecords() {
+		assert.NoError(t, store.Put(record))
+	}
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []string
+		total    int
+	}{
+		{"newest first", Query{Limit: 10}, []string{"d", "c", "b", "a"}, 4},
+		{"first page", Query{Limit: 2}, []string{"d", "c"}, 4},
+		{"second page", Query{Offset: 2, Limit: 2}, []string{"b", "a"}, 4},
+		{"beyond the last page", Query{Offset: 10, Limit: 2}, []string{}, 4},
+		{"class", Query{Filter: Filter{Class: "cats"}, Limit: 10}, []string{"d", "c", "a"}, 3},
+		{"confidence", Query{Filter: Filter{Confidence: prediction.ConfidenceUncertain}, Limit: 10}, []string{"d", "b"}, 2},
+		{"probability range", Query{Filter: Filter{MinProbability: 0.6, MaxProbability: probability(0.8)}, Limit: 10}, []string{"d", "b"}, 2},
+		{"zero maximum probability", Query{Filter: Filter{MaxProbability: probability(0)}, Limit: 10}, []string{}, 0},
+		{"from", Query{Filter: Filter{From: baseTime.Add(time.Hour)}, Limit: 10}, []string{"d", "c", "b"}, 3},
+		{"to", Query{Filter: Filter{To: baseTime.Add(2 * time.Hour)}, Limit: 10}, []string{"b", "a"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.List(tt.query)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, recordIDs(page))
+			assert.Equal(t, tt.total, page.Total)
+		})
+	}
+}
+
+func Test_Memory_Put_replaces(t *testing.T) {
+	store := NewMemory()
+	assert.NoError(t, store.Put(newRecord("a", "cats", 0.9, prediction.ConfidenceConfident, 0)))
+	assert.NoError(t, store.Put(newRecord("a", "non_cats", 0.9, prediction.ConfidenceConfident, 1)))
+
+	page, err := store.List(Query{Limit: 10})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+	assert.Equal(t, "non_cats", page.Records[0].Class)
+}
+
+func Test_ParseBackend(t *testing.T) {
+	backend, err := ParseBackend("file")
+	assert.NoError(t, err)
+	assert.Equal(t, BackendFile, backend)
+
+	_, err = ParseBackend("database")
+	assert.EqualError(t, err, `unknown prediction store "database", use one of memory, file or object`)
+}
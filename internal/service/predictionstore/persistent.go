@@ -0,0 +1,122 @@
+package predictionstore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pkg/errors"
+)
+
+const (
+	// RecordSuffix is appended to the ID of an image to name the object its
+	// prediction is stored in
+	RecordSuffix = ".prediction"
+
+	errorTextCouldNotStoreRecord  = "could not store prediction"
+	errorTextCouldNotEncodeRecord = "could not encode prediction"
+)
+
+// Persistent writes every record as json object next to the image and
+// serves reads from memory. Its Loader reads the records stored earlier. The
+// object storage service and Directory can hold the records.
+type Persistent struct {
+	storage objects.Storage
+	memory  *Memory
+}
+
+// NewPersistent creates a store writing to the given storage
+func NewPersistent(storage objects.Storage) *Persistent {
+	return &Persistent{
+		storage: storage,
+		memory:  NewMemory(),
+	}
+}
+
+// Loader adds the stored records to memory
+func (p *Persistent) Loader() objects.Loader {
+	return objects.Loader{
+		Suffix: RecordSuffix,
+		Kind:   "predictions",
+		Load: func(id string, data []byte) error {
+			record := &Record{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return err
+			}
+			// a newer prediction may have been made while loading
+			if _, err := p.memory.Get(record.ImageID); errors.Is(err, ErrNotFound) {
+				return p.memory.Put(record)
+			}
+			return nil
+		},
+	}
+}
+
+// Get returns the prediction of the image, ErrNotFound if there is none
+func (p *Persistent) Get(imageID string) (*Record, error) {
+	return p.memory.Get(imageID)
+}
+
+// Put writes the record and keeps it in memory
+func (p *Persistent) Put(record *Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, errorTextCouldNotEncodeRecord)
+	}
+	if err := p.storage.WriteToBucketObject(record.ImageID+RecordSuffix, data); err != nil {
+		return errors.Wrap(err, errorTextCouldNotStoreRecord)
+	}
+
+	return p.memory.Put(record)
+}
+
+// List returns the page of records selected by the query, the newest first
+func (p *Persistent) List(query Query) (*Page, error) {
+	return p.memory.List(query)
+}
+
+// Directory stores objects as files in a local directory
+type Directory struct {
+	path string
+}
+
+// NewDirectory creates the directory if it does not exist
+func NewDirectory(path string) (*Directory, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, errors.Wrap(err, "could not create prediction store directory")
+	}
+	return &Directory{path: path}, nil
+}
+
+// WriteToBucketObject writes the file with the given ID. The data is written
+// to a temporary file first, so that a crash never leaves half a record.
+func (d *Directory) WriteToBucketObject(objectID string, data []byte) error {
+	path := filepath.Join(d.path, filepath.Base(objectID))
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ReadFromBucketObject reads the file with the given ID
+func (d *Directory) ReadFromBucketObject(objectID string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(d.path, filepath.Base(objectID)))
+}
+
+// ListBucketObjects returns the IDs of the files ending with suffix
+func (d *Directory) ListBucketObjects(suffix string) ([]string, error) {
+	entries, err := os.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var objectIDs []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), suffix) {
+			objectIDs = append(objectIDs, entry.Name())
+		}
+	}
+	return objectIDs, nil
+}
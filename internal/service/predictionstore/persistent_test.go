@@ -0,0 +1,81 @@
+package predictionstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDirectory(t *testing.T) *Directory {
+	directory, err := NewDirectory(filepath.Join(t.TempDir(), "predictions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return directory
+}
+
+func Test_Persistent_survives_restart(t *testing.T) {
+	directory := newTestDirectory(t)
+	store := NewPersistent(directory)
+	for _, record := range testRecords() {
+		assert.NoError(t, store.Put(record))
+	}
+
+	restarted := NewPersistent(directory)
+	assert.NoError(t, objects.Load(directory, restarted.Loader()))
+
+	record, err := restarted.Get("a")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "cats", record.Class)
+		assert.Equal(t, float32(0.95), record.Probability)
+		assert.Equal(t, prediction.ConfidenceConfident, record.Confidence)
+		assert.True(t, baseTime.Equal(record.CreatedAt))
+	}
+	page, err := restarted.List(Query{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "c", "b", "a"}, recordIDs(page))
+}
+
+func Test_Persistent_Loader_skips_broken_records(t *testing.T) {
+	directory := newTestDirectory(t)
+	assert.NoError(t, directory.WriteToBucketObject("broken"+RecordSuffix, []byte("{")))
+	assert.NoError(t, directory.WriteToBucketObject("image.jpg", []byte("not a prediction")))
+	store := NewPersistent(directory)
+	assert.NoError(t, store.Put(newRecord("a", "cats", 0.9, prediction.ConfidenceConfident, 0)))
+
+	restarted := NewPersistent(directory)
+	assert.NoError(t, objects.Load(directory, restarted.Loader()))
+
+	page, err := restarted.List(Query{Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, recordIDs(page))
+}
+
+func Test_Persistent_Loader_keeps_newer_records(t *testing.T) {
+	directory := newTestDirectory(t)
+	assert.NoError(t, NewPersistent(directory).Put(newRecord("a", "cats", 0.9, prediction.ConfidenceConfident, 0)))
+
+	store := NewPersistent(directory)
+	assert.NoError(t, store.memory.Put(newRecord("a", "non_cats", 0.9, prediction.ConfidenceConfident, 1)))
+	assert.NoError(t, objects.Load(directory, store.Loader()))
+
+	record, err := store.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "non_cats", record.Class)
+}
+
+func Test_Persistent_Put_error(t *testing.T) {
+	directory := newTestDirectory(t)
+	store := NewPersistent(directory)
+	assert.NoError(t, os.RemoveAll(directory.path))
+
+	err := store.Put(newRecord("a", "cats", 0.9, prediction.ConfidenceConfident, 0))
+
+	assert.Error(t, err)
+	_, err = store.Get("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,103 @@
+package predictionstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	// BackendMemory keeps the predictions until the service stops
+	BackendMemory Backend = "memory"
+	// BackendFile stores every prediction as a file in a directory
+	BackendFile Backend = "file"
+	// BackendObject stores every prediction next to its image in the object
+	// storage
+	BackendObject Backend = "object"
+)
+
+// ErrNotFound is returned for images without a stored prediction
+var ErrNotFound = errors.New("no prediction stored for the image")
+
+// Backend decides where predictions are stored
+type Backend string
+
+// ParseBackend returns the backend with the given name
+func ParseBackend(name string) (Backend, error) {
+	switch backend := Backend(name); backend {
+	case BackendMemory, BackendFile, BackendObject:
+		return backend, nil
+	}
+
+	return "", fmt.Errorf("unknown prediction store %q, use one of %s, %s or %s", name, BackendMemory, BackendFile, BackendObject)
+}
+
+// A Record is a prediction made for an uploaded image
+type Record struct {
+	ImageID string `json:"imageId"`
+	*prediction.Result
+	// Latency is how long the prediction took, including the time it
+	// waited for a worker
+	Latency   time.Duration `json:"latency"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// A Filter selects records. Zero values do not filter.
+type Filter struct {
+	Class          string
+	Confidence     prediction.Confidence
+	MinProbability float32
+	// MaxProbability is inclusive, nil means no upper limit
+	MaxProbability *float32
+	// From and To limit the creation time, From is inclusive and To exclusive
+	From time.Time
+	To   time.Time
+}
+
+// Matches reports whether the record is selected by the filter
+func (f Filter) Matches(record *Record) bool {
+	switch {
+	case f.Class != "" && record.Class != f.Class:
+		return false
+	case f.Confidence != "" && record.Confidence != f.Confidence:
+		return false
+	case record.Probability < f.MinProbability:
+		return false
+	case f.MaxProbability != nil && record.Probability > *f.MaxProbability:
+		return false
+	case !f.From.IsZero() && record.CreatedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !record.CreatedAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// A Query selects a page of the records matching the filter
+type Query struct {
+	Filter
+	Offset int
+	Limit  int
+}
+
+// A Page of records, the newest first
+type Page struct {
+	// Total is the number of records matching the filter on all pages
+	Total   int       `json:"total"`
+	Offset  int       `json:"offset"`
+	Limit   int       `json:"limit"`
+	Records []*Record `json:"predictions"`
+}
+
+// A Store records predictions and looks them up by image. Stores are safe for
+// concurrent use.
+type Store interface {
+	// Get returns the prediction of the image, ErrNotFound if there is none
+	Get(imageID string) (*Record, error)
+	// Put stores the record, replacing an earlier one of the same image
+	Put(record *Record) error
+	// List returns the page of records selected by the query
+	List(query Query) (*Page, error)
+}
@@ -0,0 +1,158 @@
+package reload
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"log"
+	"sync"
+
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	errorTextCouldNotLoadPredictor = "could not load image predictor"
+	errorTextWarmUpFailed          = "warm-up prediction of the new image predictor failed"
+	warmUpImageSize                = 32
+)
+
+// A Loader creates an image predictor from the current model and labels
+type Loader func() (dep.ImagePredictor, error)
+
+type generation struct {
+	predictor dep.ImagePredictor
+	inFlight  sync.WaitGroup
+}
+
+// Predictor is an image predictor whose model can be replaced at runtime. A
+// reload builds a new predictor, checks it with a warm-up prediction and swaps
+// it in. The old predictor is stopped once its predictions finished.
+type Predictor struct {
+	load     Loader
+	reloadMu sync.Mutex
+	mu       sync.RWMutex
+	current  *generation
+}
+
+// New creates a predictor starting with the given one. Reloads call load for
+// the next one.
+func New(predictor dep.ImagePredictor, load Loader) *Predictor {
+	return &Predictor{
+		load:    load,
+		current: &generation{predictor: predictor},
+	}
+}
+
+// PredictImage with the current model
+func (p *Predictor) PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error) {
+	p.mu.RLock()
+	current := p.current
+	current.inFlight.Add(1)
+	p.mu.RUnlock()
+
+	defer current.inFlight.Done()
+
+	return current.predictor.PredictImage(ctx, imageBytes)
+}
+
+// ExplainImage with the current model, if it can explain its predictions
+func (p *Predictor) ExplainImage(ctx context.Context, imageBytes []byte) (*prediction.Explanation, error) {
+	p.mu.RLock()
+	current := p.current
+	current.inFlight.Add(1)
+	p.mu.RUnlock()
+
+	defer current.inFlight.Done()
+
+	explainer, ok := current.predictor.(prediction.Explainer)
+	if !ok {
+		return nil, prediction.ErrExplanationUnsupported
+	}
+	return explainer.ExplainImage(ctx, imageBytes)
+}
+
+// EmbedImage with the current model, if it can extract embeddings
+func (p *Predictor) EmbedImage(ctx context.Context, imageBytes []byte) (*prediction.Embedding, error) {
+	p.mu.RLock()
+	current := p.current
+	current.inFlight.Add(1)
+	p.mu.RUnlock()
+
+	defer current.inFlight.Done()
+
+	embedder, ok := current.predictor.(prediction.Embedder)
+	if !ok {
+		return nil, prediction.ErrEmbeddingUnsupported
+	}
+	return embedder.EmbedImage(ctx, imageBytes)
+}
+
+// ModelVersion of the current model, empty if it does not tell its version
+func (p *Predictor) ModelVersion(ctx context.Context) string {
+	p.mu.RLock()
+	current := p.current
+	p.mu.RUnlock()
+
+	if versioned, ok := current.predictor.(prediction.Versioned); ok {
+		return versioned.ModelVersion(ctx)
+	}
+	return ""
+}
+
+// Reload loads the model again and swaps it in if the warm-up prediction
+// succeeds. Otherwise the current model stays in use. Reload returns after
+// the predictions running on the old model finished.
+func (p *Predictor) Reload(ctx context.Context) error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	predictor, err := p.load()
+	if err != nil {
+		return errors.Wrap(err, errorTextCouldNotLoadPredictor)
+	}
+
+	if _, err := predictor.PredictImage(ctx, warmUpImage); err != nil {
+		if stopErr := predictor.Stop(); stopErr != nil {
+			log.Printf("could not stop image predictor after failed warm-up: %v\n", stopErr)
+		}
+		return errors.Wrap(err, errorTextWarmUpFailed)
+	}
+
+	p.mu.Lock()
+	old := p.current
+	p.current = &generation{predictor: predictor}
+	p.mu.Unlock()
+
+	log.Println("reloaded image predictor, waiting for predictions of the old one to finish")
+	old.inFlight.Wait()
+
+	return old.predictor.Stop()
+}
+
+// Stop the current predictor
+func (p *Predictor) Stop() error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.current.predictor.Stop()
+}
+
+// warmUpImage is a plain grey png
+var warmUpImage = func() []byte {
+	img := image.NewGray(image.Rect(0, 0, warmUpImageSize, warmUpImageSize))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}()
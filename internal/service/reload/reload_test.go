@@ -0,0 +1,178 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pdstuber/isit-a-cat/internal/dep"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+var errMock = errors.New("everything went to hell")
+
+type fakePredictor struct {
+	class   string
+	err     error
+	entered chan struct{}
+	release chan struct{}
+	stopped atomic.Bool
+}
+
+func (f *fakePredictor) PredictImage(ctx context.Context, imageBytes []byte) (*prediction.Result, error) {
+	if f.release != nil {
+		f.entered <- struct{}{}
+		<-f.release
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &prediction.Result{Class: f.class}, nil
+}
+
+func (f *fakePredictor) Stop() error {
+	f.stopped.Store(true)
+	return nil
+}
+
+func loaderOf(predictor *fakePredictor, err error) Loader {
+	return func() (dep.ImagePredictor, error) {
+		if err != nil {
+			return nil, err
+		}
+		return predictor, nil
+	}
+}
+
+func Test_Reload_swaps_predictor(t *testing.T) {
+	old, reloaded := &fakePredictor{class: "cats"}, &fakePredictor{class: "non_cats"}
+	predictor := New(old, loaderOf(reloaded, nil))
+
+	assert.NoError(t, predictor.Reload(context.Background()))
+
+	result, err := predictor.PredictImage(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "non_cats", result.Class)
+	assert.True(t, old.stopped.Load())
+	assert.False(t, reloaded.stopped.Load())
+}
+
+func Test_Reload_keeps_predictor_when_warm_up_fails(t *testing.T) {
+	old, reloaded := &fakePredictor{class: "cats"}, &fakePredictor{err: errMock}
+	predictor := New(old, loaderOf(reloaded, nil))
+
+	err := predictor.Reload(context.Background())
+
+	assert.ErrorIs(t, err, errMock)
+	result, err := predictor.PredictImage(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", result.Class)
+	assert.False(t, old.stopped.Load())
+	assert.True(t, reloaded.stopped.Load())
+}
+
+func Test_Reload_keeps_predictor_when_load_fails(t *testing.T) {
+	old := &fakePredictor{class: "cats"}
+	predictor := New(old, loaderOf(nil, errMock))
+
+	err := predictor.Reload(context.Background())
+
+	assert.ErrorIs(t, err, errMock)
+	assert.False(t, old.stopped.Load())
+}
+
+func Test_Reload_drains_old_predictor(t *testing.T) {
+	old := &fakePredictor{class: "cats", entered: make(chan struct{}, 1), release: make(chan struct{})}
+	predictor := New(old, loaderOf(&fakePredictor{class: "non_cats"}, nil))
+
+	results := make(chan string)
+	go func() {
+		result, _ := predictor.PredictImage(context.Background(), nil)
+		results <- result.Class
+	}()
+	<-old.entered
+
+	reloaded := make(chan error)
+	go func() { reloaded <- predictor.Reload(context.Background()) }()
+
+	// new predictions already use the new model while the old one drains
+	assert.Eventually(t, func() bool {
+		result, err := predictor.PredictImage(context.Background(), nil)
+		return err == nil && result.Class == "non_cats"
+	}, time.Second, time.Millisecond)
+	assert.False(t, old.stopped.Load())
+
+	close(old.release)
+	assert.Equal(t, "cats", <-results)
+	assert.NoError(t, <-reloaded)
+	assert.True(t, old.stopped.Load())
+}
+
+func Test_Watch_reloads_on_change(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.pb")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloads atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, 5*time.Millisecond, []string{path, filepath.Join(filepath.Dir(path), "missing.csv")}, func(context.Context) error {
+		reloads.Add(1)
+		return nil
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), reloads.Load())
+
+	if err := os.WriteFile(path, []byte("new model"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool { return reloads.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+type fakeExplainer struct {
+	fakePredictor
+}
+
+func (f *fakeExplainer) ExplainImage(ctx context.Context, imageBytes []byte) (*prediction.Explanation, error) {
+	return &prediction.Explanation{Result: &prediction.Result{Class: f.class}}, nil
+}
+
+func Test_ExplainImage(t *testing.T) {
+	explainer := &fakeExplainer{fakePredictor{class: "cats"}}
+	predictor := New(explainer, func() (dep.ImagePredictor, error) { return &fakePredictor{class: "non_cats"}, nil })
+
+	explanation, err := predictor.ExplainImage(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", explanation.Result.Class)
+
+	assert.NoError(t, predictor.Reload(context.Background()))
+	_, err = predictor.ExplainImage(context.Background(), nil)
+	assert.ErrorIs(t, err, prediction.ErrExplanationUnsupported)
+}
+
+type fakeEmbedder struct {
+	fakePredictor
+}
+
+func (f *fakeEmbedder) EmbedImage(ctx context.Context, imageBytes []byte) (*prediction.Embedding, error) {
+	return &prediction.Embedding{Vector: []float32{1, 2}}, nil
+}
+
+func Test_EmbedImage(t *testing.T) {
+	predictor := New(&fakeEmbedder{}, func() (dep.ImagePredictor, error) { return &fakePredictor{}, nil })
+
+	embedding, err := predictor.EmbedImage(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{1, 2}, embedding.Vector)
+
+	assert.NoError(t, predictor.Reload(context.Background()))
+	_, err = predictor.EmbedImage(context.Background(), nil)
+	assert.ErrorIs(t, err, prediction.ErrEmbeddingUnsupported)
+}
@@ -0,0 +1,63 @@
+package reload
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch calls reload whenever one of the files changed, checking them every
+// interval until ctx is done. Files that can not be read are skipped until
+// they exist again, so a deployment can replace them one after the other.
+func Watch(ctx context.Context, interval time.Duration, paths []string, reload func(ctx context.Context) error) {
+	versions := fileVersions(paths)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := fileVersions(paths)
+			if !changed(versions, current) {
+				continue
+			}
+			versions = current
+
+			log.Println("model files changed, reloading image predictor")
+			if err := reload(ctx); err != nil {
+				log.Printf("could not reload image predictor: %v\n", err)
+			}
+		}
+	}
+}
+
+func fileVersions(paths []string) map[string]fileVersion {
+	versions := make(map[string]fileVersion, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		versions[path] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return versions
+}
+
+// changed reports whether a file was modified. Missing files are not a change.
+func changed(previous, current map[string]fileVersion) bool {
+	for path, version := range current {
+		if previous[path] != version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package similarity
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+)
+
+// A Match is an image similar to the one searched for
+type Match struct {
+	ID string `json:"id"`
+	// Similarity is the cosine similarity of the embeddings, 1 for images
+	// the model sees as the same
+	Similarity float32 `json:"similarity"`
+}
+
+// Index finds the nearest embeddings by cosine similarity. It compares the
+// query with every embedding of the same model version, which is fast enough
+// for the number of images uploaded to a single instance. It is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	ids      []string
+	vectors  [][]float32
+	versions []string
+	byID     map[string]int
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{byID: make(map[string]int)}
+}
+
+// Add the embedding of the image with the given ID, replacing an earlier one
+func (i *Index) Add(id string, embedding *prediction.Embedding) {
+	vector := normalize(embedding.Vector)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if position, ok := i.byID[id]; ok {
+		i.vectors[position] = vector
+		i.versions[position] = embedding.ModelVersion
+		return
+	}
+	i.byID[id] = len(i.ids)
+	i.ids = append(i.ids, id)
+	i.vectors = append(i.vectors, vector)
+	i.versions = append(i.versions, embedding.ModelVersion)
+}
+
+// Get returns the normalized embedding of the image with the given ID
+func (i *Index) Get(id string) (*prediction.Embedding, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	position, ok := i.byID[id]
+	if !ok {
+		return nil, false
+	}
+	return &prediction.Embedding{Vector: i.vectors[position], ModelVersion: i.versions[position]}, true
+}
+
+// Len returns the number of embeddings in the index
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.ids)
+}
+
+// Nearest returns the k images most similar to the embedding, the most
+// similar first. The image with the excluded ID is skipped, as are
+// embeddings computed by another model version.
+func (i *Index) Nearest(embedding *prediction.Embedding, k int, excludeID string) []Match {
+	query := normalize(embedding.Vector)
+
+	i.mu.RLock()
+	matches := make([]Match, 0, len(i.ids))
+	for position, vector := range i.vectors {
+		if i.ids[position] == excludeID || i.versions[position] != embedding.ModelVersion || len(vector) != len(query) {
+			continue
+		}
+		matches = append(matches, Match{ID: i.ids[position], Similarity: dot(query, vector)})
+	}
+	i.mu.RUnlock()
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		return matches[a].Similarity > matches[b].Similarity
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// normalize returns a copy of the vector scaled to length one, so that the
+// cosine similarity is the dot product
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, value := range vector {
+		sum += float64(value) * float64(value)
+	}
+
+	normalized := make([]float32, len(vector))
+	if sum == 0 {
+		return normalized
+	}
+	length := math.Sqrt(sum)
+	for i, value := range vector {
+		normalized[i] = float32(float64(value) / length)
+	}
+	return normalized
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,84 @@
+package similarity
+
+import (
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+// embedding of the model version v1
+func embedding(vector ...float32) *prediction.Embedding {
+	return &prediction.Embedding{Vector: vector, ModelVersion: "v1"}
+}
+
+func Test_Index_Nearest(t *testing.T) {
+	index := NewIndex()
+	index.Add("same", embedding(2, 0, 0))
+	index.Add("close", embedding(1, 1, 0))
+	index.Add("opposite", embedding(-1, 0, 0))
+	index.Add("query", embedding(1, 0, 0))
+
+	matches := index.Nearest(embedding(1, 0, 0), 10, "query")
+
+	if assert.Len(t, matches, 3) {
+		assert.Equal(t, "same", matches[0].ID)
+		assert.InDelta(t, 1, matches[0].Similarity, 1e-6)
+		assert.Equal(t, "close", matches[1].ID)
+		assert.InDelta(t, 0.7071068, matches[1].Similarity, 1e-6)
+		assert.Equal(t, "opposite", matches[2].ID)
+		assert.InDelta(t, -1, matches[2].Similarity, 1e-6)
+	}
+}
+
+func Test_Index_Nearest_limits_to_k(t *testing.T) {
+	index := NewIndex()
+	index.Add("a", embedding(1, 0))
+	index.Add("b", embedding(0, 1))
+	index.Add("c", embedding(1, 1))
+
+	matches := index.Nearest(embedding(1, 0.1), 2, "")
+
+	assert.Equal(t, []string{"a", "c"}, []string{matches[0].ID, matches[1].ID})
+}
+
+func Test_Index_Add_replaces(t *testing.T) {
+	index := NewIndex()
+	index.Add("a", embedding(1, 0))
+	index.Add("a", embedding(0, 3))
+
+	stored, ok := index.Get("a")
+
+	assert.True(t, ok)
+	assert.Equal(t, []float32{0, 1}, stored.Vector)
+	assert.Equal(t, 1, index.Len())
+}
+
+func Test_Index_Nearest_skips_other_lengths(t *testing.T) {
+	index := NewIndex()
+	index.Add("old model", embedding(1, 0, 0))
+	index.Add("new model", embedding(1, 0))
+
+	matches := index.Nearest(embedding(1, 0), 10, "")
+
+	assert.Equal(t, []Match{{ID: "new model", Similarity: 1}}, matches)
+}
+
+func Test_Index_Nearest_skips_other_versions(t *testing.T) {
+	index := NewIndex()
+	index.Add("old model", &prediction.Embedding{Vector: []float32{1, 0}, ModelVersion: "v0"})
+	index.Add("new model", embedding(0, 1))
+
+	matches := index.Nearest(embedding(1, 0), 10, "")
+
+	assert.Equal(t, []Match{{ID: "new model", Similarity: 0}}, matches)
+}
+
+func Test_Index_zero_vector(t *testing.T) {
+	index := NewIndex()
+	index.Add("zero", embedding(0, 0))
+
+	matches := index.Nearest(embedding(1, 0), 10, "")
+
+	assert.Equal(t, []Match{{ID: "zero", Similarity: 0}}, matches)
+}
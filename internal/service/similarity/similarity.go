@@ -0,0 +1,151 @@
+package similarity
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/pkg/errors"
+)
+
+const (
+	// EmbeddingSuffix is appended to the ID of an image to name the object
+	// its embedding is stored in
+	EmbeddingSuffix = ".embedding"
+
+	errorTextCouldNotEmbedImage     = "could not compute embedding of image"
+	errorTextCouldNotStoreEmbedding = "could not store embedding"
+	errorTextCouldNotReadImage      = "could not read image"
+	errorTextInvalidStoredEmbedding = "stored embedding has %d bytes, not a multiple of 4"
+	errorTextInvalidStoredVersion   = "stored embedding has %d bytes, too few for its model version"
+)
+
+// versionedEmbeddingHeader starts stored embeddings that are followed by the
+// length of their model version as uint16 and the version. Embeddings stored
+// before have no model version.
+var versionedEmbeddingHeader = []byte("emb1")
+
+// Service indexes the embeddings of uploaded images and finds similar ones.
+// Embeddings are stored next to the image with their model version as little
+// endian float32 values, so that the index can be rebuilt when the service
+// starts.
+type Service struct {
+	embedder prediction.Embedder
+	storage  objects.Storage
+	index    *Index
+
+	mu sync.RWMutex
+	// modelVersion of the latest computed embedding, nil before the first
+	modelVersion *string
+}
+
+// New creates the similarity service with an empty index
+func New(embedder prediction.Embedder, storage objects.Storage) *Service {
+	return &Service{
+		embedder: embedder,
+		storage:  storage,
+		index:    NewIndex(),
+	}
+}
+
+// Loader adds the stored embeddings to the index
+func (s *Service) Loader() objects.Loader {
+	return objects.Loader{
+		Suffix: EmbeddingSuffix,
+		Kind:   "embeddings",
+		Load: func(id string, data []byte) error {
+			embedding, err := decodeEmbedding(data)
+			if err != nil {
+				return err
+			}
+			s.index.Add(id, embedding)
+			return nil
+		},
+	}
+}
+
+// IndexImage computes the embedding of the image, stores it next to the
+// image and adds it to the index
+func (s *Service) IndexImage(ctx context.Context, id string, imageBytes []byte) error {
+	embedding, err := s.embedder.EmbedImage(ctx, imageBytes)
+	if err != nil {
+		return errors.Wrap(err, errorTextCouldNotEmbedImage)
+	}
+	s.mu.Lock()
+	s.modelVersion = &embedding.ModelVersion
+	s.mu.Unlock()
+
+	if err := s.storage.WriteToBucketObject(id+EmbeddingSuffix, encodeEmbedding(embedding)); err != nil {
+		return errors.Wrap(err, errorTextCouldNotStoreEmbedding)
+	}
+	s.index.Add(id, embedding)
+
+	return nil
+}
+
+// Similar returns the k uploaded images most similar to the one with the
+// given ID among the images embedded by the same model version. Images
+// uploaded before embeddings were enabled, or embedded by another model
+// version than the current one, are indexed first.
+func (s *Service) Similar(ctx context.Context, id string, k int) ([]Match, error) {
+	embedding, ok := s.index.Get(id)
+	if !ok || !s.isCurrent(embedding) {
+		imageBytes, err := s.storage.ReadFromBucketObject(id)
+		if err != nil {
+			return nil, errors.Wrap(err, errorTextCouldNotReadImage)
+		}
+		if err := s.IndexImage(ctx, id, imageBytes); err != nil {
+			return nil, err
+		}
+		embedding, _ = s.index.Get(id)
+	}
+
+	return s.index.Nearest(embedding, k, id), nil
+}
+
+// isCurrent reports whether the embedding was computed by the model version
+// of the latest computed embedding. Until an embedding was computed the
+// current version is unknown and no stored embedding is current.
+func (s *Service) isCurrent(embedding *prediction.Embedding) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.modelVersion != nil && *s.modelVersion == embedding.ModelVersion
+}
+
+func encodeEmbedding(embedding *prediction.Embedding) []byte {
+	header := len(versionedEmbeddingHeader) + 2 + len(embedding.ModelVersion)
+	data := make([]byte, header+4*len(embedding.Vector))
+	copy(data, versionedEmbeddingHeader)
+	binary.LittleEndian.PutUint16(data[len(versionedEmbeddingHeader):], uint16(len(embedding.ModelVersion)))
+	copy(data[len(versionedEmbeddingHeader)+2:], embedding.ModelVersion)
+	for i, value := range embedding.Vector {
+		binary.LittleEndian.PutUint32(data[header+4*i:], math.Float32bits(value))
+	}
+	return data
+}
+
+func decodeEmbedding(data []byte) (*prediction.Embedding, error) {
+	embedding := &prediction.Embedding{}
+	if rest, ok := bytes.CutPrefix(data, versionedEmbeddingHeader); ok {
+		if len(rest) < 2 || len(rest) < 2+int(binary.LittleEndian.Uint16(rest)) {
+			return nil, errors.Errorf(errorTextInvalidStoredVersion, len(data))
+		}
+		versionLength := int(binary.LittleEndian.Uint16(rest))
+		embedding.ModelVersion = string(rest[2 : 2+versionLength])
+		data = rest[2+versionLength:]
+	}
+	if len(data)%4 != 0 {
+		return nil, errors.Errorf(errorTextInvalidStoredEmbedding, len(data))
+	}
+
+	embedding.Vector = make([]float32, len(data)/4)
+	for i := range embedding.Vector {
+		embedding.Vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return embedding, nil
+}
@@ -0,0 +1,149 @@
+package similarity
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pdstuber/isit-a-cat/internal/service/objects"
+	"github.com/pdstuber/isit-a-cat/internal/service/objects/objectstest"
+	"github.com/pdstuber/isit-a-cat/internal/service/storage"
+	"github.com/pdstuber/isit-a-cat/pkg/prediction"
+	"github.com/stretchr/testify/assert"
+)
+
+var errMock = errors.New("everything went to hell")
+
+// fakeEmbedder embeds an image as its bytes
+type fakeEmbedder struct {
+	calls   int
+	version string
+	err     error
+}
+
+func (f *fakeEmbedder) EmbedImage(ctx context.Context, imageBytes []byte) (*prediction.Embedding, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	vector := make([]float32, len(imageBytes))
+	for i, b := range imageBytes {
+		vector[i] = float32(b)
+	}
+	return &prediction.Embedding{Vector: vector, ModelVersion: f.version}, nil
+}
+
+func Test_IndexImage_stores_embedding(t *testing.T) {
+	storage := objectstest.NewStorage()
+	service := New(&fakeEmbedder{version: "v1"}, storage)
+
+	assert.NoError(t, service.IndexImage(context.Background(), "cat", []byte{1, 2}))
+
+	embedding, err := decodeEmbedding(storage.Objects["cat"+EmbeddingSuffix])
+	assert.NoError(t, err)
+	assert.Equal(t, &prediction.Embedding{Vector: []float32{1, 2}, ModelVersion: "v1"}, embedding)
+	assert.Equal(t, 1, service.index.Len())
+}
+
+func Test_IndexImage_error(t *testing.T) {
+	storage := objectstest.NewStorage()
+	service := New(&fakeEmbedder{err: errMock}, storage)
+
+	err := service.IndexImage(context.Background(), "cat", []byte{1, 2})
+
+	assert.ErrorIs(t, err, errMock)
+	assert.Empty(t, storage.Objects)
+	assert.Zero(t, service.index.Len())
+}
+
+func Test_Similar(t *testing.T) {
+	storage := objectstest.NewStorage()
+	embedder := &fakeEmbedder{}
+	service := New(embedder, storage)
+	ctx := context.Background()
+	assert.NoError(t, service.IndexImage(ctx, "cat", []byte{10, 1}))
+	assert.NoError(t, service.IndexImage(ctx, "similar cat", []byte{9, 2}))
+	assert.NoError(t, service.IndexImage(ctx, "dog", []byte{1, 10}))
+
+	matches, err := service.Similar(ctx, "cat", 1)
+
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "similar cat", matches[0].ID)
+	}
+	assert.Equal(t, 3, embedder.calls)
+}
+
+func Test_Similar_recomputes_embeddings_of_other_versions(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["cat"] = []byte{10, 1}
+	embedder := &fakeEmbedder{version: "v1"}
+	service := New(embedder, storage)
+	ctx := context.Background()
+	assert.NoError(t, service.IndexImage(ctx, "cat", []byte{10, 1}))
+	assert.NoError(t, service.IndexImage(ctx, "similar cat", []byte{9, 2}))
+
+	embedder.version = "v2"
+	assert.NoError(t, service.IndexImage(ctx, "dog", []byte{1, 10}))
+	matches, err := service.Similar(ctx, "cat", 10)
+
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "dog", matches[0].ID)
+	}
+	assert.Equal(t, 4, embedder.calls)
+	stored, err := decodeEmbedding(storage.Objects["cat"+EmbeddingSuffix])
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", stored.ModelVersion)
+}
+
+func Test_Similar_indexes_unknown_image(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["old"] = []byte{3, 4}
+	service := New(&fakeEmbedder{}, storage)
+	assert.NoError(t, service.IndexImage(context.Background(), "new", []byte{4, 3}))
+
+	matches, err := service.Similar(context.Background(), "old", 10)
+
+	assert.NoError(t, err)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "new", matches[0].ID)
+		assert.InDelta(t, 24.0/25, matches[0].Similarity, 1e-6)
+	}
+	assert.Contains(t, storage.Objects, "old"+EmbeddingSuffix)
+}
+
+func Test_Similar_missing_image(t *testing.T) {
+	service := New(&fakeEmbedder{}, objectstest.NewStorage())
+
+	_, err := service.Similar(context.Background(), "missing", 10)
+
+	assert.ErrorIs(t, err, storage.ErrObjectNotFound)
+}
+
+func Test_Load(t *testing.T) {
+	storage := objectstest.NewStorage()
+	storage.Objects["cat"] = []byte{1, 0}
+	storage.Objects["cat"+EmbeddingSuffix] = encodeEmbedding(&prediction.Embedding{Vector: []float32{1, 0}, ModelVersion: "v1"})
+	storage.Objects["dog"+EmbeddingSuffix] = encodeEmbedding(&prediction.Embedding{Vector: []float32{0, 1}, ModelVersion: "v1"})
+	// stored before embeddings had a model version
+	storage.Objects["old"+EmbeddingSuffix] = []byte{0, 0, 128, 63, 0, 0, 0, 0}
+	storage.Objects["broken"+EmbeddingSuffix] = []byte{1, 2, 3}
+	embedder := &fakeEmbedder{version: "v1"}
+	service := New(embedder, storage)
+
+	assert.NoError(t, objects.Load(storage, service.Loader()))
+
+	assert.Equal(t, 3, service.index.Len())
+	old, _ := service.index.Get("old")
+	assert.Equal(t, &prediction.Embedding{Vector: []float32{1, 0}}, old)
+	// the current model version is only known after embedding an image
+	matches, err := service.Similar(context.Background(), "cat", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Match{{ID: "dog", Similarity: 0}}, matches)
+	assert.Equal(t, 1, embedder.calls)
+
+	_, err = service.Similar(context.Background(), "dog", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, embedder.calls)
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Config holds the connection to the object storage
+type Config struct {
+	Endpoint        string
+	AccessKeyID     string
+	SecretAccessKey string
+	UseTLS          bool
+	BucketName      string
+	ObjectFolder    string
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+// ConfigFromEnv reads the object storage settings from the environment
+func ConfigFromEnv() (*Config, error) {
+	endpoint := getEnv("OBJECT_STORAGE_ENDPOINT", "minio:9000")
+	accessKeyID := getEnv("MINIO_ACCESS_KEY", "")
+	if accessKeyID == "" {
+		return nil, errors.New("object storage access key id is mandatory")
+	}
+	secretKey := getEnv("MINIO_SECRET_KEY", "")
+	if secretKey == "" {
+		return nil, errors.New("object storage secret key is mandatory")
+	}
+	useTLS, err := strconv.ParseBool(getEnv("OBJECT_STORAGE_USE_TLS", "false"))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse as boolean")
+	}
+
+	return &Config{
+		Endpoint:        endpoint,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretKey,
+		UseTLS:          useTLS,
+		BucketName:      getEnv("STORAGE_BUCKET_NAME", "isit-a-cat"),
+		ObjectFolder:    getEnv("STORAGE_OBJECT_FOLDER", "uploaded-images/"),
+	}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/minio/minio-go/v6"
 	"github.com/pkg/errors"
@@ -13,8 +14,13 @@ const (
 	errorTextCouldNotCreateClient = "could not create storage client"
 	errorTextBucketWrite          = "could not write to bucket"
 	errorTextBucketRead           = "could not read from bucket"
+	errorTextBucketList           = "could not list bucket objects"
+	minioCodeNoSuchKey            = "NoSuchKey"
 )
 
+// ErrObjectNotFound is returned when reading an object that does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
 // Service handles writes and reads from object storage buckets
 type Service struct {
 	client              StorageObjectReaderWriter
@@ -26,6 +32,7 @@ type Service struct {
 type StorageObjectReaderWriter interface {
 	PutObject(bucketName, objectName string, reader io.Reader, objectSize int64, opts minio.PutObjectOptions) (n int64, err error)
 	GetObject(bucketName, objectName string, opts minio.GetObjectOptions) (*minio.Object, error)
+	ListObjectsV2(bucketName, objectPrefix string, recursive bool, doneCh <-chan struct{}) <-chan minio.ObjectInfo
 }
 
 // New creates an instance of the storage service
@@ -79,7 +86,9 @@ func (service *Service) ReadFromBucketObject(objectId string) ([]byte, error) {
 
 	data, err := io.ReadAll(object)
 
-	if err != nil {
+	if minio.ToErrorResponse(err).Code == minioCodeNoSuchKey {
+		return nil, errors.Wrap(ErrObjectNotFound, storageObjectPath)
+	} else if err != nil {
 		return nil, errors.Wrap(err, errorTextBucketRead)
 	}
 
@@ -87,3 +96,21 @@ func (service *Service) ReadFromBucketObject(objectId string) ([]byte, error) {
 
 	return data, err
 }
+
+// ListBucketObjects returns the IDs of the bucket objects ending with suffix
+func (service *Service) ListBucketObjects(suffix string) ([]string, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	var objectIDs []string
+	for object := range service.client.ListObjectsV2(service.storageBucketName, service.storageObjectFolder, true, done) {
+		if object.Err != nil {
+			return nil, errors.Wrap(object.Err, errorTextBucketList)
+		}
+		if objectID := strings.TrimPrefix(object.Key, service.storageObjectFolder); strings.HasSuffix(objectID, suffix) {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+
+	return objectIDs, nil
+}
@@ -36,3 +36,68 @@ def export_in_tf_format(export_op_names, export_folder, pb_filename):
                                   output_names=export_op_names)
 
     tf.train.write_graph(frozen_graph, export_folder, pb_filename, as_text=False)
+
+
+def export_saved_model(model, export_folder):
+    """
+    Exports the model as a SavedModel directory, which the prediction service
+    loads without freezing the graph and without configured operation names.
+    @param model The keras model to export.
+    @param export_folder Directory for saved_model.pb and the variables.
+    """
+    tf.saved_model.save(model, export_folder)
+
+
+WEIGHTS_MAGIC = b'IACW'
+WEIGHTS_VERSION = 1
+WEIGHTS_ACTIVATIONS = {'linear': 0, 'relu': 1, 'softmax': 2}
+
+
+def export_go_weights(model, path):
+    """
+    Exports the model in the weights format of the pure Go backend, described
+    in pkg/prediction/network.go. Only the layers of the VGG16 based model are
+    supported.
+    @param model The keras model to export.
+    @param path File to write, usually model.weights in the export folder.
+    """
+    import struct
+    import numpy as np
+
+    def activation(layer):
+        name = layer.activation.__name__
+        if name not in WEIGHTS_ACTIVATIONS:
+            raise ValueError(f"unsupported activation {name} in layer {layer.name}")
+        return WEIGHTS_ACTIVATIONS[name]
+
+    def floats(values):
+        return np.asarray(values, dtype='<f4').tobytes()
+
+    layers = []
+    for layer in model.layers:
+        kind = type(layer).__name__
+        if kind in ('InputLayer', 'Dropout'):
+            continue
+        elif kind == 'Conv2D':
+            if layer.strides != (1, 1) or layer.padding != 'same':
+                raise ValueError(f"layer {layer.name} must have stride 1 and same padding")
+            kernel, bias = layer.get_weights()
+            layers.append(struct.pack('<B4IB', 1, *kernel.shape, activation(layer)) + floats(kernel) + floats(bias))
+        elif kind == 'MaxPooling2D':
+            if layer.pool_size[0] != layer.pool_size[1] or layer.strides != layer.pool_size:
+                raise ValueError(f"layer {layer.name} must have a square pool and matching strides")
+            layers.append(struct.pack('<BI', 2, layer.pool_size[0]))
+        elif kind == 'Flatten':
+            layers.append(struct.pack('<B', 3))
+        elif kind == 'Dense':
+            kernel, bias = layer.get_weights()
+            layers.append(struct.pack('<B2IB', 4, *kernel.shape, activation(layer)) + floats(kernel) + floats(bias))
+        else:
+            raise ValueError(f"unsupported layer {layer.name} of type {kind}")
+
+    _, height, width, channels = model.input_shape
+    with open(path, 'wb') as f:
+        f.write(WEIGHTS_MAGIC)
+        f.write(struct.pack('<5I', WEIGHTS_VERSION, height, width, channels, len(layers)))
+        for layer in layers:
+            f.write(layer)
@@ -1,5 +1,6 @@
 #!/usr/bin/env python3
 
+import os
 from PIL import ImageFile
 from keras import models
 from keras.applications.vgg16 import VGG16
@@ -38,12 +39,12 @@ def create_model():
     return model
 
 
-def create_datagen():
+def create_datagen(validation_split):
     global datagen
     # create data generator
     datagen = image.ImageDataGenerator(
         featurewise_center=True,
-        validation_split=0.2
+        validation_split=validation_split
     )
     # specify imagenet mean values for centering
     datagen.mean = [123.68, 116.779, 103.939]
@@ -51,9 +52,9 @@ def create_datagen():
     return datagen
 
 
-def create_data_set(datagen, subset):
+def create_data_set(datagen, path, subset=None):
     return datagen.flow_from_directory(
-        training_data_path,
+        path,
         batch_size=128,
         target_size=(IMAGE_SIZE, IMAGE_SIZE),
         class_mode='categorical',
@@ -69,10 +70,20 @@ pb_model_name = 'model.pb'
 
 classifier = create_model()
 
-datagen = create_datagen()
+# a dataset exported with --validation-split is already split into train/
+# and validation/, otherwise 20% of the images are used for validation
+split_training_path = os.path.join(training_data_path, 'train')
+split_validation_path = os.path.join(training_data_path, 'validation')
+if os.path.isdir(split_training_path) and os.path.isdir(split_validation_path):
+    datagen = create_datagen(0.0)
 
-training_data_set = create_data_set(datagen, 'training')
-validation_data_set = create_data_set(datagen, 'validation')
+    training_data_set = create_data_set(datagen, split_training_path)
+    validation_data_set = create_data_set(datagen, split_validation_path)
+else:
+    datagen = create_datagen(0.2)
+
+    training_data_set = create_data_set(datagen, training_data_path, 'training')
+    validation_data_set = create_data_set(datagen, training_data_path, 'validation')
 
 history = classifier.fit_generator(
     training_data_set,
@@ -97,4 +108,10 @@ with open(labelsFilePath, 'w') as f:
     for key in label_map.keys():
         f.write("%s,%s\n" % (label_map[key], key))
 
-export.export_in_tf_format([out.op.name for out in classifier.outputs], exported_model_folder, pb_model_name)
+export_format = os.environ.get('EXPORT_FORMAT', 'frozen-graph')
+if export_format == 'saved-model':
+    export.export_saved_model(classifier, exported_model_folder)
+elif export_format == 'weights':
+    export.export_go_weights(classifier, f"{exported_model_folder}/model.weights")
+else:
+    export.export_in_tf_format([out.op.name for out in classifier.outputs], exported_model_folder, pb_model_name)
@@ -0,0 +1,140 @@
+package prediction
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxBatchSize = 1
+	defaultMaxBatchWait = 5 * time.Millisecond
+)
+
+type batchItem struct {
+	input []float32
+	done  chan batchResult
+}
+
+type batchResult struct {
+	scores []float32
+	err    error
+}
+
+// batcher collects the preprocessed images of concurrent predictions and runs
+// them through the model as a single batch. A batch is run as soon as it is
+// full or maxWait after its first image arrived. The workers of the pool wait
+// for their batch, so a batch never holds more images than there are workers.
+type batcher struct {
+	items   chan *batchItem
+	maxSize int
+	maxWait time.Duration
+	run     func(inputs [][]float32) ([][]float32, error)
+	wg      sync.WaitGroup
+	stopped sync.Once
+
+	statsMu sync.Mutex
+	batches uint64
+	images  uint64
+}
+
+func newBatcher(maxSize int, maxWait time.Duration, run func(inputs [][]float32) ([][]float32, error)) *batcher {
+	b := &batcher{
+		items:   make(chan *batchItem, maxSize),
+		maxSize: maxSize,
+		maxWait: maxWait,
+		run:     run,
+	}
+
+	b.wg.Add(1)
+	go b.loop()
+
+	return b
+}
+
+// predict adds the input to the next batch and waits for its scores
+func (b *batcher) predict(input []float32) ([]float32, error) {
+	item := &batchItem{input: input, done: make(chan batchResult, 1)}
+	b.items <- item
+
+	r := <-item.done
+	return r.scores, r.err
+}
+
+func (b *batcher) loop() {
+	defer b.wg.Done()
+
+	for item := range b.items {
+		batch := b.collect(item)
+
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.runBatch(batch)
+		}()
+	}
+}
+
+// collect waits for more items until the batch is full or maxWait passed
+func (b *batcher) collect(first *batchItem) []*batchItem {
+	batch := []*batchItem{first}
+	if b.maxSize == 1 {
+		return batch
+	}
+
+	timer := time.NewTimer(b.maxWait)
+	defer timer.Stop()
+
+	for len(batch) < b.maxSize {
+		select {
+		case item, ok := <-b.items:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, item)
+		case <-timer.C:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+func (b *batcher) runBatch(batch []*batchItem) {
+	inputs := make([][]float32, len(batch))
+	for i, item := range batch {
+		inputs[i] = item.input
+	}
+
+	scores, err := b.run(inputs)
+
+	b.statsMu.Lock()
+	b.batches++
+	b.images += uint64(len(batch))
+	b.statsMu.Unlock()
+
+	for i, item := range batch {
+		if err != nil {
+			item.done <- batchResult{err: err}
+		} else {
+			item.done <- batchResult{scores: scores[i]}
+		}
+	}
+}
+
+func (b *batcher) fillStats(stats *Stats) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	stats.MaxBatchSize = b.maxSize
+	stats.Batches = b.batches
+	if b.batches > 0 {
+		stats.AverageBatchSize = float64(b.images) / float64(b.batches)
+	}
+}
+
+// stop runs the remaining batches and waits for them. No predictions may be
+// added after stop was called.
+func (b *batcher) stop() {
+	b.stopped.Do(func() { close(b.items) })
+	b.wg.Wait()
+}
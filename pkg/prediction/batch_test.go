@@ -0,0 +1,194 @@
+package prediction
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dominantChannelInference predicts the class matching the strongest color
+// channel of each image, so results can be told apart within a batch
+func dominantChannelInference(batchSizes chan<- int) func([][]float32) ([][]float32, error) {
+	return func(inputs [][]float32) ([][]float32, error) {
+		batchSizes <- len(inputs)
+
+		predictions := make([][]float32, len(inputs))
+		for i := range predictions {
+			var sums [rgbColorChannels]float32
+			for j, f := range inputs[i] {
+				sums[j%rgbColorChannels] += f + imagenetMeans[j%rgbColorChannels]
+			}
+
+			predictions[i] = make([]float32, rgbColorChannels)
+			dominant := 0
+			for c := range sums {
+				if sums[c] > sums[dominant] {
+					dominant = c
+				}
+			}
+			predictions[i][dominant] = 1
+		}
+		return predictions, nil
+	}
+}
+
+func solidPNG(tb testing.TB, c color.RGBA) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_PredictImage_batches_concurrent_predictions(t *testing.T) {
+	batchSizes := make(chan int, 10)
+	service := newFakeService(dominantChannelInference(batchSizes),
+		WithWorkers(4), WithMaxBatchSize(4), WithMaxBatchWait(time.Minute))
+
+	images := []struct {
+		class      string
+		imageBytes []byte
+	}{
+		{"cats", solidPNG(t, color.RGBA{255, 0, 0, 255})},
+		{"non_cats", solidPNG(t, color.RGBA{0, 255, 0, 255})},
+		{"dogs", solidPNG(t, color.RGBA{0, 0, 255, 255})},
+		{"cats", solidPNG(t, color.RGBA{200, 10, 10, 255})},
+	}
+
+	var wg sync.WaitGroup
+	for _, img := range images {
+		wg.Add(1)
+		go func(expected string, imageBytes []byte) {
+			defer wg.Done()
+			result, err := service.PredictImage(context.Background(), imageBytes)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected, result.Class)
+			}
+		}(img.class, img.imageBytes)
+	}
+	wg.Wait()
+	assert.NoError(t, service.Stop())
+
+	close(batchSizes)
+	var sizes []int
+	for size := range batchSizes {
+		sizes = append(sizes, size)
+	}
+	assert.Equal(t, []int{4}, sizes)
+
+	stats := service.Stats()
+	assert.Equal(t, uint64(1), stats.Batches)
+	assert.Equal(t, 4.0, stats.AverageBatchSize)
+	assert.Equal(t, 4, stats.MaxBatchSize)
+}
+
+func Test_PredictImage_runs_partial_batch_after_max_wait(t *testing.T) {
+	batchSizes := make(chan int, 1)
+	service := newFakeService(dominantChannelInference(batchSizes),
+		WithWorkers(4), WithMaxBatchSize(4), WithMaxBatchWait(10*time.Millisecond))
+	defer service.Stop()
+
+	result, err := service.PredictImage(context.Background(), solidPNG(t, color.RGBA{0, 0, 255, 255}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "dogs", result.Class)
+	assert.Equal(t, 1, <-batchSizes)
+}
+
+func Test_PredictImage_batch_size_limited_by_workers(t *testing.T) {
+	service := newFakeService(fakeInference, WithWorkers(2), WithMaxBatchSize(8))
+	defer service.Stop()
+
+	assert.Equal(t, 2, service.Stats().MaxBatchSize)
+}
+
+func Test_PredictImage_batch_error(t *testing.T) {
+	errInference := errors.New("session failed")
+	service := newFakeService(func([][]float32) ([][]float32, error) { return nil, errInference },
+		WithWorkers(2), WithMaxBatchSize(2), WithMaxBatchWait(time.Minute))
+	defer service.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.PredictImage(context.Background(), pngTestImage(t))
+			assert.ErrorIs(t, err, errInference)
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_PredictImage_unexpected_number_of_predictions(t *testing.T) {
+	service := newFakeService(func([][]float32) ([][]float32, error) { return nil, nil })
+	defer service.Stop()
+
+	_, err := service.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.EqualError(t, err, "model returned 0 predictions for 1 images")
+}
+
+var simulatedSession sync.Mutex
+
+// simulatedInference stands in for a session run on CPU. A run uses all cores,
+// so concurrent runs are serialized, and it has a fixed cost per run on top
+// of the cost per image.
+func simulatedInference(inputs [][]float32) ([][]float32, error) {
+	const (
+		costPerRun   = 2 * time.Millisecond
+		costPerImage = 200 * time.Microsecond
+	)
+	simulatedSession.Lock()
+	time.Sleep(costPerRun + time.Duration(len(inputs))*costPerImage)
+	simulatedSession.Unlock()
+
+	return fakeInference(inputs)
+}
+
+func benchmarkBatching(b *testing.B, newService func(maxBatchSize int) *Service, imageBytes []byte) {
+	const concurrency = 16
+
+	for _, maxBatchSize := range []int{1, 4, 8, 16} {
+		b.Run(fmt.Sprintf("max_batch_size_%d", maxBatchSize), func(b *testing.B) {
+			service := newService(maxBatchSize)
+			defer service.Stop()
+
+			b.SetParallelism(concurrency)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := service.PredictImage(context.Background(), imageBytes); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "images/s")
+			b.ReportMetric(service.Stats().AverageBatchSize, "images/batch")
+		})
+	}
+}
+
+// BenchmarkPredictImage_batching_simulated compares the throughput of
+// concurrent predictions with and without batching
+func BenchmarkPredictImage_batching_simulated(b *testing.B) {
+	benchmarkBatching(b, func(maxBatchSize int) *Service {
+		return newFakeService(simulatedInference,
+			WithWorkers(16), WithQueueSize(1000), WithMaxBatchSize(maxBatchSize), WithMaxBatchWait(2*time.Millisecond))
+	}, pngTestImage(b))
+}
@@ -0,0 +1,291 @@
+package prediction
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CalibrationTemperature divides the logits of all classes by a single
+	// temperature before the softmax
+	CalibrationTemperature CalibrationMethod = "temperature"
+	// CalibrationPlatt fits a logistic regression on the logit of the second
+	// class, only for models with two classes
+	CalibrationPlatt CalibrationMethod = "platt"
+
+	// DefaultCalibrationBins is the number of bins used for the expected
+	// calibration error
+	DefaultCalibrationBins = 15
+
+	// probabilities are clamped to this before taking logarithms, the
+	// softmax of the model can return exact zeros
+	minCalibrationProbability = 1e-12
+
+	minTemperature     = 0.05
+	maxTemperature     = 20
+	temperatureSteps   = 100
+	plattMaxIterations = 100
+	plattTolerance     = 1e-10
+)
+
+// CalibrationMethod is the way probabilities are calibrated
+type CalibrationMethod string
+
+// ParseCalibrationMethod returns the calibration method with the given name
+func ParseCalibrationMethod(name string) (CalibrationMethod, error) {
+	switch method := CalibrationMethod(name); method {
+	case CalibrationTemperature, CalibrationPlatt:
+		return method, nil
+	}
+
+	return "", fmt.Errorf("unknown calibration method %q, use one of %s or %s", name, CalibrationTemperature, CalibrationPlatt)
+}
+
+// Calibration maps the probabilities of the model to ones that match how
+// often it is actually right. It is stored as json next to the labels.
+type Calibration struct {
+	Method CalibrationMethod `json:"method"`
+	// Temperature is used by CalibrationTemperature, above one it makes the
+	// model less confident
+	Temperature float64 `json:"temperature,omitempty"`
+	// A and B are used by CalibrationPlatt, the probability of the second
+	// class is sigmoid(A * logit + B)
+	A float64 `json:"a,omitempty"`
+	B float64 `json:"b,omitempty"`
+}
+
+// A CalibrationSample is the output of the uncalibrated model for an image
+// of a known class. Probabilities and Class are in label index order.
+type CalibrationSample struct {
+	Probabilities []float32
+	Class         int
+}
+
+// Validate checks that the calibration can be applied to a model with the
+// given number of classes
+func (c *Calibration) Validate(numClasses int) error {
+	switch c.Method {
+	case CalibrationTemperature:
+		if !(c.Temperature > 0) || math.IsInf(c.Temperature, 0) {
+			return errors.Errorf("temperature must be positive, got %v", c.Temperature)
+		}
+	case CalibrationPlatt:
+		if numClasses != 2 {
+			return errors.Errorf("platt scaling needs a model with 2 classes, got %d", numClasses)
+		}
+	default:
+		_, err := ParseCalibrationMethod(string(c.Method))
+		return err
+	}
+
+	return nil
+}
+
+// Apply returns the calibrated probabilities, in the same order
+func (c *Calibration) Apply(probabilities []float32) []float32 {
+	calibrated := make([]float32, len(probabilities))
+	for i, probability := range c.apply(toFloat64(probabilities)) {
+		calibrated[i] = float32(probability)
+	}
+	return calibrated
+}
+
+func (c *Calibration) apply(probabilities []float64) []float64 {
+	if c.Method == CalibrationPlatt {
+		return platt(probabilities, c.A, c.B)
+	}
+	return withTemperature(probabilities, c.Temperature)
+}
+
+// withTemperature is the softmax of the logits divided by the temperature.
+// The logits of a softmax output are the logarithms of its probabilities up
+// to a constant, which the softmax cancels out.
+func withTemperature(probabilities []float64, temperature float64) []float64 {
+	scaled := make([]float64, len(probabilities))
+	maxLogit := math.Inf(-1)
+	for i, probability := range probabilities {
+		scaled[i] = math.Log(math.Max(probability, minCalibrationProbability)) / temperature
+		maxLogit = math.Max(maxLogit, scaled[i])
+	}
+
+	var sum float64
+	for i := range scaled {
+		scaled[i] = math.Exp(scaled[i] - maxLogit)
+		sum += scaled[i]
+	}
+	for i := range scaled {
+		scaled[i] /= sum
+	}
+	return scaled
+}
+
+func platt(probabilities []float64, a, b float64) []float64 {
+	positive := sigmoid(a*binaryLogit(probabilities) + b)
+	return []float64{1 - positive, positive}
+}
+
+// binaryLogit is the logit of the second class of a two class model
+func binaryLogit(probabilities []float64) float64 {
+	return math.Log(math.Max(probabilities[1], minCalibrationProbability)) -
+		math.Log(math.Max(probabilities[0], minCalibrationProbability))
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// FitCalibration finds the calibration parameters that minimize the negative
+// log likelihood of the samples
+func FitCalibration(method CalibrationMethod, samples []CalibrationSample) (*Calibration, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples to fit the calibration on")
+	}
+
+	switch method {
+	case CalibrationTemperature:
+		return &Calibration{Method: method, Temperature: fitTemperature(samples)}, nil
+	case CalibrationPlatt:
+		for _, sample := range samples {
+			if len(sample.Probabilities) != 2 {
+				return nil, errors.Errorf("platt scaling needs a model with 2 classes, got %d", len(sample.Probabilities))
+			}
+		}
+		a, b := fitPlatt(samples)
+		return &Calibration{Method: method, A: a, B: b}, nil
+	}
+
+	_, err := ParseCalibrationMethod(string(method))
+	return nil, err
+}
+
+// fitTemperature runs a golden section search over the logarithm of the
+// temperature, the negative log likelihood is unimodal in it
+func fitTemperature(samples []CalibrationSample) float64 {
+	loss := func(logTemperature float64) float64 {
+		var nll float64
+		for _, sample := range samples {
+			calibrated := withTemperature(toFloat64(sample.Probabilities), math.Exp(logTemperature))
+			nll -= math.Log(math.Max(calibrated[sample.Class], minCalibrationProbability))
+		}
+		return nll
+	}
+
+	invPhi := (math.Sqrt(5) - 1) / 2
+	low, high := math.Log(minTemperature), math.Log(maxTemperature)
+	for i := 0; i < temperatureSteps; i++ {
+		left := high - invPhi*(high-low)
+		right := low + invPhi*(high-low)
+		if loss(left) < loss(right) {
+			high = right
+		} else {
+			low = left
+		}
+	}
+
+	return math.Exp((low + high) / 2)
+}
+
+// fitPlatt fits the logistic regression with newton's method. Like Platt did,
+// the targets are smoothed so that separable samples do not diverge.
+func fitPlatt(samples []CalibrationSample) (float64, float64) {
+	var positives, negatives float64
+	for _, sample := range samples {
+		if sample.Class == 1 {
+			positives++
+		} else {
+			negatives++
+		}
+	}
+	positiveTarget := (positives + 1) / (positives + 2)
+	negativeTarget := 1 / (negatives + 2)
+
+	logits := make([]float64, len(samples))
+	targets := make([]float64, len(samples))
+	for i, sample := range samples {
+		logits[i] = binaryLogit(toFloat64(sample.Probabilities))
+		targets[i] = negativeTarget
+		if sample.Class == 1 {
+			targets[i] = positiveTarget
+		}
+	}
+
+	a, b := 1.0, 0.0
+	for i := 0; i < plattMaxIterations; i++ {
+		var gradientA, gradientB, hessianAA, hessianAB, hessianBB float64
+		for j, logit := range logits {
+			p := sigmoid(a*logit + b)
+			gradientA += (p - targets[j]) * logit
+			gradientB += p - targets[j]
+			weight := math.Max(p*(1-p), 1e-12)
+			hessianAA += weight * logit * logit
+			hessianAB += weight * logit
+			hessianBB += weight
+		}
+		hessianAA += 1e-9
+		hessianBB += 1e-9
+
+		determinant := hessianAA*hessianBB - hessianAB*hessianAB
+		stepA := (hessianBB*gradientA - hessianAB*gradientB) / determinant
+		stepB := (hessianAA*gradientB - hessianAB*gradientA) / determinant
+		a -= stepA
+		b -= stepB
+
+		if math.Abs(stepA) < plattTolerance && math.Abs(stepB) < plattTolerance {
+			break
+		}
+	}
+
+	return a, b
+}
+
+// ExpectedCalibrationError is the difference between the confidence and the
+// accuracy of the predictions, averaged over equally wide confidence bins
+// weighted by the number of samples in them. A nil calibration measures the
+// raw probabilities.
+func ExpectedCalibrationError(calibration *Calibration, samples []CalibrationSample, bins int) float64 {
+	if len(samples) == 0 || bins <= 0 {
+		return 0
+	}
+
+	confidences := make([]float64, bins)
+	correct := make([]float64, bins)
+	counts := make([]float64, bins)
+	for _, sample := range samples {
+		probabilities := toFloat64(sample.Probabilities)
+		if calibration != nil {
+			probabilities = calibration.apply(probabilities)
+		}
+
+		predicted := 0
+		for i, probability := range probabilities {
+			if probability > probabilities[predicted] {
+				predicted = i
+			}
+		}
+
+		bin := min(int(probabilities[predicted]*float64(bins)), bins-1)
+		confidences[bin] += probabilities[predicted]
+		counts[bin]++
+		if predicted == sample.Class {
+			correct[bin]++
+		}
+	}
+
+	var ece float64
+	for bin := range counts {
+		if counts[bin] > 0 {
+			ece += math.Abs(correct[bin]-confidences[bin]) / float64(len(samples))
+		}
+	}
+	return ece
+}
+
+func toFloat64(values []float32) []float64 {
+	converted := make([]float64, len(values))
+	for i, value := range values {
+		converted[i] = float64(value)
+	}
+	return converted
+}
@@ -0,0 +1,102 @@
+package prediction
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// overconfidentSamples are predicted with 0.95, but only 70% are right
+func overconfidentSamples() []CalibrationSample {
+	var samples []CalibrationSample
+	for i := 0; i < 100; i++ {
+		class := 0
+		if i%10 >= 7 {
+			class = 1
+		}
+		samples = append(samples, CalibrationSample{Probabilities: []float32{0.95, 0.05}, Class: class})
+	}
+	return samples
+}
+
+func Test_FitCalibration_temperature(t *testing.T) {
+	samples := overconfidentSamples()
+
+	calibration, err := FitCalibration(CalibrationTemperature, samples)
+
+	assert.NoError(t, err)
+	assert.InDelta(t, 3.475, calibration.Temperature, 0.01)
+	assert.InDelta(t, 0.7, calibration.Apply([]float32{0.95, 0.05})[0], 1e-3)
+	assert.InDelta(t, 0.25, ExpectedCalibrationError(nil, samples, DefaultCalibrationBins), 1e-6)
+	assert.InDelta(t, 0, ExpectedCalibrationError(calibration, samples, DefaultCalibrationBins), 1e-3)
+}
+
+func Test_FitCalibration_platt(t *testing.T) {
+	var samples []CalibrationSample
+	for i := 0; i < 100; i++ {
+		samples = append(samples,
+			CalibrationSample{Probabilities: []float32{0.2, 0.8}, Class: min(1, i%5)},
+			CalibrationSample{Probabilities: []float32{0.8, 0.2}, Class: 1 - min(1, i%5)},
+		)
+	}
+
+	calibration, err := FitCalibration(CalibrationPlatt, samples)
+
+	assert.NoError(t, err)
+	assert.InDelta(t, 0, calibration.B, 0.01)
+	assert.InDelta(t, 0.8, calibration.Apply([]float32{0.2, 0.8})[1], 0.01)
+	assert.InDelta(t, 0.2, calibration.Apply([]float32{0.8, 0.2})[1], 0.01)
+}
+
+func Test_FitCalibration_invalid(t *testing.T) {
+	_, err := FitCalibration(CalibrationTemperature, nil)
+	assert.Error(t, err)
+
+	_, err = FitCalibration(CalibrationPlatt, []CalibrationSample{{Probabilities: []float32{0.6, 0.3, 0.1}}})
+	assert.ErrorContains(t, err, "2 classes")
+
+	_, err = FitCalibration("isotonic", overconfidentSamples())
+	assert.ErrorContains(t, err, "unknown calibration method")
+}
+
+func Test_Calibration_Apply_handles_zero_probabilities(t *testing.T) {
+	calibration := &Calibration{Method: CalibrationTemperature, Temperature: 2}
+
+	calibrated := calibration.Apply([]float32{1, 0, 0})
+
+	assert.InDelta(t, 1, calibrated[0], 1e-5)
+	assert.InDelta(t, 0, calibrated[1], 1e-5)
+}
+
+func Test_Calibration_Validate(t *testing.T) {
+	assert.NoError(t, (&Calibration{Method: CalibrationTemperature, Temperature: 1.5}).Validate(3))
+	assert.Error(t, (&Calibration{Method: CalibrationTemperature}).Validate(3))
+	assert.NoError(t, (&Calibration{Method: CalibrationPlatt, A: 0.5}).Validate(2))
+	assert.Error(t, (&Calibration{Method: CalibrationPlatt, A: 0.5}).Validate(3))
+	assert.Error(t, (&Calibration{Method: "isotonic"}).Validate(2))
+}
+
+func Test_NewServiceFromWeights_invalid_calibration(t *testing.T) {
+	for _, calibration := range []*Calibration{
+		{Method: CalibrationPlatt, A: 0.5},
+		{Method: CalibrationTemperature},
+	} {
+		_, err := NewServiceFromWeights(dominantChannelWeights(), testLabels, 4, WithCalibration(calibration))
+
+		assert.ErrorContains(t, err, errorTextInvalidCalibration)
+	}
+}
+
+func Test_PredictImage_calibrated(t *testing.T) {
+	calibration := &Calibration{Method: CalibrationTemperature, Temperature: 2}
+	service := newFakeService(constantInference(0.9, 0.05, 0.05), WithCalibration(calibration))
+	defer service.Stop()
+
+	result, err := service.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", result.Class)
+	assert.InDelta(t, 0.6796, result.Probability, 1e-4)
+	assert.Equal(t, ConfidenceUncertain, result.Confidence)
+}
@@ -0,0 +1,61 @@
+package prediction
+
+import "github.com/pkg/errors"
+
+const (
+	// ConfidenceConfident results are reported as they are
+	ConfidenceConfident Confidence = "confident"
+	// ConfidenceUncertain results are reported with a hint that the model
+	// might be wrong
+	ConfidenceUncertain Confidence = "uncertain"
+	// ConfidenceRejected results are too close to call, the predicted class
+	// should not be trusted
+	ConfidenceRejected Confidence = "rejected"
+
+	// DefaultConfidentThreshold is the probability from which a result is
+	// confident
+	DefaultConfidentThreshold = 0.9
+	// DefaultRejectedThreshold is the probability below which a result is
+	// rejected
+	DefaultRejectedThreshold = 0.6
+)
+
+// Confidence classifies how much the probability of a result can be trusted
+type Confidence string
+
+// Thresholds decide the confidence of a result from the probability of the
+// predicted class
+type Thresholds struct {
+	// Confident is the lowest probability of a confident result
+	Confident float32
+	// Rejected results have a probability below this one, everything in
+	// between is uncertain
+	Rejected float32
+}
+
+// DefaultThresholds returns the thresholds used when none are configured
+func DefaultThresholds() Thresholds {
+	return Thresholds{Confident: DefaultConfidentThreshold, Rejected: DefaultRejectedThreshold}
+}
+
+// Validate checks that both thresholds are probabilities and that rejected
+// results are less likely than confident ones
+func (t Thresholds) Validate() error {
+	if t.Rejected < 0 || t.Confident > 1 || t.Rejected > t.Confident {
+		return errors.Errorf("thresholds must satisfy 0 <= rejected <= confident <= 1, got rejected=%v and confident=%v", t.Rejected, t.Confident)
+	}
+
+	return nil
+}
+
+// Classify returns the confidence of a result with the given probability
+func (t Thresholds) Classify(probability float32) Confidence {
+	switch {
+	case probability >= t.Confident:
+		return ConfidenceConfident
+	case probability >= t.Rejected:
+		return ConfidenceUncertain
+	default:
+		return ConfidenceRejected
+	}
+}
@@ -0,0 +1,58 @@
+package prediction
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Thresholds_Classify(t *testing.T) {
+	thresholds := Thresholds{Confident: 0.9, Rejected: 0.6}
+
+	assert.Equal(t, ConfidenceConfident, thresholds.Classify(0.99))
+	assert.Equal(t, ConfidenceConfident, thresholds.Classify(0.9))
+	assert.Equal(t, ConfidenceUncertain, thresholds.Classify(0.75))
+	assert.Equal(t, ConfidenceUncertain, thresholds.Classify(0.6))
+	assert.Equal(t, ConfidenceRejected, thresholds.Classify(0.51))
+}
+
+func Test_Thresholds_Validate(t *testing.T) {
+	assert.NoError(t, DefaultThresholds().Validate())
+	assert.NoError(t, Thresholds{Confident: 0.5, Rejected: 0.5}.Validate())
+	assert.Error(t, Thresholds{Confident: 0.6, Rejected: 0.9}.Validate())
+	assert.Error(t, Thresholds{Confident: 1.1, Rejected: 0.6}.Validate())
+	assert.Error(t, Thresholds{Confident: 0.9, Rejected: -0.1}.Validate())
+}
+
+func Test_Result_String(t *testing.T) {
+	tests := []struct {
+		class      string
+		confidence Confidence
+		expected   string
+	}{
+		{"cats", ConfidenceConfident, "I'm pretty sure this is a cat"},
+		{"non_cats", ConfidenceConfident, "I'm pretty sure this is not a cat"},
+		{"cats", "", "I'm pretty sure this is a cat"},
+		{"cats", ConfidenceUncertain, "I think this is a cat, but I'm not sure"},
+		{"non_cats", ConfidenceUncertain, "I don't think this is a cat, but I'm not sure"},
+		{"cats", ConfidenceRejected, "I can't tell whether this is a cat"},
+		{"non_cats", ConfidenceRejected, "I can't tell whether this is a cat"},
+	}
+
+	for _, tt := range tests {
+		result := &Result{Class: tt.class, Confidence: tt.confidence}
+		assert.Equal(t, tt.expected, result.String())
+	}
+}
+
+func Test_PredictImage_confidence(t *testing.T) {
+	service := newFakeService(constantInference(0.7, 0.2, 0.1), WithThresholds(Thresholds{Confident: 0.8, Rejected: 0.5}))
+	defer service.Stop()
+
+	result, err := service.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ConfidenceUncertain, result.Confidence)
+	assert.Equal(t, "I think this is a cat, but I'm not sure", result.Message)
+}
@@ -0,0 +1,71 @@
+package prediction
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+const errorTextUnexpectedNumberOfEmbeddings = "model returned %d embeddings for %d images"
+
+// ErrEmbeddingUnsupported is returned by predictors that cannot extract
+// embeddings, because no embedding tensor is configured or the predictor
+// combines several models
+var ErrEmbeddingUnsupported = errors.New("the predictor cannot extract embeddings")
+
+// An Embedder maps images to vectors that are close for similar images
+type Embedder interface {
+	EmbedImage(ctx context.Context, imageBytes []byte) (*Embedding, error)
+}
+
+// An Embedding of an image. Only embeddings of the same model version can
+// be compared, another model maps images to unrelated vectors even if they
+// have the same length.
+type Embedding struct {
+	Vector       []float32
+	ModelVersion string
+}
+
+// An embeddingBackend can fetch an intermediate tensor of the model instead
+// of its output. The tensor is named like the backend names its layers.
+type embeddingBackend interface {
+	embed(inputs [][]float32, height, width int, tensor string) ([][]float32, error)
+}
+
+// EmbedImage returns the embedding tensor of the model for the image,
+// flattened. It is queued like a prediction and is not batched.
+func (s *Service) EmbedImage(ctx context.Context, imageBytes []byte) (*Embedding, error) {
+	model, ok := s.model.(embeddingBackend)
+	if s.embeddingTensor == "" || !ok {
+		return nil, ErrEmbeddingUnsupported
+	}
+
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var embedding []float32
+	_, err := s.pool.submit(ctx, func() (*Result, error) {
+		resizedImage, err := s.resizeImage(imageBytes)
+		if err != nil {
+			return nil, errors.Wrap(err, errorTextCouldNotProcessInputImage)
+		}
+
+		embeddings, err := model.embed([][]float32{imageToFloats(resizedImage)}, s.targetImageDimensions, s.targetImageDimensions, s.embeddingTensor)
+		if err != nil {
+			return nil, err
+		} else if len(embeddings) != 1 {
+			return nil, errors.Errorf(errorTextUnexpectedNumberOfEmbeddings, len(embeddings), 1)
+		}
+
+		embedding = embeddings[0]
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Embedding{Vector: embedding, ModelVersion: s.modelVersion}, nil
+}
@@ -0,0 +1,80 @@
+package prediction
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEmbeddingBackend embeds an image as the mean of its color channels
+type fakeEmbeddingBackend struct {
+	fakeBackend
+	tensors []string
+}
+
+func (f *fakeEmbeddingBackend) embed(inputs [][]float32, height, width int, tensor string) ([][]float32, error) {
+	f.tensors = append(f.tensors, tensor)
+	embeddings := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		embeddings[i] = make([]float32, rgbColorChannels)
+		for j, value := range input {
+			embeddings[i][j%rgbColorChannels] += value / float32(height*width)
+		}
+	}
+	return embeddings, nil
+}
+
+func newFakeEmbeddingService(options ...Option) (*Service, *fakeEmbeddingBackend) {
+	model := &fakeEmbeddingBackend{fakeBackend: fakeInference}
+	service := &Service{}
+	if err := service.init(testLabels, 16, model, options); err != nil {
+		panic(err)
+	}
+	return service, model
+}
+
+func Test_EmbedImage(t *testing.T) {
+	service, model := newFakeEmbeddingService(WithEmbedding("dense_1/Relu"), WithModelVersion("v2"))
+	defer service.Stop()
+
+	pixel := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	pixel.Set(0, 0, color.RGBA{255, 200, 0, 255})
+
+	embedding, err := service.EmbedImage(context.Background(), solidPNG(t, color.RGBA{255, 200, 0, 255}))
+
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, imageToFloats(pixel), embedding.Vector, 1e-3)
+	assert.Equal(t, "v2", embedding.ModelVersion)
+	assert.Equal(t, []string{"dense_1/Relu"}, model.tensors)
+}
+
+func Test_EmbedImage_disabled(t *testing.T) {
+	service, model := newFakeEmbeddingService()
+	defer service.Stop()
+
+	_, err := service.EmbedImage(context.Background(), pngTestImage(t))
+
+	assert.ErrorIs(t, err, ErrEmbeddingUnsupported)
+	assert.Empty(t, model.tensors)
+}
+
+func Test_EmbedImage_unsupported_backend(t *testing.T) {
+	service := newFakeService(fakeInference, WithEmbedding("dense_1/Relu"))
+	defer service.Stop()
+
+	_, err := service.EmbedImage(context.Background(), pngTestImage(t))
+
+	assert.ErrorIs(t, err, ErrEmbeddingUnsupported)
+}
+
+func Test_EmbedImage_invalid_image(t *testing.T) {
+	service, _ := newFakeEmbeddingService(WithEmbedding("dense_1/Relu"))
+	defer service.Stop()
+
+	_, err := service.EmbedImage(context.Background(), []byte("no image"))
+
+	assert.ErrorIs(t, err, ErrUnsupportedImageFormat)
+}
@@ -0,0 +1,210 @@
+package prediction
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// StrategyAverage averages the probabilities of all models
+	StrategyAverage Strategy = "average"
+	// StrategyWeightedAverage averages the probabilities weighted by the
+	// weight of each model
+	StrategyWeightedAverage Strategy = "weighted-average"
+	// StrategyMajorityVote predicts the class most models predicted. Ties
+	// are broken by the average probability.
+	StrategyMajorityVote Strategy = "majority-vote"
+
+	errorTextEnsembleMemberFailed = "model %d of the ensemble failed"
+)
+
+// ErrLabelMismatch is returned when the models of an ensemble do not predict
+// the same classes
+var ErrLabelMismatch = errors.New("models of the ensemble have different labels")
+
+// Strategy decides how the predictions of the models of an ensemble are
+// combined
+type Strategy string
+
+// ParseStrategy returns the strategy with the given name
+func ParseStrategy(name string) (Strategy, error) {
+	switch strategy := Strategy(name); strategy {
+	case StrategyAverage, StrategyWeightedAverage, StrategyMajorityVote:
+		return strategy, nil
+	}
+
+	return "", fmt.Errorf("unknown ensemble strategy %q, use one of %s, %s or %s", name, StrategyAverage, StrategyWeightedAverage, StrategyMajorityVote)
+}
+
+// An EnsembleMember is one of the models of an ensemble. The weight is only
+// used by StrategyWeightedAverage.
+type EnsembleMember struct {
+	Service *Service
+	Weight  float32
+}
+
+// Ensemble predicts images with several services concurrently and combines
+// their probabilities. It can be used wherever a single Service is used.
+type Ensemble struct {
+	strategy   Strategy
+	members    []EnsembleMember
+	labels     []Label
+	version    string
+	thresholds Thresholds
+}
+
+// NewEnsemble creates an ensemble of the given services. All of them have to
+// predict the same classes at the same indices. The confidence of the combined
+// result is decided by the thresholds of the first service.
+func NewEnsemble(strategy Strategy, members ...EnsembleMember) (*Ensemble, error) {
+	if _, err := ParseStrategy(string(strategy)); err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, errors.New("an ensemble needs at least one model")
+	}
+
+	labels := members[0].Service.labels
+	versions := make([]string, 0, len(members))
+	for i, member := range members {
+		if !sameClasses(labels, member.Service.labels) {
+			return nil, errors.Wrapf(ErrLabelMismatch, "model %d differs from model 0", i)
+		}
+		if strategy == StrategyWeightedAverage && member.Weight <= 0 {
+			return nil, errors.Errorf("the weight of model %d must be positive, got %v", i, member.Weight)
+		}
+		if member.Service.modelVersion != "" {
+			versions = append(versions, member.Service.modelVersion)
+		}
+	}
+
+	return &Ensemble{
+		strategy:   strategy,
+		members:    members,
+		labels:     labels,
+		version:    strings.Join(versions, "+"),
+		thresholds: members[0].Service.thresholds,
+	}, nil
+}
+
+// PredictImage with all models of the ensemble. It fails if any of them
+// fails.
+func (e *Ensemble) PredictImage(ctx context.Context, imageBytes []byte) (*Result, error) {
+	results := make([]*Result, len(e.members))
+	errs := make([]error, len(e.members))
+
+	var wg sync.WaitGroup
+	for i, member := range e.members {
+		wg.Add(1)
+		go func(i int, service *Service) {
+			defer wg.Done()
+			results[i], errs[i] = service.PredictImage(ctx, imageBytes)
+		}(i, member.Service)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, errors.Wrapf(err, errorTextEnsembleMemberFailed, i)
+		}
+	}
+
+	result := e.combine(results)
+	result.ModelVersion = e.version
+	result.classify(e.thresholds)
+
+	return result, nil
+}
+
+// combine merges the results of the members into a single result
+func (e *Ensemble) combine(results []*Result) *Result {
+	numClasses := len(e.labels)
+	average := make([]float32, numClasses)
+	votes := make([]float32, numClasses)
+
+	var totalWeight float32
+	for i, result := range results {
+		weight := float32(1)
+		if e.strategy == StrategyWeightedAverage {
+			weight = e.members[i].Weight
+		}
+		totalWeight += weight
+
+		probabilities := result.Probabilities(e.labels)
+		for class := range average {
+			average[class] += weight * probabilities[class]
+		}
+		if index := e.classIndex(result.Class); index >= 0 {
+			votes[index]++
+		}
+	}
+	for class := range average {
+		average[class] /= totalWeight
+	}
+
+	if e.strategy != StrategyMajorityVote {
+		return newResult(average, e.labels)
+	}
+
+	for class := range votes {
+		votes[class] /= float32(len(results))
+	}
+	result := newResult(votes, e.labels)
+
+	averageByClass := make(map[string]float32, numClasses)
+	for class, probability := range average {
+		averageByClass[classNameForIndex(e.labels, class)] = probability
+	}
+	sort.SliceStable(result.Scores, func(i, j int) bool {
+		if result.Scores[i].Probability != result.Scores[j].Probability {
+			return result.Scores[i].Probability > result.Scores[j].Probability
+		}
+		return averageByClass[result.Scores[i].Class] > averageByClass[result.Scores[j].Class]
+	})
+	result.Class = result.Scores[0].Class
+	result.Probability = result.Scores[0].Probability
+
+	return result
+}
+
+func (e *Ensemble) classIndex(class string) int {
+	for i := range e.labels {
+		if classNameForIndex(e.labels, i) == class {
+			return i
+		}
+	}
+	return -1
+}
+
+// ModelVersion returns the versions of the models joined by "+"
+func (e *Ensemble) ModelVersion(context.Context) string {
+	return e.version
+}
+
+// Stop stops all models of the ensemble and returns the first error
+func (e *Ensemble) Stop() error {
+	var firstErr error
+	for i, member := range e.members {
+		if err := member.Service.Stop(); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, errorTextEnsembleMemberFailed, i)
+		}
+	}
+	return firstErr
+}
+
+func sameClasses(a, b []Label) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if classNameForIndex(a, i) != classNameForIndex(b, i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package prediction
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// constantInference predicts the same probabilities for every image
+func constantInference(probabilities ...float32) func([][]float32) ([][]float32, error) {
+	return func(inputs [][]float32) ([][]float32, error) {
+		predictions := make([][]float32, len(inputs))
+		for i := range predictions {
+			predictions[i] = probabilities
+		}
+		return predictions, nil
+	}
+}
+
+func newTestEnsemble(t *testing.T, strategy Strategy, members ...EnsembleMember) *Ensemble {
+	ensemble, err := NewEnsemble(strategy, members...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ensemble.Stop() })
+	return ensemble
+}
+
+func member(weight float32, probabilities ...float32) EnsembleMember {
+	return EnsembleMember{Service: newFakeService(constantInference(probabilities...)), Weight: weight}
+}
+
+func Test_Ensemble_average(t *testing.T) {
+	ensemble := newTestEnsemble(t, StrategyAverage,
+		member(1, 0.6, 0.3, 0.1),
+		member(5, 0.2, 0.7, 0.1),
+	)
+
+	result, err := ensemble.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "non_cats", result.Class)
+	assert.InDelta(t, 0.5, result.Probability, 1e-6)
+	assert.Equal(t, []string{"non_cats", "cats", "dogs"}, classes(result.Scores))
+	assert.InDelta(t, 0.4, result.Scores[1].Probability, 1e-6)
+}
+
+func Test_Ensemble_weighted_average(t *testing.T) {
+	ensemble := newTestEnsemble(t, StrategyWeightedAverage,
+		member(3, 0.6, 0.3, 0.1),
+		member(1, 0.2, 0.7, 0.1),
+	)
+
+	result, err := ensemble.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", result.Class)
+	assert.InDelta(t, 0.5, result.Probability, 1e-6)
+}
+
+func Test_Ensemble_majority_vote(t *testing.T) {
+	ensemble := newTestEnsemble(t, StrategyMajorityVote,
+		member(1, 0.4, 0.35, 0.25),
+		member(1, 0.45, 0.1, 0.45),
+		member(1, 0.1, 0.9, 0.0),
+	)
+
+	result, err := ensemble.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", result.Class)
+	assert.InDelta(t, 2.0/3, result.Probability, 1e-6)
+}
+
+func Test_Ensemble_majority_vote_tie(t *testing.T) {
+	ensemble := newTestEnsemble(t, StrategyMajorityVote,
+		member(1, 0.6, 0.4, 0.0),
+		member(1, 0.1, 0.9, 0.0),
+	)
+
+	result, err := ensemble.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "non_cats", result.Class)
+	assert.InDelta(t, 0.5, result.Probability, 1e-6)
+}
+
+func Test_Ensemble_member_fails(t *testing.T) {
+	errMock := errors.New("everything went to hell")
+	failing := newFakeService(func([][]float32) ([][]float32, error) { return nil, errMock })
+	ensemble := newTestEnsemble(t, StrategyAverage,
+		member(1, 0.6, 0.3, 0.1),
+		EnsembleMember{Service: failing},
+	)
+
+	_, err := ensemble.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.ErrorIs(t, err, errMock)
+	assert.ErrorContains(t, err, "model 1 of the ensemble failed")
+}
+
+func Test_Ensemble_model_version(t *testing.T) {
+	ensemble := newTestEnsemble(t, StrategyAverage,
+		EnsembleMember{Service: newFakeService(fakeInference, WithModelVersion("v1"))},
+		EnsembleMember{Service: newFakeService(fakeInference, WithModelVersion("v2"))},
+	)
+
+	result, err := ensemble.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "v1+v2", result.ModelVersion)
+}
+
+func Test_NewEnsemble_label_mismatch(t *testing.T) {
+	other := &Service{}
+	assert.NoError(t, other.init([]Label{{Index: 0, ClassName: "non_cats"}, {Index: 1, ClassName: "cats"}, {Index: 2, ClassName: "dogs"}}, 16, fakeBackend(fakeInference), nil))
+	defer other.Stop()
+	service := newFakeService(fakeInference)
+	defer service.Stop()
+
+	_, err := NewEnsemble(StrategyAverage, EnsembleMember{Service: service}, EnsembleMember{Service: other})
+
+	assert.ErrorIs(t, err, ErrLabelMismatch)
+}
+
+func Test_NewEnsemble_invalid(t *testing.T) {
+	service := newFakeService(fakeInference)
+	defer service.Stop()
+
+	_, err := NewEnsemble(StrategyAverage)
+	assert.Error(t, err)
+
+	_, err = NewEnsemble("median", EnsembleMember{Service: service})
+	assert.Error(t, err)
+
+	_, err = NewEnsemble(StrategyWeightedAverage, EnsembleMember{Service: service})
+	assert.ErrorContains(t, err, "must be positive")
+}
+
+func classes(scores []ClassScore) []string {
+	names := make([]string, 0, len(scores))
+	for _, score := range scores {
+		names = append(names, score.Class)
+	}
+	return names
+}
+
+func Test_Ensemble_confidence(t *testing.T) {
+	ensemble := newTestEnsemble(t, StrategyMajorityVote,
+		member(1, 0.9, 0.1, 0.0),
+		member(1, 0.1, 0.9, 0.0),
+		member(1, 0.1, 0.0, 0.9),
+	)
+
+	result, err := ensemble.PredictImage(context.Background(), pngTestImage(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ConfidenceRejected, result.Confidence)
+	assert.Equal(t, "I can't tell whether this is a cat", result.Message)
+}
@@ -0,0 +1,238 @@
+package prediction
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// the default patch covers a quarter of the side of the model input and
+	// moves by half of its size, 49 occlusions for 256x256
+	defaultOcclusionPatchFraction = 4
+	occlusionGrey                 = 128
+	// how strongly the heatmap covers the image where it is hottest
+	overlayOpacity = 0.6
+
+	errorTextCouldNotEncodeOverlay = "could not encode heatmap overlay"
+)
+
+// ErrExplanationUnsupported is returned by predictors that cannot explain
+// their predictions, like ensembles
+var ErrExplanationUnsupported = errors.New("the predictor cannot explain its predictions")
+
+// An Explainer explains why an image was predicted as it was
+type Explainer interface {
+	ExplainImage(ctx context.Context, imageBytes []byte) (*Explanation, error)
+}
+
+// An Explanation shows which parts of the image the prediction depends on.
+// The heatmap is computed by occlusion sensitivity: a grey patch is slid over
+// the resized image and the drop of the probability of the predicted class is
+// measured at every position.
+type Explanation struct {
+	Result *Result
+	// Size is the width and height of the resized image and the heatmap
+	Size int
+	// Heatmap holds the importance of every pixel in row major order, from 0
+	// for pixels whose occlusion did not matter to 1 for the most important
+	Heatmap []float32
+	// Overlay is the resized image with the heatmap drawn over it as PNG
+	Overlay []byte
+}
+
+// ExplainImage predicts the image and computes the occlusion heatmap of the
+// predicted class. It is queued like a prediction and takes a single worker,
+// but runs the model once for every position of the patch, in batches of the
+// maximum batch size.
+func (s *Service) ExplainImage(ctx context.Context, imageBytes []byte) (*Explanation, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var explanation *Explanation
+	_, err := s.pool.submit(ctx, func() (*Result, error) {
+		var err error
+		explanation, err = s.explain(ctx, imageBytes)
+		return nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return explanation, nil
+}
+
+func (s *Service) explain(ctx context.Context, imageBytes []byte) (*Explanation, error) {
+	resizedImage, err := s.resizeImage(imageBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotProcessInputImage)
+	}
+	input := imageToFloats(resizedImage)
+
+	scores, err := s.batcher.predict(input)
+	if err != nil {
+		return nil, err
+	}
+	predicted := 0
+	for i, score := range scores {
+		if score > scores[predicted] {
+			predicted = i
+		}
+	}
+
+	size := s.targetImageDimensions
+	patch, stride := s.occlusionPatch()
+	offsets := occlusionOffsets(size, patch, stride)
+	grey := imageToFloats(greyPixel())
+
+	occluded := make([][]float32, 0, len(offsets)*len(offsets))
+	for _, y := range offsets {
+		for _, x := range offsets {
+			occluded = append(occluded, occlude(input, size, x, y, patch, grey))
+		}
+	}
+
+	drops := make([]float32, 0, len(occluded))
+	batchSize := max(1, s.maxBatchSize)
+	for start := 0; start < len(occluded); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		predictions, err := s.runBatch(occluded[start:min(start+batchSize, len(occluded))])
+		if err != nil {
+			return nil, err
+		}
+		for _, prediction := range predictions {
+			drops = append(drops, max(0, scores[predicted]-prediction[predicted]))
+		}
+	}
+
+	heatmap := occlusionHeatmap(drops, offsets, size, patch)
+	overlay, err := encodeOverlay(resizedImage, heatmap)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Explanation{
+		Result:  s.newResult(scores),
+		Size:    size,
+		Heatmap: heatmap,
+		Overlay: overlay,
+	}, nil
+}
+
+// occlusionPatch returns the size of the grey patch and how far it moves
+func (s *Service) occlusionPatch() (int, int) {
+	patch := s.occlusionPatchSize
+	if patch <= 0 {
+		patch = s.targetImageDimensions / defaultOcclusionPatchFraction
+	}
+	patch = min(max(1, patch), s.targetImageDimensions)
+
+	stride := s.occlusionStride
+	if stride <= 0 {
+		stride = patch / 2
+	}
+
+	return patch, max(1, stride)
+}
+
+// occlusionOffsets are the positions of the patch along one side. The last
+// one is moved to the edge so the whole image is covered.
+func occlusionOffsets(size, patch, stride int) []int {
+	var offsets []int
+	for offset := 0; offset+patch <= size; offset += stride {
+		offsets = append(offsets, offset)
+	}
+	if last := size - patch; offsets[len(offsets)-1] != last {
+		offsets = append(offsets, last)
+	}
+	return offsets
+}
+
+func greyPixel() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.Gray{Y: occlusionGrey})
+	return img
+}
+
+// occlude returns a copy of the input with the patch at x, y set to grey
+func occlude(input []float32, size, x, y, patch int, grey []float32) []float32 {
+	occluded := append([]float32(nil), input...)
+	for row := y; row < y+patch; row++ {
+		for column := x; column < x+patch; column++ {
+			copy(occluded[(row*size+column)*rgbColorChannels:], grey)
+		}
+	}
+	return occluded
+}
+
+// occlusionHeatmap averages the drops of all patches covering a pixel and
+// scales them to the largest one
+func occlusionHeatmap(drops []float32, offsets []int, size, patch int) []float32 {
+	sums := make([]float32, size*size)
+	counts := make([]float32, size*size)
+	for i, y := range offsets {
+		for j, x := range offsets {
+			drop := drops[i*len(offsets)+j]
+			for row := y; row < y+patch; row++ {
+				for column := x; column < x+patch; column++ {
+					sums[row*size+column] += drop
+					counts[row*size+column]++
+				}
+			}
+		}
+	}
+
+	var maximum float32
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= counts[i]
+		}
+		maximum = max(maximum, sums[i])
+	}
+	if maximum > 0 {
+		for i := range sums {
+			sums[i] /= maximum
+		}
+	}
+
+	return sums
+}
+
+// encodeOverlay blends the heatmap from yellow to red over the image
+func encodeOverlay(img *image.RGBA, heatmap []float32) ([]byte, error) {
+	bounds := img.Bounds()
+	overlay := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			heat := heatmap[y*bounds.Dx()+x]
+			alpha := overlayOpacity * heat
+			pixel := img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
+			overlay.SetRGBA(x, y, color.RGBA{
+				R: blend(pixel.R, 255, alpha),
+				G: blend(pixel.G, 255*(1-heat), alpha),
+				B: blend(pixel.B, 0, alpha),
+				A: 255,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, overlay); err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotEncodeOverlay)
+	}
+	return buf.Bytes(), nil
+}
+
+func blend(value uint8, target, alpha float32) uint8 {
+	return uint8(float32(value)*(1-alpha) + target*alpha + 0.5)
+}
@@ -0,0 +1,91 @@
+package prediction
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// topLeftInference predicts cats by the share of red pixels in the top left
+// 4x4 corner of the 16x16 input, the rest of the image does not matter
+func topLeftInference(inputs [][]float32) ([][]float32, error) {
+	predictions := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		var red float32
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				if input[(y*16+x)*rgbColorChannels] > 100 {
+					red++
+				}
+			}
+		}
+		cats := 0.1 + 0.8*red/16
+		predictions[i] = []float32{cats, 0.9 - cats, 0.1}
+	}
+	return predictions, nil
+}
+
+func Test_ExplainImage(t *testing.T) {
+	service := newFakeService(topLeftInference, WithOcclusion(4, 4), WithMaxBatchSize(3), WithWorkers(3))
+	defer service.Stop()
+
+	explanation, err := service.ExplainImage(context.Background(), solidPNG(t, color.RGBA{255, 200, 200, 255}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cats", explanation.Result.Class)
+	assert.InDelta(t, 0.9, explanation.Result.Probability, 1e-6)
+	assert.Equal(t, 16, explanation.Size)
+	assert.Len(t, explanation.Heatmap, 16*16)
+	assert.Equal(t, float32(1), explanation.Heatmap[0])
+	assert.Equal(t, float32(1), explanation.Heatmap[3*16+3])
+	assert.Equal(t, float32(0), explanation.Heatmap[4*16+4])
+	assert.Equal(t, float32(0), explanation.Heatmap[15*16+15])
+
+	overlay, err := png.Decode(bytes.NewReader(explanation.Overlay))
+	if assert.NoError(t, err) {
+		assert.Equal(t, 16, overlay.Bounds().Dx())
+		r, g, b, _ := overlay.At(15, 15).RGBA()
+		assert.Equal(t, []uint32{255, 200, 200}, []uint32{r >> 8, g >> 8, b >> 8})
+		r, g, b, _ = overlay.At(0, 0).RGBA()
+		assert.Equal(t, []uint32{255, 80, 80}, []uint32{r >> 8, g >> 8, b >> 8})
+	}
+}
+
+func Test_ExplainImage_invalid_image(t *testing.T) {
+	service := newFakeService(topLeftInference)
+	defer service.Stop()
+
+	_, err := service.ExplainImage(context.Background(), []byte("no image"))
+
+	assert.ErrorIs(t, err, ErrUnsupportedImageFormat)
+}
+
+func Test_ExplainImage_stopped(t *testing.T) {
+	service := newFakeService(topLeftInference)
+	service.Stop()
+
+	_, err := service.ExplainImage(context.Background(), pngTestImage(t))
+
+	assert.ErrorIs(t, err, ErrStopped)
+}
+
+func Test_occlusionOffsets(t *testing.T) {
+	assert.Equal(t, []int{0, 32, 64, 96, 128, 160, 192}, occlusionOffsets(256, 64, 32))
+	assert.Equal(t, []int{0, 4, 6}, occlusionOffsets(10, 4, 4))
+	assert.Equal(t, []int{0}, occlusionOffsets(16, 16, 8))
+}
+
+func Test_occlusionHeatmap(t *testing.T) {
+	// two overlapping patches of 2 in a 3 pixel wide image
+	heatmap := occlusionHeatmap([]float32{0.4, 0, 0, 0}, []int{0, 1}, 3, 2)
+
+	assert.InDeltaSlice(t, []float32{
+		1, 0.5, 0,
+		0.5, 0.25, 0,
+		0, 0, 0,
+	}, heatmap, 1e-6)
+}
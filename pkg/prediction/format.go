@@ -0,0 +1,62 @@
+package prediction
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/pkg/errors"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	// ErrUnsupportedImageFormat is returned for images that are not JPEG, PNG, GIF, WebP or BMP
+	ErrUnsupportedImageFormat = errors.New("unsupported image format")
+	// ErrInvalidImage is returned for images of a supported format that cannot be decoded
+	ErrInvalidImage = errors.New("invalid image")
+)
+
+// the formats registered with the image package above
+var supportedImageFormats = map[string]bool{
+	"jpeg": true,
+	"png":  true,
+	"gif":  true,
+	"webp": true,
+	"bmp":  true,
+}
+
+// DetectImageFormat returns the format of the encoded image. The format is
+// detected from the content, not from any file name or mime type.
+func DetectImageFormat(imageBytes []byte) (string, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(imageBytes))
+	if errors.Is(err, image.ErrFormat) || (err == nil && !supportedImageFormats[format]) {
+		return "", ErrUnsupportedImageFormat
+	} else if err != nil {
+		return "", errors.Wrap(ErrInvalidImage, err.Error())
+	}
+
+	return format, nil
+}
+
+// decodeImage decodes the image, for animated gifs only the first frame
+func decodeImage(imageBytes []byte) (image.Image, error) {
+	if _, err := DetectImageFormat(imageBytes); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidImage, err.Error())
+	}
+
+	return img, nil
+}
+
+// IsImageError reports whether err was caused by the uploaded image itself
+// rather than by the service
+func IsImageError(err error) bool {
+	return errors.Is(err, ErrUnsupportedImageFormat) || errors.Is(err, ErrInvalidImage)
+}
@@ -0,0 +1,81 @@
+package prediction
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+)
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 32), uint8(y * 32), 128, 255})
+		}
+	}
+	return img
+}
+
+func encodeTestImage(t testing.TB, encode func(*bytes.Buffer, image.Image) error) []byte {
+	var buf bytes.Buffer
+	if err := encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_DetectImageFormat(t *testing.T) {
+	webpBytes, err := os.ReadFile("testdata/gopher.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		imageBytes []byte
+		format     string
+	}{
+		{"jpeg", encodeTestImage(t, func(b *bytes.Buffer, i image.Image) error { return jpeg.Encode(b, i, nil) }), "jpeg"},
+		{"png", encodeTestImage(t, func(b *bytes.Buffer, i image.Image) error { return png.Encode(b, i) }), "png"},
+		{"gif", encodeTestImage(t, func(b *bytes.Buffer, i image.Image) error { return gif.Encode(b, i, nil) }), "gif"},
+		{"bmp", encodeTestImage(t, func(b *bytes.Buffer, i image.Image) error { return bmp.Encode(b, i) }), "bmp"},
+		{"webp", webpBytes, "webp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := DetectImageFormat(tt.imageBytes)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+
+			img, err := decodeImage(tt.imageBytes)
+			assert.NoError(t, err)
+			assert.NotNil(t, img)
+		})
+	}
+}
+
+func Test_DetectImageFormat_unsupported(t *testing.T) {
+	_, err := DetectImageFormat([]byte("this is not an image at all"))
+
+	assert.ErrorIs(t, err, ErrUnsupportedImageFormat)
+	assert.True(t, IsImageError(err))
+}
+
+func Test_decodeImage_truncated(t *testing.T) {
+	pngBytes := encodeTestImage(t, func(b *bytes.Buffer, i image.Image) error { return png.Encode(b, i) })
+
+	_, err := decodeImage(pngBytes[:len(pngBytes)/2])
+
+	assert.ErrorIs(t, err, ErrInvalidImage)
+	assert.True(t, IsImageError(err))
+}
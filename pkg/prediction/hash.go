@@ -0,0 +1,61 @@
+package prediction
+
+import (
+	"image"
+	"image/color"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// the difference hash compares neighbouring pixels of a grey 9x8 thumbnail
+const (
+	hashWidth  = 9
+	hashHeight = 8
+)
+
+// PerceptualHash returns the difference hash of the image. Every bit tells
+// whether a pixel of a grey 9x8 thumbnail is brighter than its right
+// neighbour, so re-encoded, resized or slightly edited copies of an image
+// have hashes that differ in only a few bits. The exif orientation is applied
+// first, like for predictions.
+func PerceptualHash(imageBytes []byte) (uint64, error) {
+	src, err := decodeImage(imageBytes)
+	if err != nil {
+		return 0, err
+	}
+	src = applyOrientation(src, exifOrientation(imageBytes))
+
+	thumbnail := image.NewGray(image.Rect(0, 0, hashWidth, hashHeight))
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Rect, toGray(src), src.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if thumbnail.GrayAt(x, y).Y > thumbnail.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash, nil
+}
+
+// toGray converts the image before scaling, so that the thumbnail averages
+// brightness rather than colors
+func toGray(src image.Image) *image.Gray {
+	bounds := src.Bounds()
+	gray := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			gray.Set(x, y, color.GrayModel.Convert(src.At(x, y)))
+		}
+	}
+	return gray
+}
+
+// HammingDistance is the number of bits in which two hashes differ
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,77 @@
+package prediction
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// patternImage has a brightness that rises and falls along x, with a few
+// blocks so that the rows differ
+func patternImage(width, height int, negative bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			brightness := uint8(255 * x / width)
+			if (x*4/width+y*3/height)%2 == 0 {
+				brightness = 255 - brightness
+			}
+			if negative {
+				brightness = 255 - brightness
+			}
+			img.Set(x, y, color.RGBA{brightness, brightness / 2, 255 - brightness, 255})
+		}
+	}
+	return img
+}
+
+func encodePattern(t *testing.T, img image.Image, encode func(*bytes.Buffer, image.Image) error) []byte {
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_PerceptualHash_near_duplicates(t *testing.T) {
+	encodePNG := func(b *bytes.Buffer, i image.Image) error { return png.Encode(b, i) }
+	encodeJPEG := func(b *bytes.Buffer, i image.Image) error { return jpeg.Encode(b, i, &jpeg.Options{Quality: 60}) }
+
+	original, err := PerceptualHash(encodePattern(t, patternImage(180, 120, false), encodePNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reencoded, err := PerceptualHash(encodePattern(t, patternImage(180, 120, false), encodeJPEG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resized, err := PerceptualHash(encodePattern(t, patternImage(90, 60, false), encodePNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	negative, err := PerceptualHash(encodePattern(t, patternImage(180, 120, true), encodePNG))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.LessOrEqual(t, HammingDistance(original, reencoded), 3)
+	assert.LessOrEqual(t, HammingDistance(original, resized), 3)
+	assert.Greater(t, HammingDistance(original, negative), 48)
+}
+
+func Test_PerceptualHash_invalid_image(t *testing.T) {
+	_, err := PerceptualHash([]byte("no image"))
+
+	assert.ErrorIs(t, err, ErrUnsupportedImageFormat)
+}
+
+func Test_HammingDistance(t *testing.T) {
+	assert.Equal(t, 0, HammingDistance(0xff00, 0xff00))
+	assert.Equal(t, 2, HammingDistance(0b1010, 0b0110))
+	assert.Equal(t, 64, HammingDistance(0, ^uint64(0)))
+}
@@ -1,6 +1,6 @@
 package prediction
 
-import "fmt"
+import "context"
 
 // the Input for the prediction
 type Input struct {
@@ -9,20 +9,80 @@ type Input struct {
 
 // the Result of the prediction
 type Result struct {
+	Class        string       `json:"class"`
+	Probability  float32      `json:"probability"`
+	Scores       []ClassScore `json:"scores"`
+	ModelVersion string       `json:"modelVersion,omitempty"`
+	Confidence   Confidence   `json:"confidence,omitempty"`
+	// Message phrases the result for humans, see String
+	Message string `json:"message,omitempty"`
+}
+
+// A Versioned predictor tells the version of the model that predicts the
+// image. Wrappers choosing between models per image read the image id from
+// the context.
+type Versioned interface {
+	ModelVersion(ctx context.Context) string
+}
+
+// A ClassScore is the probability the model assigned to a single class
+type ClassScore struct {
 	Class       string  `json:"class"`
 	Probability float32 `json:"probability"`
 }
 
+// TopK returns the k classes with the highest probability
+func (r *Result) TopK(k int) []ClassScore {
+	if k < 0 {
+		k = 0
+	}
+	if k > len(r.Scores) {
+		k = len(r.Scores)
+	}
+
+	return r.Scores[:k]
+}
+
+// Probabilities returns the probabilities of the result in label index order
+func (r *Result) Probabilities(labels []Label) []float32 {
+	probabilities := make([]float32, len(labels))
+	for i := range probabilities {
+		class := classNameForIndex(labels, i)
+		for _, score := range r.Scores {
+			if score.Class == class {
+				probabilities[i] = score.Probability
+				break
+			}
+		}
+	}
+	return probabilities
+}
+
+// String phrases the result depending on its confidence. Results without a
+// confidence are phrased as confident ones.
 func (r *Result) String() string {
 	isCat := r.Class == "cats"
-	var suffix string
+
+	switch r.Confidence {
+	case ConfidenceRejected:
+		return "I can't tell whether this is a cat"
+	case ConfidenceUncertain:
+		if isCat {
+			return "I think this is a cat, but I'm not sure"
+		}
+		return "I don't think this is a cat, but I'm not sure"
+	}
+
 	if isCat {
-		suffix = "this is a cat"
-	} else {
-		suffix = "this is not a cat"
+		return "I'm pretty sure this is a cat"
 	}
+	return "I'm pretty sure this is not a cat"
+}
 
-	return fmt.Sprintf("I'm pretty sure %s", suffix)
+// classify sets the confidence of the result and phrases it accordingly
+func (r *Result) classify(thresholds Thresholds) {
+	r.Confidence = thresholds.Classify(r.Probability)
+	r.Message = r.String()
 }
 
 // the ErrorResult of the prediction
@@ -0,0 +1,43 @@
+package prediction
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	// FormatFrozenGraph is a tensorflow GraphDef with the variables frozen
+	// into constants
+	FormatFrozenGraph ModelFormat = "frozen-graph"
+	// FormatSavedModel is a tensorflow SavedModel directory
+	FormatSavedModel ModelFormat = "saved-model"
+	// FormatWeights is the weights format run in pure Go, see network.go
+	FormatWeights ModelFormat = "weights"
+)
+
+// ModelFormat is the format a model is stored in
+type ModelFormat string
+
+// FileName returns the name of the model file in a model directory
+func (f ModelFormat) FileName() string {
+	switch f {
+	case FormatSavedModel:
+		return "saved_model.pb"
+	case FormatWeights:
+		return "model.weights"
+	}
+	return "model.pb"
+}
+
+// DetectModelFormat returns the first format in the order preferred by this
+// build whose model file is in dir. Without any, the directory is expected to hold a
+// frozen graph.
+func DetectModelFormat(dir string) ModelFormat {
+	for _, format := range supportedFormats {
+		info, err := os.Stat(filepath.Join(dir, format.FileName()))
+		if err == nil && !info.IsDir() {
+			return format
+		}
+	}
+	return FormatFrozenGraph
+}
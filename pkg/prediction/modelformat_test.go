@@ -0,0 +1,30 @@
+package prediction
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DetectModelFormat(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, FormatFrozenGraph, DetectModelFormat(dir))
+
+	for _, format := range supportedFormats {
+		if err := os.WriteFile(filepath.Join(dir, format.FileName()), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, supportedFormats[0], DetectModelFormat(dir))
+}
+
+func Test_DetectModelFormat_weights(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "model.weights"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, FormatWeights, DetectModelFormat(dir))
+}
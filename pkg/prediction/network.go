@@ -0,0 +1,483 @@
+package prediction
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// The weights format stores a feed forward network, such as the VGG16 based
+// model trained in learn/, so it can be run in pure Go without the tensorflow
+// C library. All numbers are little endian, learn/export.py writes it from a
+// keras model.
+//
+//	magic      [4]byte  "IACW"
+//	version    uint32   1
+//	height     uint32   input height
+//	width      uint32   input width
+//	channels   uint32   input channels, 3
+//	layers     uint32   number of layers, each starting with a uint8 kind
+//
+//	conv2d     kind 1   stride 1 and "same" padding
+//	  kernelHeight, kernelWidth, inChannels, outChannels uint32
+//	  activation uint8
+//	  kernel     float32[kernelHeight*kernelWidth*inChannels*outChannels] in keras order
+//	  bias       float32[outChannels]
+//	maxpool2d  kind 2   stride equal to the pool size and "valid" padding
+//	  poolSize   uint32
+//	flatten    kind 3   no parameters, flattens in height, width, channel order
+//	dense      kind 4
+//	  inputs, outputs uint32
+//	  activation uint8
+//	  kernel     float32[inputs*outputs] in keras order
+//	  bias       float32[outputs]
+//
+// Activations are 0 for linear, 1 for relu and 2 for softmax. Dropout layers
+// do nothing at inference and are not stored.
+//
+// The probabilities are within weightsTolerance of the ones of the
+// tensorflow backend, they only differ by the order of the float32 additions.
+const (
+	weightsMagic     = "IACW"
+	weightsVersion   = 1
+	weightsTolerance = 1e-4
+
+	layerConv2D    = 1
+	layerMaxPool2D = 2
+	layerFlatten   = 3
+	layerDense     = 4
+
+	activationLinear  = 0
+	activationRelu    = 1
+	activationSoftmax = 2
+
+	// limits the allocations of a corrupt file
+	maxWeightsDimension = 1 << 16
+	maxWeightsParams    = 1 << 28
+
+	errorTextCouldNotReadWeights = "could not read model weights"
+)
+
+// tensorShape is the height, width and channels of the values between two
+// layers, a flat vector has height and width 1
+type tensorShape struct {
+	height, width, channels int
+}
+
+func (s tensorShape) size() int {
+	return s.height * s.width * s.channels
+}
+
+func (s tensorShape) String() string {
+	return fmt.Sprintf("[%d, %d, %d]", s.height, s.width, s.channels)
+}
+
+type layer interface {
+	// outputShape fails if the layer does not accept the input shape
+	outputShape(input tensorShape) (tensorShape, error)
+	forward(input []float32, shape tensorShape) []float32
+}
+
+// network runs the layers of a model read from the weights format
+type network struct {
+	input  tensorShape
+	output tensorShape
+	layers []layer
+}
+
+// readNetwork reads a network in the weights format and checks that the
+// shapes of its layers fit together
+func readNetwork(r io.Reader) (*network, error) {
+	reader := &weightsReader{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(weightsMagic))
+	if _, err := io.ReadFull(reader.r, magic); err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotReadWeights)
+	}
+	if string(magic) != weightsMagic {
+		return nil, errors.Errorf("%s: not in the weights format", errorTextCouldNotReadWeights)
+	}
+	if version := reader.uint32(); reader.err == nil && version != weightsVersion {
+		return nil, errors.Errorf("%s: unsupported version %d", errorTextCouldNotReadWeights, version)
+	}
+
+	net := &network{input: tensorShape{reader.dimension(), reader.dimension(), reader.dimension()}}
+	numLayers := reader.dimension()
+	if reader.err != nil {
+		return nil, errors.Wrap(reader.err, errorTextCouldNotReadWeights)
+	}
+
+	shape := net.input
+	for i := 0; i < numLayers; i++ {
+		l := reader.layer()
+		if reader.err != nil {
+			return nil, errors.Wrapf(reader.err, "%s: layer %d", errorTextCouldNotReadWeights, i)
+		}
+
+		var err error
+		if shape, err = l.outputShape(shape); err != nil {
+			return nil, errors.Wrapf(ErrInvalidModel, "layer %d: %v", i, err)
+		}
+		net.layers = append(net.layers, l)
+	}
+	net.output = shape
+
+	return net, nil
+}
+
+// forward runs a single image through the network
+func (n *network) forward(input []float32) []float32 {
+	return n.forwardTo(input, len(n.layers))
+}
+
+// forwardTo runs the input through the first count layers
+func (n *network) forwardTo(input []float32, count int) []float32 {
+	values, shape := input, n.input
+	for _, l := range n.layers[:count] {
+		next, _ := l.outputShape(shape)
+		values, shape = l.forward(values, shape), next
+	}
+	return values
+}
+
+// weightsReader keeps the first error, so a layer can be read without
+// checking every field
+type weightsReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *weightsReader) uint32() uint32 {
+	var value uint32
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, &value)
+	}
+	return value
+}
+
+func (r *weightsReader) dimension() int {
+	value := r.uint32()
+	if r.err == nil && value > maxWeightsDimension {
+		r.err = errors.Errorf("dimension %d is too large", value)
+	}
+	return int(value)
+}
+
+func (r *weightsReader) uint8() uint8 {
+	var value uint8
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, &value)
+	}
+	return value
+}
+
+func (r *weightsReader) activation() uint8 {
+	value := r.uint8()
+	if r.err == nil && value > activationSoftmax {
+		r.err = errors.Errorf("unknown activation %d", value)
+	}
+	return value
+}
+
+// floats reads as many values as the product of the dimensions
+func (r *weightsReader) floats(dimensions ...int) []float32 {
+	if r.err != nil {
+		return nil
+	}
+	n := 1
+	for _, dimension := range dimensions {
+		if n *= dimension; n > maxWeightsParams {
+			r.err = errors.Errorf("layer has more than %d parameters", maxWeightsParams)
+			return nil
+		}
+	}
+	values := make([]float32, n)
+	r.err = binary.Read(r.r, binary.LittleEndian, values)
+	return values
+}
+
+func (r *weightsReader) layer() layer {
+	switch kind := r.uint8(); kind {
+	case layerConv2D:
+		c := &conv2D{kernelHeight: r.dimension(), kernelWidth: r.dimension(), inChannels: r.dimension(), outChannels: r.dimension()}
+		c.activation = r.activation()
+		c.kernel = r.floats(c.kernelHeight, c.kernelWidth, c.inChannels, c.outChannels)
+		c.bias = r.floats(c.outChannels)
+		return c
+	case layerMaxPool2D:
+		return &maxPool2D{size: r.dimension()}
+	case layerFlatten:
+		return flatten{}
+	case layerDense:
+		d := &dense{inputs: r.dimension(), outputs: r.dimension()}
+		d.activation = r.activation()
+		d.kernel = r.floats(d.inputs, d.outputs)
+		d.bias = r.floats(d.outputs)
+		return d
+	default:
+		if r.err == nil {
+			r.err = errors.Errorf("unknown layer kind %d", kind)
+		}
+		return nil
+	}
+}
+
+type conv2D struct {
+	kernelHeight, kernelWidth int
+	inChannels, outChannels   int
+	activation                uint8
+	kernel                    []float32
+	bias                      []float32
+}
+
+func (c *conv2D) outputShape(input tensorShape) (tensorShape, error) {
+	if input.channels != c.inChannels {
+		return tensorShape{}, fmt.Errorf("conv2d expects %d channels, got shape %v", c.inChannels, input)
+	}
+	if c.kernelHeight == 0 || c.kernelWidth == 0 || c.outChannels == 0 {
+		return tensorShape{}, fmt.Errorf("conv2d has an empty kernel")
+	}
+	return tensorShape{input.height, input.width, c.outChannels}, nil
+}
+
+func (c *conv2D) forward(input []float32, shape tensorShape) []float32 {
+	output := make([]float32, shape.height*shape.width*c.outChannels)
+	padTop, padLeft := (c.kernelHeight-1)/2, (c.kernelWidth-1)/2
+
+	parallelRows(shape.height, func(y int) {
+		for x := 0; x < shape.width; x++ {
+			sums := output[(y*shape.width+x)*c.outChannels : (y*shape.width+x+1)*c.outChannels]
+			copy(sums, c.bias)
+
+			for ky := 0; ky < c.kernelHeight; ky++ {
+				iy := y + ky - padTop
+				if iy < 0 || iy >= shape.height {
+					continue
+				}
+				for kx := 0; kx < c.kernelWidth; kx++ {
+					ix := x + kx - padLeft
+					if ix < 0 || ix >= shape.width {
+						continue
+					}
+
+					pixel := input[(iy*shape.width+ix)*c.inChannels : (iy*shape.width+ix+1)*c.inChannels]
+					weights := c.kernel[(ky*c.kernelWidth+kx)*c.inChannels*c.outChannels:]
+					for ci, value := range pixel {
+						if value == 0 {
+							continue
+						}
+						row := weights[ci*c.outChannels : (ci+1)*c.outChannels]
+						for co := range sums {
+							sums[co] += value * row[co]
+						}
+					}
+				}
+			}
+
+			activate(sums, c.activation)
+		}
+	})
+
+	return output
+}
+
+type maxPool2D struct {
+	size int
+}
+
+func (p *maxPool2D) outputShape(input tensorShape) (tensorShape, error) {
+	if p.size == 0 || input.height < p.size || input.width < p.size {
+		return tensorShape{}, fmt.Errorf("maxpool2d of size %d does not fit shape %v", p.size, input)
+	}
+	return tensorShape{input.height / p.size, input.width / p.size, input.channels}, nil
+}
+
+func (p *maxPool2D) forward(input []float32, shape tensorShape) []float32 {
+	out, _ := p.outputShape(shape)
+	output := make([]float32, out.size())
+
+	for y := 0; y < out.height; y++ {
+		for x := 0; x < out.width; x++ {
+			maxima := output[(y*out.width+x)*out.channels : (y*out.width+x+1)*out.channels]
+			for c := range maxima {
+				maxima[c] = float32(math.Inf(-1))
+			}
+			for py := 0; py < p.size; py++ {
+				for px := 0; px < p.size; px++ {
+					offset := ((y*p.size+py)*shape.width + x*p.size + px) * shape.channels
+					for c, value := range input[offset : offset+shape.channels] {
+						if value > maxima[c] {
+							maxima[c] = value
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return output
+}
+
+type flatten struct{}
+
+func (flatten) outputShape(input tensorShape) (tensorShape, error) {
+	return tensorShape{1, 1, input.size()}, nil
+}
+
+func (flatten) forward(input []float32, _ tensorShape) []float32 {
+	return input
+}
+
+type dense struct {
+	inputs, outputs int
+	activation      uint8
+	kernel          []float32
+	bias            []float32
+}
+
+func (d *dense) outputShape(input tensorShape) (tensorShape, error) {
+	if input.height != 1 || input.width != 1 {
+		return tensorShape{}, fmt.Errorf("dense expects a flat input, got shape %v", input)
+	}
+	if input.channels != d.inputs {
+		return tensorShape{}, fmt.Errorf("dense expects %d inputs, got %d", d.inputs, input.channels)
+	}
+	return tensorShape{1, 1, d.outputs}, nil
+}
+
+func (d *dense) forward(input []float32, _ tensorShape) []float32 {
+	output := make([]float32, d.outputs)
+	copy(output, d.bias)
+
+	for i, value := range input {
+		if value == 0 {
+			continue
+		}
+		row := d.kernel[i*d.outputs : (i+1)*d.outputs]
+		for j := range output {
+			output[j] += value * row[j]
+		}
+	}
+
+	activate(output, d.activation)
+	return output
+}
+
+func activate(values []float32, activation uint8) {
+	switch activation {
+	case activationRelu:
+		for i, value := range values {
+			if value < 0 {
+				values[i] = 0
+			}
+		}
+	case activationSoftmax:
+		maximum := float32(math.Inf(-1))
+		for _, value := range values {
+			maximum = max(maximum, value)
+		}
+		var sum float64
+		for i, value := range values {
+			exp := math.Exp(float64(value - maximum))
+			values[i] = float32(exp)
+			sum += exp
+		}
+		for i := range values {
+			values[i] = float32(float64(values[i]) / sum)
+		}
+	}
+}
+
+// parallelRows calls row for every row, spread over all cores
+func parallelRows(rows int, row func(y int)) {
+	workers := min(rows, runtime.GOMAXPROCS(0))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for y := w; y < rows; y += workers {
+				row(y)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// weightsBackend runs the model in pure Go
+type weightsBackend struct {
+	network *network
+}
+
+func (b *weightsBackend) run(inputs [][]float32, height, width int) ([][]float32, error) {
+	if height != b.network.input.height || width != b.network.input.width {
+		return nil, errors.Errorf("model expects images of %dx%d, got %dx%d", b.network.input.width, b.network.input.height, width, height)
+	}
+
+	predictions := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		predictions[i] = b.network.forward(input)
+	}
+	return predictions, nil
+}
+
+// embed returns the output of a layer. The tensor is the number of the layer
+// counting from 0, dropout layers are not stored and do not count.
+func (b *weightsBackend) embed(inputs [][]float32, height, width int, tensor string) ([][]float32, error) {
+	layer, err := strconv.Atoi(tensor)
+	if err != nil || layer < 0 || layer >= len(b.network.layers) {
+		return nil, errors.Errorf("embedding tensor %q is not the number of one of the %d layers", tensor, len(b.network.layers))
+	}
+	if height != b.network.input.height || width != b.network.input.width {
+		return nil, errors.Errorf("model expects images of %dx%d, got %dx%d", b.network.input.width, b.network.input.height, width, height)
+	}
+
+	embeddings := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		embeddings[i] = b.network.forwardTo(input, layer+1)
+	}
+	return embeddings, nil
+}
+
+func (b *weightsBackend) close() error {
+	return nil
+}
+
+// NewServiceFromWeights creates a new service instance running the model in
+// pure Go from weights in the format documented above. It fails like
+// NewService if the input does not match the image dimensions, the output
+// does not match the number of labels or the calibration does not fit them.
+func NewServiceFromWeights(weights []byte, labels []Label, targetImageDimensions int, options ...Option) (*Service, error) {
+	net, err := readNetwork(bytes.NewReader(weights))
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	if expected := (tensorShape{targetImageDimensions, targetImageDimensions, rgbColorChannels}); net.input != expected {
+		problems = append(problems, fmt.Sprintf("input has shape %v, expected %v", net.input, expected))
+	}
+	if net.output.height != 1 || net.output.width != 1 || net.output.channels != len(labels) {
+		problems = append(problems, fmt.Sprintf("output has shape %v, but there are %d labels", net.output, len(labels)))
+	}
+	if len(problems) > 0 {
+		return nil, errors.Wrap(ErrInvalidModel, strings.Join(problems, "; "))
+	}
+
+	service := &Service{}
+	if err := service.init(labels, targetImageDimensions, &weightsBackend{network: net}, options); err != nil {
+		return nil, err
+	}
+
+	return service, nil
+}
@@ -0,0 +1,209 @@
+package prediction
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// weightsBuilder writes a network in the weights format
+type weightsBuilder struct {
+	layers int
+	body   bytes.Buffer
+}
+
+func (b *weightsBuilder) write(values ...any) *weightsBuilder {
+	for _, value := range values {
+		if err := binary.Write(&b.body, binary.LittleEndian, value); err != nil {
+			panic(err)
+		}
+	}
+	return b
+}
+
+// layer writes a layer of the given kind followed by its raw fields
+func (b *weightsBuilder) layer(kind uint8, values ...any) *weightsBuilder {
+	b.layers++
+	return b.write(append([]any{kind}, values...)...)
+}
+
+func (b *weightsBuilder) conv2D(kernelHeight, kernelWidth, inChannels, outChannels uint32, activation uint8, kernel, bias []float32) *weightsBuilder {
+	return b.layer(uint8(layerConv2D), kernelHeight, kernelWidth, inChannels, outChannels, activation, kernel, bias)
+}
+
+func (b *weightsBuilder) maxPool2D(size uint32) *weightsBuilder {
+	return b.layer(uint8(layerMaxPool2D), size)
+}
+
+func (b *weightsBuilder) flatten() *weightsBuilder {
+	return b.layer(uint8(layerFlatten))
+}
+
+func (b *weightsBuilder) dense(inputs, outputs uint32, activation uint8, kernel, bias []float32) *weightsBuilder {
+	return b.layer(uint8(layerDense), inputs, outputs, activation, kernel, bias)
+}
+
+func (b *weightsBuilder) bytes(height, width, channels uint32) []byte {
+	var out bytes.Buffer
+	out.WriteString(weightsMagic)
+	for _, value := range []uint32{weightsVersion, height, width, channels, uint32(b.layers)} {
+		binary.Write(&out, binary.LittleEndian, value)
+	}
+	out.Write(b.body.Bytes())
+	return out.Bytes()
+}
+
+func readTestNetwork(t *testing.T, weights []byte) *network {
+	net, err := readNetwork(bytes.NewReader(weights))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return net
+}
+
+func ones(n int) []float32 {
+	values := make([]float32, n)
+	for i := range values {
+		values[i] = 1
+	}
+	return values
+}
+
+func Test_network_conv2D_same_padding(t *testing.T) {
+	weights := (&weightsBuilder{}).
+		conv2D(3, 3, 1, 1, activationLinear, ones(9), []float32{0.5}).
+		bytes(3, 3, 1)
+
+	output := readTestNetwork(t, weights).forward([]float32{1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+	assert.Equal(t, []float32{12.5, 21.5, 16.5, 27.5, 45.5, 33.5, 24.5, 39.5, 28.5}, output)
+}
+
+func Test_network_conv2D_channels(t *testing.T) {
+	// a 1x1 kernel mapping [r, g] to [r+g, r-g, -r], the last one cut by relu
+	weights := (&weightsBuilder{}).
+		conv2D(1, 1, 2, 3, activationRelu, []float32{1, 1, -1, 1, -1, 0}, []float32{0, 0, 0}).
+		bytes(1, 2, 2)
+
+	output := readTestNetwork(t, weights).forward([]float32{3, 1, 1, 2})
+
+	assert.Equal(t, []float32{4, 2, 0, 3, 0, 0}, output)
+}
+
+func Test_network_maxPool2D_flatten_dense_softmax(t *testing.T) {
+	weights := (&weightsBuilder{}).
+		maxPool2D(2).
+		flatten().
+		dense(2, 2, activationSoftmax, []float32{1, 0, 0, 1}, []float32{0, 0}).
+		bytes(2, 3, 2)
+
+	net := readTestNetwork(t, weights)
+	output := net.forward([]float32{
+		1, -1, 0, 0, 9, 9,
+		0, 0, 2, -3, 9, 9,
+	})
+
+	assert.Equal(t, tensorShape{1, 1, 2}, net.output)
+	assert.InDelta(t, 0.8807971, output[0], 1e-6)
+	assert.InDelta(t, 0.1192029, output[1], 1e-6)
+}
+
+func Test_readNetwork_invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		weights  []byte
+		expected string
+	}{
+		{"wrong magic", []byte("GIF89a"), "could not read model weights: not in the weights format"},
+		{"truncated", (&weightsBuilder{}).flatten().bytes(2, 2, 3)[:22], "could not read model weights: unexpected EOF"},
+		{"missing parameters", (&weightsBuilder{}).dense(12, 2, activationLinear, ones(3), nil).bytes(2, 2, 3), "could not read model weights: layer 0: unexpected EOF"},
+		{"unknown layer", (&weightsBuilder{}).layer(9).bytes(2, 2, 3), "could not read model weights: layer 0: unknown layer kind 9"},
+		{"too large", (&weightsBuilder{}).layer(uint8(layerDense), uint32(1<<16), uint32(1<<16), uint8(activationLinear)).bytes(2, 2, 3), "could not read model weights: layer 0: layer has more than 268435456 parameters"},
+		{"shapes do not fit", (&weightsBuilder{}).flatten().dense(4, 2, activationLinear, ones(8), ones(2)).bytes(2, 2, 3), "layer 1: dense expects 4 inputs, got 12: model does not match the configuration"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readNetwork(bytes.NewReader(tt.weights))
+			assert.EqualError(t, err, tt.expected)
+		})
+	}
+}
+
+// dominantChannelWeights sums each color channel of a 4x4 image and predicts
+// the class of the strongest one
+func dominantChannelWeights() []byte {
+	kernel := make([]float32, 4*4*rgbColorChannels*rgbColorChannels)
+	for i := 0; i < 4*4*rgbColorChannels; i++ {
+		kernel[i*rgbColorChannels+i%rgbColorChannels] = 1
+	}
+
+	return (&weightsBuilder{}).
+		flatten().
+		dense(4*4*rgbColorChannels, rgbColorChannels, activationSoftmax, kernel, make([]float32, rgbColorChannels)).
+		bytes(4, 4, rgbColorChannels)
+}
+
+func Test_NewServiceFromWeights(t *testing.T) {
+	service, err := NewServiceFromWeights(dominantChannelWeights(), testLabels, 4, WithModelVersion("go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	for expected, c := range map[string]color.RGBA{
+		"cats":     {255, 0, 0, 255},
+		"non_cats": {0, 255, 0, 255},
+		"dogs":     {0, 0, 255, 255},
+	} {
+		result, err := service.PredictImage(context.Background(), solidPNG(t, c))
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, result.Class)
+			assert.Equal(t, "go", result.ModelVersion)
+		}
+	}
+}
+
+func Test_NewServiceFromWeights_does_not_match_configuration(t *testing.T) {
+	_, err := NewServiceFromWeights(dominantChannelWeights(), testLabels[:2], 8)
+
+	assert.ErrorIs(t, err, ErrInvalidModel)
+	assert.EqualError(t, err, "input has shape [4, 4, 3], expected [8, 8, 3]; "+
+		"output has shape [1, 1, 3], but there are 2 labels: model does not match the configuration")
+}
+
+func Test_weightsBackend_embed(t *testing.T) {
+	service, err := NewServiceFromWeights(dominantChannelWeights(), testLabels, 4, WithEmbedding("0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.Stop()
+
+	red, err := service.EmbedImage(context.Background(), solidPNG(t, color.RGBA{255, 0, 0, 255}))
+	assert.NoError(t, err)
+	green, err := service.EmbedImage(context.Background(), solidPNG(t, color.RGBA{0, 255, 0, 255}))
+	assert.NoError(t, err)
+
+	// the flatten layer passes the preprocessed pixels through
+	assert.Len(t, red.Vector, 4*4*rgbColorChannels)
+	assert.Equal(t, red.Vector[:rgbColorChannels], red.Vector[rgbColorChannels:2*rgbColorChannels])
+	assert.NotEqual(t, red.Vector[:rgbColorChannels], green.Vector[:rgbColorChannels])
+}
+
+func Test_weightsBackend_embed_invalid_layer(t *testing.T) {
+	for _, tensor := range []string{"2", "-1", "flatten"} {
+		service, err := NewServiceFromWeights(dominantChannelWeights(), testLabels, 4, WithEmbedding(tensor))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = service.EmbedImage(context.Background(), solidPNG(t, color.RGBA{255, 0, 0, 255}))
+		assert.EqualError(t, err, fmt.Sprintf("embedding tensor %q is not the number of one of the 2 layers", tensor))
+		service.Stop()
+	}
+}
@@ -0,0 +1,23 @@
+//go:build notensorflow
+
+package prediction
+
+import "github.com/pkg/errors"
+
+// ErrTensorflowUnavailable is returned for tensorflow models when the service
+// is built with the notensorflow tag
+var ErrTensorflowUnavailable = errors.New("built without tensorflow, use a model in the weights format")
+
+// supportedFormats are the model formats in the order they are looked for,
+// tensorflow models are still detected to fail with ErrTensorflowUnavailable
+var supportedFormats = []ModelFormat{FormatWeights, FormatSavedModel, FormatFrozenGraph}
+
+// NewService fails, frozen graphs need the tensorflow backend
+func NewService(model []byte, labels []Label, colorChannels int64, inputOperationName, outputOperationName string, targetImageDimensions int, options ...Option) (*Service, error) {
+	return nil, ErrTensorflowUnavailable
+}
+
+// NewServiceFromSavedModel fails, SavedModels need the tensorflow backend
+func NewServiceFromSavedModel(exportDir string, config SavedModelConfig, labels []Label, targetImageDimensions int, options ...Option) (*Service, error) {
+	return nil, ErrTensorflowUnavailable
+}
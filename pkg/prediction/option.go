@@ -0,0 +1,106 @@
+package prediction
+
+import "time"
+
+// An Option configures the prediction service
+type Option func(*Service)
+
+// WithResizeMode sets the interpolation used to scale images, nearest
+// neighbour by default
+func WithResizeMode(mode ResizeMode) Option {
+	return func(s *Service) {
+		s.resizeMode = mode
+	}
+}
+
+// WithAspectPolicy sets how images that are not square are fit into the
+// model input, stretched by default
+func WithAspectPolicy(policy AspectPolicy) Option {
+	return func(s *Service) {
+		s.aspectPolicy = policy
+	}
+}
+
+// WithThresholds sets the probabilities that decide whether a result is
+// confident, uncertain or rejected
+func WithThresholds(thresholds Thresholds) Option {
+	return func(s *Service) {
+		s.thresholds = thresholds
+	}
+}
+
+// WithCalibration sets the calibration applied to the probabilities of the
+// model before they are reported. Nil, the default, reports them as they are.
+// Creating the service fails if the calibration does not fit the number of
+// labels.
+func WithCalibration(calibration *Calibration) Option {
+	return func(s *Service) {
+		s.calibration = calibration
+	}
+}
+
+// WithModelVersion sets the version recorded in every result
+func WithModelVersion(version string) Option {
+	return func(s *Service) {
+		s.modelVersion = version
+	}
+}
+
+// WithWorkers sets the number of predictions running at the same time
+func WithWorkers(workers int) Option {
+	return func(s *Service) {
+		s.workers = workers
+	}
+}
+
+// WithQueueSize sets how many predictions may wait for a free worker before
+// new ones are rejected with ErrQueueFull
+func WithQueueSize(queueSize int) Option {
+	return func(s *Service) {
+		s.queueSize = queueSize
+	}
+}
+
+// WithTimeout limits how long a single prediction, including the time it
+// waits in the queue, may take. Zero disables the limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Service) {
+		s.timeout = timeout
+	}
+}
+
+// WithMaxBatchSize sets how many concurrent predictions may be run through the
+// model together. The batch is also limited by the number of workers. One,
+// the default, disables batching.
+func WithMaxBatchSize(maxBatchSize int) Option {
+	return func(s *Service) {
+		s.maxBatchSize = maxBatchSize
+	}
+}
+
+// WithMaxBatchWait sets how long a batch waits for more predictions before it
+// is run even though it is not full
+func WithMaxBatchWait(maxBatchWait time.Duration) Option {
+	return func(s *Service) {
+		s.maxBatchWait = maxBatchWait
+	}
+}
+
+// WithOcclusion sets the size of the grey patch used for explanations and
+// how far it moves between runs. Zero picks a quarter of the image and half
+// of the patch.
+func WithOcclusion(patchSize, stride int) Option {
+	return func(s *Service) {
+		s.occlusionPatchSize = patchSize
+		s.occlusionStride = stride
+	}
+}
+
+// WithEmbedding sets the tensor whose values are returned as the embedding of
+// an image, the name of a graph operation for TensorFlow models and the number
+// of the layer for weights. Empty, the default, disables embeddings.
+func WithEmbedding(tensor string) Option {
+	return func(s *Service) {
+		s.embeddingTensor = tensor
+	}
+}
@@ -0,0 +1,160 @@
+package prediction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	orientationNormal     = 1
+	orientationFlipH      = 2
+	orientationRotate180  = 3
+	orientationFlipV      = 4
+	orientationTranspose  = 5
+	orientationRotate90   = 6
+	orientationTransverse = 7
+	orientationRotate270  = 8
+
+	jpegMarkerPrefix   = 0xff
+	jpegMarkerSOI      = 0xd8
+	jpegMarkerEOI      = 0xd9
+	jpegMarkerSOS      = 0xda
+	jpegMarkerAPP1     = 0xe1
+	exifTagOrientation = 0x0112
+	exifTypeShort      = 3
+	exifIFDEntrySize   = 12
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// exifOrientation returns the EXIF orientation of a jpeg image. Images
+// without a valid orientation tag are treated as normally oriented.
+func exifOrientation(imageBytes []byte) int {
+	if len(imageBytes) < 4 || imageBytes[0] != jpegMarkerPrefix || imageBytes[1] != jpegMarkerSOI {
+		return orientationNormal
+	}
+
+	for i := 2; i+4 <= len(imageBytes); {
+		if imageBytes[i] != jpegMarkerPrefix {
+			return orientationNormal
+		}
+		marker := imageBytes[i+1]
+		switch {
+		case marker == jpegMarkerPrefix:
+			// fill byte
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// markers without a payload
+			i += 2
+			continue
+		case marker == jpegMarkerSOS || marker == jpegMarkerEOI:
+			// the image data starts, there is no more metadata
+			return orientationNormal
+		}
+
+		length := int(binary.BigEndian.Uint16(imageBytes[i+2:]))
+		if length < 2 || i+2+length > len(imageBytes) {
+			return orientationNormal
+		}
+		segment := imageBytes[i+4 : i+2+length]
+
+		if marker == jpegMarkerAPP1 && bytes.HasPrefix(segment, exifHeader) {
+			return tiffOrientation(segment[len(exifHeader):])
+		}
+
+		i += 2 + length
+	}
+
+	return orientationNormal
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of the tiff
+// structure EXIF data is stored in
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return orientationNormal
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return orientationNormal
+	}
+
+	ifdOffset := int(order.Uint32(tiff[4:]))
+	if ifdOffset < 8 || ifdOffset+2 > len(tiff) {
+		return orientationNormal
+	}
+
+	entries := int(order.Uint16(tiff[ifdOffset:]))
+	for e := 0; e < entries; e++ {
+		entry := ifdOffset + 2 + e*exifIFDEntrySize
+		if entry+exifIFDEntrySize > len(tiff) {
+			return orientationNormal
+		}
+
+		if order.Uint16(tiff[entry:]) != exifTagOrientation || order.Uint16(tiff[entry+2:]) != exifTypeShort {
+			continue
+		}
+
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if orientation < orientationNormal || orientation > orientationRotate270 {
+			return orientationNormal
+		}
+		return orientation
+	}
+
+	return orientationNormal
+}
+
+// applyOrientation rotates and flips img so that it is displayed upright
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= orientationNormal || orientation > orientationRotate270 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Rect, img, bounds.Min, draw.Src)
+
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= orientationTranspose {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			var sx, sy int
+			switch orientation {
+			case orientationFlipH:
+				sx, sy = width-1-x, y
+			case orientationRotate180:
+				sx, sy = width-1-x, height-1-y
+			case orientationFlipV:
+				sx, sy = x, height-1-y
+			case orientationTranspose:
+				sx, sy = y, x
+			case orientationRotate90:
+				sx, sy = y, height-1-x
+			case orientationTransverse:
+				sx, sy = width-1-y, height-1-x
+			case orientationRotate270:
+				sx, sy = width-1-y, x
+			}
+
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,153 @@
+package prediction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a 3x2 image with a distinct gray value per pixel:
+//
+//	1 2 3
+//	4 5 6
+func orientationTestImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			v := uint8(1 + x + y*3)
+			img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func grayValues(img image.Image) [][]uint8 {
+	bounds := img.Bounds()
+	values := make([][]uint8, bounds.Dy())
+	for y := range values {
+		values[y] = make([]uint8, bounds.Dx())
+		for x := range values[y] {
+			r, _, _, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			values[y][x] = uint8(r >> 8)
+		}
+	}
+	return values
+}
+
+func Test_applyOrientation(t *testing.T) {
+	tests := []struct {
+		orientation int
+		expected    [][]uint8
+	}{
+		{orientationNormal, [][]uint8{{1, 2, 3}, {4, 5, 6}}},
+		{orientationFlipH, [][]uint8{{3, 2, 1}, {6, 5, 4}}},
+		{orientationRotate180, [][]uint8{{6, 5, 4}, {3, 2, 1}}},
+		{orientationFlipV, [][]uint8{{4, 5, 6}, {1, 2, 3}}},
+		{orientationTranspose, [][]uint8{{1, 4}, {2, 5}, {3, 6}}},
+		{orientationRotate90, [][]uint8{{4, 1}, {5, 2}, {6, 3}}},
+		{orientationTransverse, [][]uint8{{6, 3}, {5, 2}, {4, 1}}},
+		{orientationRotate270, [][]uint8{{3, 6}, {2, 5}, {1, 4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.orientation), func(t *testing.T) {
+			assert.Equal(t, tt.expected, grayValues(applyOrientation(orientationTestImage(), tt.orientation)))
+		})
+	}
+}
+
+func Test_applyOrientation_sub_image(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 5, 4))
+	sub := img.SubImage(image.Rect(1, 1, 4, 3)).(*image.RGBA)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			v := uint8(1 + x + y*3)
+			sub.SetRGBA(1+x, 1+y, color.RGBA{v, v, v, 255})
+		}
+	}
+
+	assert.Equal(t, [][]uint8{{4, 1}, {5, 2}, {6, 3}}, grayValues(applyOrientation(sub, orientationRotate90)))
+}
+
+// exifSegment builds an APP1 segment holding only the orientation tag
+func exifSegment(orientation uint16, order binary.ByteOrder) []byte {
+	var tiff bytes.Buffer
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	binary.Write(&tiff, order, uint16(42))
+	binary.Write(&tiff, order, uint32(8))
+	binary.Write(&tiff, order, uint16(2))
+	// an unrelated tag before the orientation
+	binary.Write(&tiff, order, []uint16{0x010f, 2})
+	binary.Write(&tiff, order, []uint32{4, 0})
+	binary.Write(&tiff, order, []uint16{exifTagOrientation, exifTypeShort})
+	binary.Write(&tiff, order, uint32(1))
+	binary.Write(&tiff, order, []uint16{orientation, 0})
+	binary.Write(&tiff, order, uint32(0))
+
+	payload := append(append([]byte(nil), exifHeader...), tiff.Bytes()...)
+	segment := []byte{jpegMarkerPrefix, jpegMarkerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+
+	return append(segment, payload...)
+}
+
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16, order binary.ByteOrder) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	withExif := append([]byte(nil), encoded[:2]...)
+	withExif = append(withExif, exifSegment(orientation, order)...)
+	return append(withExif, encoded[2:]...)
+}
+
+func Test_exifOrientation(t *testing.T) {
+	for orientation := uint16(1); orientation <= 8; orientation++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			imageBytes := jpegWithOrientation(t, orientationTestImage(), orientation, order)
+
+			assert.Equal(t, int(orientation), exifOrientation(imageBytes), "orientation %d, %v", orientation, order)
+		}
+	}
+}
+
+func Test_exifOrientation_missing_or_invalid(t *testing.T) {
+	var plain bytes.Buffer
+	_ = jpeg.Encode(&plain, orientationTestImage(), nil)
+
+	assert.Equal(t, orientationNormal, exifOrientation(plain.Bytes()))
+	assert.Equal(t, orientationNormal, exifOrientation(jpegWithOrientation(t, orientationTestImage(), 9, binary.BigEndian)))
+	assert.Equal(t, orientationNormal, exifOrientation([]byte("not a jpeg")))
+	assert.Equal(t, orientationNormal, exifOrientation(jpegWithOrientation(t, orientationTestImage(), 6, binary.BigEndian)[:30]))
+}
+
+func Test_resizeImage_honors_orientation(t *testing.T) {
+	// a tall image with a white top half, stored sideways with the top on the right
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 32; x < 64; x++ {
+			img.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+		}
+	}
+	imageBytes := jpegWithOrientation(t, img, orientationRotate270, binary.BigEndian)
+
+	service := &Service{targetImageDimensions: 8, resizeMode: ResizeNearest, aspectPolicy: AspectStretch}
+	resized, err := service.resizeImage(imageBytes)
+
+	assert.NoError(t, err)
+	top, bottom := resized.RGBAAt(4, 1), resized.RGBAAt(4, 6)
+	assert.Greater(t, top.R, uint8(200))
+	assert.Less(t, bottom.R, uint8(50))
+}
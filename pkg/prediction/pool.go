@@ -0,0 +1,175 @@
+package prediction
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultWorkers   = 4
+	defaultQueueSize = 100
+	defaultTimeout   = 30 * time.Second
+)
+
+var (
+	// ErrQueueFull is returned when more predictions are waiting than the queue holds
+	ErrQueueFull = errors.New("prediction queue is full")
+	// ErrStopped is returned for predictions requested after Stop was called
+	ErrStopped = errors.New("prediction service is stopped")
+)
+
+// Stats describe the load on the prediction service
+type Stats struct {
+	Workers          int           `json:"workers"`
+	QueueCapacity    int           `json:"queueCapacity"`
+	QueueDepth       int           `json:"queueDepth"`
+	InFlight         int           `json:"inFlight"`
+	Completed        uint64        `json:"completed"`
+	Failed           uint64        `json:"failed"`
+	Rejected         uint64        `json:"rejected"`
+	Canceled         uint64        `json:"canceled"`
+	AverageQueueWait time.Duration `json:"averageQueueWait"`
+	AverageLatency   time.Duration `json:"averageLatency"`
+	MaxLatency       time.Duration `json:"maxLatency"`
+	MaxBatchSize     int           `json:"maxBatchSize"`
+	Batches          uint64        `json:"batches"`
+	AverageBatchSize float64       `json:"averageBatchSize"`
+}
+
+type job struct {
+	ctx      context.Context
+	work     func() (*Result, error)
+	enqueued time.Time
+	done     chan jobResult
+}
+
+type jobResult struct {
+	result *Result
+	err    error
+}
+
+// pool runs predictions on a fixed number of workers fed by a bounded queue.
+// The workers share the tensorflow session, which is safe for concurrent use.
+type pool struct {
+	jobs    chan *job
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	stopped bool
+
+	statsMu        sync.Mutex
+	stats          Stats
+	totalQueueWait time.Duration
+	totalLatency   time.Duration
+}
+
+func newPool(workers, queueSize int) *pool {
+	p := &pool{
+		jobs:  make(chan *job, queueSize),
+		stats: Stats{Workers: workers, QueueCapacity: queueSize},
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for j := range p.jobs {
+				p.run(j)
+			}
+		}()
+	}
+
+	return p
+}
+
+// submit queues the work and waits for its result or the end of ctx
+func (p *pool) submit(ctx context.Context, work func() (*Result, error)) (*Result, error) {
+	j := &job{ctx: ctx, work: work, enqueued: time.Now(), done: make(chan jobResult, 1)}
+
+	p.mu.RLock()
+	if p.stopped {
+		p.mu.RUnlock()
+		return nil, ErrStopped
+	}
+	select {
+	case p.jobs <- j:
+		p.mu.RUnlock()
+	default:
+		p.mu.RUnlock()
+		p.record(func(s *Stats) { s.Rejected++ })
+		return nil, ErrQueueFull
+	}
+
+	select {
+	case r := <-j.done:
+		return r.result, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *pool) run(j *job) {
+	started := time.Now()
+	queueWait := started.Sub(j.enqueued)
+
+	// nobody is waiting for predictions that were canceled while queued
+	if err := j.ctx.Err(); err != nil {
+		p.record(func(s *Stats) { s.Canceled++ })
+		j.done <- jobResult{err: err}
+		return
+	}
+
+	p.record(func(s *Stats) { s.InFlight++ })
+	result, err := j.work()
+	latency := time.Since(started)
+
+	p.statsMu.Lock()
+	p.stats.InFlight--
+	if err != nil {
+		p.stats.Failed++
+	} else {
+		p.stats.Completed++
+	}
+	p.totalQueueWait += queueWait
+	p.totalLatency += latency
+	p.stats.MaxLatency = max(p.stats.MaxLatency, latency)
+	p.statsMu.Unlock()
+
+	j.done <- jobResult{result, err}
+}
+
+func (p *pool) record(update func(s *Stats)) {
+	p.statsMu.Lock()
+	update(&p.stats)
+	p.statsMu.Unlock()
+}
+
+func (p *pool) snapshot() Stats {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+
+	stats := p.stats
+	stats.QueueDepth = len(p.jobs)
+	if processed := stats.Completed + stats.Failed; processed > 0 {
+		stats.AverageQueueWait = p.totalQueueWait / time.Duration(processed)
+		stats.AverageLatency = p.totalLatency / time.Duration(processed)
+	}
+
+	return stats
+}
+
+// stop lets the workers finish the queued predictions and waits for them
+func (p *pool) stop() {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	p.stopped = true
+	close(p.jobs)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
@@ -0,0 +1,182 @@
+package prediction
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBackend stands in for the model
+type fakeBackend func(inputs [][]float32) ([][]float32, error)
+
+func (f fakeBackend) run(inputs [][]float32, height, width int) ([][]float32, error) {
+	return f(inputs)
+}
+
+func (f fakeBackend) close() error {
+	return nil
+}
+
+// newFakeService predicts with a stand in for the model
+func newFakeService(inference func([][]float32) ([][]float32, error), options ...Option) *Service {
+	service := &Service{}
+	if err := service.init(testLabels, 16, fakeBackend(inference), options); err != nil {
+		panic(err)
+	}
+	return service
+}
+
+func fakeInference(inputs [][]float32) ([][]float32, error) {
+	predictions := make([][]float32, len(inputs))
+	for i := range predictions {
+		predictions[i] = []float32{0.8, 0.15, 0.05}
+	}
+	return predictions, nil
+}
+
+func pngTestImage(t testing.TB) []byte {
+	return encodeTestImage(t, func(b *bytes.Buffer, i image.Image) error { return png.Encode(b, i) })
+}
+
+func Test_PredictImage_concurrent(t *testing.T) {
+	service := newFakeService(fakeInference, WithWorkers(4), WithQueueSize(200))
+	imageBytes := pngTestImage(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := service.PredictImage(context.Background(), imageBytes)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "cats", result.Class)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := service.Stats()
+	assert.NoError(t, service.Stop())
+	assert.Equal(t, uint64(200), stats.Completed)
+	assert.Equal(t, 0, stats.InFlight)
+	assert.Equal(t, 0, stats.QueueDepth)
+	assert.Equal(t, 4, stats.Workers)
+	assert.Greater(t, stats.AverageLatency, time.Duration(0))
+}
+
+func Test_PredictImage_limits_workers(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	inference := func(inputs [][]float32) ([][]float32, error) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return fakeInference(inputs)
+	}
+	service := newFakeService(inference, WithWorkers(2), WithQueueSize(20))
+	defer service.Stop()
+	imageBytes := pngTestImage(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.PredictImage(context.Background(), imageBytes)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, maxRunning)
+}
+
+func Test_PredictImage_queue_full(t *testing.T) {
+	release := make(chan struct{})
+	inference := func(inputs [][]float32) ([][]float32, error) {
+		<-release
+		return fakeInference(inputs)
+	}
+	service := newFakeService(inference, WithWorkers(1), WithQueueSize(1))
+	imageBytes := pngTestImage(t)
+
+	results := make(chan error, 2)
+	predict := func() {
+		_, err := service.PredictImage(context.Background(), imageBytes)
+		results <- err
+	}
+	go predict()
+	assert.Eventually(t, func() bool { return service.Stats().InFlight == 1 }, time.Second, time.Millisecond)
+	go predict()
+	assert.Eventually(t, func() bool { return service.Stats().QueueDepth == 1 }, time.Second, time.Millisecond)
+
+	_, err := service.PredictImage(context.Background(), imageBytes)
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Equal(t, uint64(1), service.Stats().Rejected)
+
+	close(release)
+	assert.NoError(t, <-results)
+	assert.NoError(t, <-results)
+	assert.NoError(t, service.Stop())
+}
+
+func Test_PredictImage_canceled(t *testing.T) {
+	release := make(chan struct{})
+	inference := func(inputs [][]float32) ([][]float32, error) {
+		<-release
+		return fakeInference(inputs)
+	}
+	service := newFakeService(inference, WithWorkers(1), WithQueueSize(1))
+	imageBytes := pngTestImage(t)
+
+	go service.PredictImage(context.Background(), imageBytes)
+	assert.Eventually(t, func() bool { return service.Stats().InFlight == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	_, err := service.PredictImage(ctx, imageBytes)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(release)
+	assert.NoError(t, service.Stop())
+	assert.Equal(t, uint64(1), service.Stats().Canceled)
+}
+
+func Test_PredictImage_timeout(t *testing.T) {
+	release := make(chan struct{})
+	inference := func(inputs [][]float32) ([][]float32, error) {
+		<-release
+		return fakeInference(inputs)
+	}
+	service := newFakeService(inference, WithWorkers(1), WithTimeout(10*time.Millisecond))
+
+	_, err := service.PredictImage(context.Background(), pngTestImage(t))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, service.Stop())
+}
+
+func Test_PredictImage_after_stop(t *testing.T) {
+	service := newFakeService(fakeInference)
+	assert.NoError(t, service.Stop())
+
+	_, err := service.PredictImage(context.Background(), pngTestImage(t))
+	assert.ErrorIs(t, err, ErrStopped)
+}
@@ -1,112 +1,160 @@
 package prediction
 
 import (
+	"context"
 	"log"
+	"time"
 
 	"github.com/pkg/errors"
-	tf "github.com/wamuir/graft/tensorflow"
 )
 
 const (
-	errorTextTensorflowEmptyResponse          = "tensorflow session produced empty result"
-	errorTextCouldNotExecuteTensorflowSession = "could not execute tensorflow session"
-	errorTextCouldNotProcessInputImage        = "could not process input image"
+	errorTextCouldNotProcessInputImage     = "could not process input image"
+	errorTextUnexpectedNumberOfPredictions = "model returned %d predictions for %d images"
+	errorTextInvalidCalibration            = "invalid calibration"
 )
 
-// Service predicts images using an imported tensorflow model
+// ErrInvalidModel is returned when the model does not fit the configuration
+var ErrInvalidModel = errors.New("model does not match the configuration")
+
+// A backend runs preprocessed images through the model. The inputs are the
+// pixels of images of height x width in row major RGB order.
+type backend interface {
+	run(inputs [][]float32, height, width int) ([][]float32, error)
+	close() error
+}
+
+// Service predicts images using an imported model. It is safe for concurrent
+// use, predictions are run by a pool of workers and concurrent ones can be run
+// through the model as a batch.
 type Service struct {
-	inputOperation        *tf.Operation
-	outputOperation       *tf.Operation
-	session               *tf.Session
-	normalizationSession  *tf.Session
-	normalizationInput    *tf.Output
-	normalizationOutput   *tf.Output
+	model                 backend
 	labels                []Label
+	modelVersion          string
 	targetImageDimensions int
+	resizeMode            ResizeMode
+	aspectPolicy          AspectPolicy
+	thresholds            Thresholds
+	calibration           *Calibration
+	workers               int
+	queueSize             int
+	timeout               time.Duration
+	maxBatchSize          int
+	maxBatchWait          time.Duration
+	occlusionPatchSize    int
+	occlusionStride       int
+	embeddingTensor       string
+	pool                  *pool
+	batcher               *batcher
 }
 
-// NewService creates a new service instance from the given model and labels
-func NewService(model []byte, labels []Label, colorChannels int64, inputOperationName, outputOperationName string, targetImageDimensions int) *Service {
-	graph, err := createTensorFlowGraphFromModel(model)
+// init applies the options and starts the workers. It fails if the
+// calibration does not fit the number of labels.
+func (s *Service) init(labels []Label, targetImageDimensions int, model backend, options []Option) error {
+	s.labels = labels
+	s.targetImageDimensions = targetImageDimensions
+	s.model = model
+	s.resizeMode = ResizeNearest
+	s.aspectPolicy = AspectStretch
+	s.thresholds = DefaultThresholds()
+	s.workers = defaultWorkers
+	s.queueSize = defaultQueueSize
+	s.timeout = defaultTimeout
+	s.maxBatchSize = defaultMaxBatchSize
+	s.maxBatchWait = defaultMaxBatchWait
+
+	for _, option := range options {
+		option(s)
+	}
 
-	if err != nil {
-		log.Fatalf("could not import tensorflow graph: %v\n", err)
+	if s.calibration != nil {
+		if err := s.calibration.Validate(len(labels)); err != nil {
+			return errors.Wrap(err, errorTextInvalidCalibration)
+		}
 	}
 
-	inputOperation := graph.Operation(inputOperationName)
-	outputOperation := graph.Operation(outputOperationName)
+	workers := max(1, s.workers)
+	s.batcher = newBatcher(min(max(1, s.maxBatchSize), workers), s.maxBatchWait, s.runBatch)
+	s.pool = newPool(workers, max(0, s.queueSize))
 
-	session, err := tf.NewSession(graph, nil)
-	if err != nil {
-		log.Fatalf("could not create tensorflow session: %v/n", err)
-	}
+	return nil
+}
 
-	// Creates a tensorflow graph to decode the jpeg image
-	normalizationGraph, normalizationInput, normalizationOutput, err := decodeJPEGGraph(colorChannels)
-	if err != nil {
-		log.Fatalf("could not create tensorflow graph: %v/n", err)
+// PredictImage with the imported model and labels. It fails with
+// ErrQueueFull when too many predictions are waiting, and with the error of
+// ctx when it is done before the prediction finished.
+func (s *Service) PredictImage(ctx context.Context, imageBytes []byte) (*Result, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
 	}
 
-	// Execute that graph to decode this one image
-	normalizationSession, err := tf.NewSession(normalizationGraph, nil)
-	if err != nil {
-		log.Fatalf("could not create tensorflow session: %v/n", err)
-	}
+	return s.pool.submit(ctx, func() (*Result, error) {
+		return s.predict(imageBytes)
+	})
+}
 
-	return &Service{inputOperation, outputOperation, session, normalizationSession, normalizationInput, normalizationOutput, labels, targetImageDimensions}
+// ModelVersion returns the version set with WithModelVersion
+func (s *Service) ModelVersion(context.Context) string {
+	return s.modelVersion
 }
 
-// PredictImage with the imported tensorflow model and labels
-func (s *Service) PredictImage(imageBytes []byte) (*Result, error) {
-	resizedImageBytes, err := s.resizeImage(imageBytes)
+// Stats returns the current load of the service
+func (s *Service) Stats() Stats {
+	stats := s.pool.snapshot()
+	s.batcher.fillStats(&stats)
+
+	return stats
+}
+
+func (s *Service) predict(imageBytes []byte) (*Result, error) {
+	resizedImage, err := s.resizeImage(imageBytes)
 	if err != nil {
 		return nil, errors.Wrap(err, errorTextCouldNotProcessInputImage)
 	}
 
-	inputTensor, err := s.makeTensorFromImage(resizedImageBytes)
+	scores, err := s.batcher.predict(imageToFloats(resizedImage))
 	if err != nil {
-		return nil, errors.Wrap(err, errorTextCouldNotProcessInputImage)
+		return nil, err
 	}
 
-	results, err := s.session.Run(
-		map[tf.Output]*tf.Tensor{
-			s.inputOperation.Output(0): inputTensor,
-		},
-		[]tf.Output{
-			s.outputOperation.Output(0),
-		},
-		nil)
+	result := s.newResult(scores)
 
-	if err != nil {
-		return nil, errors.Wrap(err, errorTextCouldNotExecuteTensorflowSession)
-	} else if len(results) == 0 {
-		return nil, errors.New(errorTextTensorflowEmptyResponse)
+	log.Printf("Prediction finished. Predicted class=[%v] with probability=[%v] and confidence=[%v]", result.Class, result.Probability, result.Confidence)
+	return result, nil
+}
+
+// newResult builds the result reported for the raw scores of the model
+func (s *Service) newResult(scores []float32) *Result {
+	if s.calibration != nil {
+		scores = s.calibration.Apply(scores)
 	}
 
-	predictions := results[0].Value().([][]float32)[0]
-	className, probability := findClassWithMaxProbability(predictions, s.labels)
+	result := newResult(scores, s.labels)
+	result.ModelVersion = s.modelVersion
+	result.classify(s.thresholds)
 
-	log.Printf("Prediction finished. Predicted class=[%v] with probability=[%v]", className, probability)
-	return &Result{Class: className, Probability: probability}, nil
+	return result
 }
 
-func createTensorFlowGraphFromModel(model []byte) (*tf.Graph, error) {
-	// Construct an in-memory graph from the serialized form.
-	graph := tf.NewGraph()
-	if err := graph.Import(model, ""); err != nil {
+// runBatch runs the preprocessed images through the model in a single run
+// and returns their probabilities in the same order
+func (s *Service) runBatch(inputs [][]float32) ([][]float32, error) {
+	predictions, err := s.model.run(inputs, s.targetImageDimensions, s.targetImageDimensions)
+	if err != nil {
 		return nil, err
+	} else if len(predictions) != len(inputs) {
+		return nil, errors.Errorf(errorTextUnexpectedNumberOfPredictions, len(predictions), len(inputs))
 	}
 
-	return graph, nil
+	return predictions, nil
 }
 
+// Stop waits for the queued predictions and releases the model
 func (s *Service) Stop() error {
-	if err := s.session.Close(); err != nil {
-		return err
-	}
-	if err := s.normalizationSession.Close(); err != nil {
-		return err
-	}
+	s.pool.stop()
+	s.batcher.stop()
 
-	return nil
+	return s.model.close()
 }
@@ -1,76 +1,46 @@
 package prediction
 
 import (
-	"bytes"
 	"image"
-	"image/jpeg"
-
-	"github.com/pkg/errors"
-	tf "github.com/wamuir/graft/tensorflow"
-	"github.com/wamuir/graft/tensorflow/op"
-	"golang.org/x/image/draw"
 )
 
 const (
-	errorTextCouldNotCreateTensorFromImage     = "could not create tensor from input image"
-	errorTextCouldNotDecodeJPEG                = "could not decode jpeg channels of image"
-	errorTextCouldNotRunPreprocessImageSession = "could not run tensorflow session to preprocess input image"
-	int32zero                                  = int32(0)
-	vgg16ImagenetMeanRed                       = float32(123.68)
-	vgg16ImagenetMeanGreen                     = float32(116.779)
-	vgg16ImagenetMeanBlue                      = float32(103.939)
-
-	targetImageMime    = "image/jpeg"
-	targetImageQuality = 99
+	rgbColorChannels       = 3
+	vgg16ImagenetMeanRed   = float32(123.68)
+	vgg16ImagenetMeanGreen = float32(116.779)
+	vgg16ImagenetMeanBlue  = float32(103.939)
 )
 
 // VGG16 mean RGB values for the imagenet dataset
-var imagenetMeans = []float32{vgg16ImagenetMeanRed, vgg16ImagenetMeanGreen, vgg16ImagenetMeanBlue}
-
-// Preprocessing in specific to VGG16
-func (s *Service) makeTensorFromImage(imageBytes []byte) (*tf.Tensor, error) {
-
-	// DecodeJpeg uses a scalar String-valued tensor as inputOperation.
-	tensor, err := tf.NewTensor(string(imageBytes))
-	if err != nil {
-		return nil, errors.Wrap(err, errorTextCouldNotCreateTensorFromImage)
-	}
-
-	normalized, err := s.normalizationSession.Run(
-		map[tf.Output]*tf.Tensor{*s.normalizationInput: tensor},
-		[]tf.Output{*s.normalizationOutput},
-		nil)
-	if err != nil {
-		return nil, errors.Wrap(err, errorTextCouldNotRunPreprocessImageSession)
+var imagenetMeans = [rgbColorChannels]float32{vgg16ImagenetMeanRed, vgg16ImagenetMeanGreen, vgg16ImagenetMeanBlue}
+
+// imageToFloats returns the pixels in row major RGB order with the imagenet
+// means subtracted
+func imageToFloats(img *image.RGBA) []float32 {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	floats := make([]float32, 0, width*height*rgbColorChannels)
+
+	for y := 0; y < height; y++ {
+		offset := img.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		row := img.Pix[offset : offset+width*4]
+		for x := 0; x < len(row); x += 4 {
+			floats = append(floats,
+				float32(row[x])-imagenetMeans[0],
+				float32(row[x+1])-imagenetMeans[1],
+				float32(row[x+2])-imagenetMeans[2])
+		}
 	}
-	return normalized[0], nil
-}
 
-func decodeJPEGGraph(colorChannels int64) (*tf.Graph, *tf.Output, *tf.Output, error) {
-	s := op.NewScope()
-
-	mean := op.Const(s, imagenetMeans)
-	input := op.Placeholder(s, tf.String)
-	output := op.DecodeJpeg(s, input, op.DecodeJpegChannels(colorChannels))
-	output = op.Cast(s, output, tf.Float)
-	output = op.Sub(s, output, mean)
-	output = op.ExpandDims(s, output, op.Const(s.SubScope("batch"), int32zero))
-
-	graph, err := s.Finalize()
-	return graph, &input, &output, err
+	return floats
 }
 
-// TODO user tensorflow for resizing
-func (s *Service) resizeImage(imageBytes []byte) ([]byte, error) {
-	src, _, err := image.Decode(bytes.NewReader(imageBytes))
+func (s *Service) resizeImage(imageBytes []byte) (*image.RGBA, error) {
+	src, err := decodeImage(imageBytes)
 	if err != nil {
 		return nil, err
 	}
-	dst := image.NewRGBA(image.Rect(0, 0, s.targetImageDimensions, s.targetImageDimensions))
-	draw.NearestNeighbor.Scale(dst, dst.Rect, src, src.Bounds(), draw.Over, nil)
-
-	var buf bytes.Buffer
-	jpeg.Encode(&buf, dst, &jpeg.Options{Quality: targetImageQuality})
+	src = applyOrientation(src, exifOrientation(imageBytes))
 
-	return buf.Bytes(), nil
+	return resize(src, s.targetImageDimensions, s.resizeMode, s.aspectPolicy), nil
 }
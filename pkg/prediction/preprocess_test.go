@@ -0,0 +1,141 @@
+package prediction
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// quality of the jpeg re-encoding the preprocessing used to do
+	legacyJPEGQuality   = 99
+	testImageDimensions = 256
+)
+
+func testPhoto(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), uint8((x + y) * 255 / (width + height)), 255})
+		}
+	}
+	return img
+}
+
+func testPhotoJPEG(tb testing.TB) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testPhoto(640, 480), &jpeg.Options{Quality: 90}); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func resizeTestImage(src image.Image) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, testImageDimensions, testImageDimensions))
+	draw.NearestNeighbor.Scale(dst, dst.Rect, src, src.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// legacyJPEGRoundTrip is what the resized image went through before it was
+// handed to tensorflow
+func legacyJPEGRoundTrip(tb testing.TB, img *image.RGBA) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: legacyJPEGQuality}); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_imageToFloats(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.RGBA{200, 100, 50, 255})
+	img.Set(1, 0, color.RGBA{0, 0, 255, 255})
+
+	floats := imageToFloats(img)
+
+	assert.InDeltaSlice(t, []float32{
+		200 - vgg16ImagenetMeanRed, 100 - vgg16ImagenetMeanGreen, 50 - vgg16ImagenetMeanBlue,
+		0 - vgg16ImagenetMeanRed, 0 - vgg16ImagenetMeanGreen, 255 - vgg16ImagenetMeanBlue,
+	}, floats, 1e-4)
+}
+
+func Test_imageToFloats_sub_image(t *testing.T) {
+	img := testPhoto(4, 4)
+	sub := img.SubImage(image.Rect(1, 1, 3, 3)).(*image.RGBA)
+
+	floats := imageToFloats(sub)
+
+	assert.Len(t, floats, 2*2*rgbColorChannels)
+	r, g, b, _ := img.At(1, 1).RGBA()
+	assert.InDeltaSlice(t, []float32{
+		float32(r>>8) - vgg16ImagenetMeanRed, float32(g>>8) - vgg16ImagenetMeanGreen, float32(b>>8) - vgg16ImagenetMeanBlue,
+	}, floats[:3], 1e-4)
+}
+
+// The input differs from the legacy pipeline only by the jpeg artifacts the
+// re-encoding used to introduce
+func Test_imageToFloats_within_tolerance_of_jpeg_round_trip(t *testing.T) {
+	resized := resizeTestImage(testPhoto(640, 480))
+
+	legacy, err := jpeg.Decode(bytes.NewReader(legacyJPEGRoundTrip(t, resized)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyRGBA := image.NewRGBA(legacy.Bounds())
+	draw.Draw(legacyRGBA, legacyRGBA.Rect, legacy, image.Point{}, draw.Src)
+
+	direct := imageToFloats(resized)
+	roundTrip := imageToFloats(legacyRGBA)
+
+	var sum float64
+	for i := range direct {
+		diff := float64(direct[i] - roundTrip[i])
+		if diff < 0 {
+			diff = -diff
+		}
+		sum += diff
+	}
+
+	assert.Less(t, sum/float64(len(direct)), 2.0)
+}
+
+// BenchmarkPreprocess_jpegRoundTrip measures the former pipeline up to the
+// input tensor, with the go jpeg decoder standing in for DecodeJpeg
+func BenchmarkPreprocess_jpegRoundTrip(b *testing.B) {
+	imageBytes := testPhotoJPEG(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		src, err := decodeImage(imageBytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		reencoded := legacyJPEGRoundTrip(b, resizeTestImage(src))
+		decoded, err := jpeg.Decode(bytes.NewReader(reencoded))
+		if err != nil {
+			b.Fatal(err)
+		}
+		rgba := image.NewRGBA(decoded.Bounds())
+		draw.Draw(rgba, rgba.Rect, decoded, image.Point{}, draw.Src)
+		_ = imageToFloats(rgba)
+	}
+}
+
+// BenchmarkPreprocess_direct measures the current pipeline up to the input tensor
+func BenchmarkPreprocess_direct(b *testing.B) {
+	imageBytes := testPhotoJPEG(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		src, err := decodeImage(imageBytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = imageToFloats(resizeTestImage(src))
+	}
+}
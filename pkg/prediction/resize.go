@@ -0,0 +1,111 @@
+package prediction
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// ResizeMode is the interpolation used to scale images to the model input size
+type ResizeMode string
+
+const (
+	ResizeNearest        ResizeMode = "nearest"
+	ResizeBilinear       ResizeMode = "bilinear"
+	ResizeCatmullRom     ResizeMode = "catmull-rom"
+	ResizeApproxBilinear ResizeMode = "approx-bilinear"
+)
+
+// AspectPolicy decides how images that are not square are fit into the
+// square model input
+type AspectPolicy string
+
+const (
+	// AspectStretch squashes the whole image into the square
+	AspectStretch AspectPolicy = "stretch"
+	// AspectCenterCrop cuts the largest centered square out of the image
+	AspectCenterCrop AspectPolicy = "center-crop"
+	// AspectLetterbox scales the whole image into the square and pads the rest
+	AspectLetterbox AspectPolicy = "letterbox"
+)
+
+// the imagenet mean color, which becomes (almost) zero after preprocessing
+var letterboxColor = color.RGBA{124, 117, 104, 255}
+
+// ParseResizeMode returns the resize mode with the given name
+func ParseResizeMode(name string) (ResizeMode, error) {
+	switch mode := ResizeMode(name); mode {
+	case ResizeNearest, ResizeBilinear, ResizeCatmullRom, ResizeApproxBilinear:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown resize mode %q, use one of %s, %s, %s or %s", name, ResizeNearest, ResizeBilinear, ResizeCatmullRom, ResizeApproxBilinear)
+}
+
+// ParseAspectPolicy returns the aspect policy with the given name
+func ParseAspectPolicy(name string) (AspectPolicy, error) {
+	switch policy := AspectPolicy(name); policy {
+	case AspectStretch, AspectCenterCrop, AspectLetterbox:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unknown aspect policy %q, use one of %s, %s or %s", name, AspectStretch, AspectCenterCrop, AspectLetterbox)
+}
+
+func (m ResizeMode) scaler() draw.Scaler {
+	switch m {
+	case ResizeBilinear:
+		return draw.BiLinear
+	case ResizeCatmullRom:
+		return draw.CatmullRom
+	case ResizeApproxBilinear:
+		return draw.ApproxBiLinear
+	}
+
+	return draw.NearestNeighbor
+}
+
+// resize scales src into a size x size square
+func resize(src image.Image, size int, mode ResizeMode, policy AspectPolicy) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	srcRect, dstRect := src.Bounds(), dst.Rect
+
+	switch policy {
+	case AspectCenterCrop:
+		srcRect = centerSquare(srcRect)
+	case AspectLetterbox:
+		draw.Draw(dst, dst.Rect, image.NewUniform(letterboxColor), image.Point{}, draw.Src)
+		dstRect = letterboxRect(srcRect, size)
+	}
+
+	mode.scaler().Scale(dst, dstRect, src, srcRect, draw.Over, nil)
+
+	return dst
+}
+
+// centerSquare returns the largest square centered in r
+func centerSquare(r image.Rectangle) image.Rectangle {
+	side := min(r.Dx(), r.Dy())
+	x0 := r.Min.X + (r.Dx()-side)/2
+	y0 := r.Min.Y + (r.Dy()-side)/2
+
+	return image.Rect(x0, y0, x0+side, y0+side)
+}
+
+// letterboxRect returns the area of a size x size square that an image of
+// the dimensions of r fills when scaled without distortion
+func letterboxRect(r image.Rectangle, size int) image.Rectangle {
+	width, height := size, size
+	if r.Dx() > r.Dy() {
+		height = max(1, (size*r.Dy()+r.Dx()/2)/r.Dx())
+	} else if r.Dy() > r.Dx() {
+		width = max(1, (size*r.Dx()+r.Dy()/2)/r.Dy())
+	}
+
+	x0 := (size - width) / 2
+	y0 := (size - height) / 2
+
+	return image.Rect(x0, y0, x0+width, y0+height)
+}
@@ -0,0 +1,83 @@
+package prediction
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseResizeMode(t *testing.T) {
+	for _, name := range []string{"nearest", "bilinear", "catmull-rom", "approx-bilinear"} {
+		mode, err := ParseResizeMode(name)
+		assert.NoError(t, err)
+		assert.Equal(t, ResizeMode(name), mode)
+	}
+
+	_, err := ParseResizeMode("lanczos")
+	assert.Error(t, err)
+}
+
+func Test_ParseAspectPolicy(t *testing.T) {
+	for _, name := range []string{"stretch", "center-crop", "letterbox"} {
+		policy, err := ParseAspectPolicy(name)
+		assert.NoError(t, err)
+		assert.Equal(t, AspectPolicy(name), policy)
+	}
+
+	_, err := ParseAspectPolicy("fit")
+	assert.Error(t, err)
+}
+
+func Test_centerSquare(t *testing.T) {
+	assert.Equal(t, image.Rect(20, 0, 60, 40), centerSquare(image.Rect(0, 0, 80, 40)))
+	assert.Equal(t, image.Rect(10, 25, 40, 55), centerSquare(image.Rect(10, 10, 40, 70)))
+	assert.Equal(t, image.Rect(0, 0, 5, 5), centerSquare(image.Rect(0, 0, 5, 5)))
+}
+
+func Test_letterboxRect(t *testing.T) {
+	assert.Equal(t, image.Rect(0, 25, 100, 75), letterboxRect(image.Rect(0, 0, 200, 100), 100))
+	assert.Equal(t, image.Rect(25, 0, 75, 100), letterboxRect(image.Rect(0, 0, 100, 200), 100))
+	assert.Equal(t, image.Rect(0, 0, 100, 100), letterboxRect(image.Rect(0, 0, 30, 30), 100))
+	assert.Equal(t, image.Rect(0, 49, 100, 50), letterboxRect(image.Rect(0, 0, 1000, 1), 100))
+}
+
+// a wide image with a red left half and a blue right half
+func halfRedHalfBlue() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 20 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func Test_resize(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+
+	for _, mode := range []ResizeMode{ResizeNearest, ResizeBilinear, ResizeCatmullRom, ResizeApproxBilinear} {
+		t.Run(string(mode), func(t *testing.T) {
+			stretched := resize(halfRedHalfBlue(), 10, mode, AspectStretch)
+			assert.Equal(t, image.Rect(0, 0, 10, 10), stretched.Rect)
+			assert.Equal(t, red, stretched.RGBAAt(0, 0))
+			assert.Equal(t, blue, stretched.RGBAAt(9, 9))
+
+			cropped := resize(halfRedHalfBlue(), 10, mode, AspectCenterCrop)
+			assert.Equal(t, red, cropped.RGBAAt(0, 5))
+			assert.Equal(t, blue, cropped.RGBAAt(9, 5))
+
+			letterboxed := resize(halfRedHalfBlue(), 10, mode, AspectLetterbox)
+			assert.Equal(t, letterboxColor, letterboxed.RGBAAt(0, 0))
+			assert.Equal(t, letterboxColor, letterboxed.RGBAAt(9, 9))
+			assert.Equal(t, red, letterboxed.RGBAAt(0, 5))
+			assert.Equal(t, blue, letterboxed.RGBAAt(9, 5))
+		})
+	}
+}
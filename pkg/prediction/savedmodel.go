@@ -0,0 +1,19 @@
+package prediction
+
+const (
+	// DefaultSavedModelTag selects the graph exported for serving
+	DefaultSavedModelTag = "serve"
+	// DefaultSignatureName is the signature keras exports for serving
+	DefaultSignatureName = "serving_default"
+)
+
+// SavedModelConfig selects the graph and the signature of a SavedModel. The
+// input and output names are optional. They are either keys of the
+// signature or names of tensors in the graph. When empty, the only input or
+// output of the signature is used.
+type SavedModelConfig struct {
+	Tags          []string
+	SignatureName string
+	InputName     string
+	OutputName    string
+}
@@ -0,0 +1,104 @@
+//go:build !notensorflow
+
+package prediction
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	tf "github.com/wamuir/graft/tensorflow"
+)
+
+const errorTextCouldNotLoadSavedModel = "could not load tensorflow saved model"
+
+// NewServiceFromSavedModel creates a new service instance from the SavedModel
+// exported to exportDir. It fails like NewService if the tensors of the
+// signature do not match the image dimensions and the number of labels, or if
+// the calibration does not fit the number of labels.
+func NewServiceFromSavedModel(exportDir string, config SavedModelConfig, labels []Label, targetImageDimensions int, options ...Option) (*Service, error) {
+	tags := config.Tags
+	if len(tags) == 0 {
+		tags = []string{DefaultSavedModelTag}
+	}
+	signatureName := config.SignatureName
+	if signatureName == "" {
+		signatureName = DefaultSignatureName
+	}
+
+	savedModel, err := tf.LoadSavedModel(exportDir, tags, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotLoadSavedModel)
+	}
+
+	inputName, outputName, err := signatureTensorNames(savedModel.Signatures, signatureName, config.InputName, config.OutputName)
+	if err != nil {
+		savedModel.Session.Close()
+		return nil, err
+	}
+
+	input, output, err := modelOutputs(savedModel.Graph, inputName, outputName, targetImageDimensions, len(labels))
+	if err != nil {
+		savedModel.Session.Close()
+		return nil, err
+	}
+
+	service := &Service{}
+	if err := service.init(labels, targetImageDimensions, newTensorflowBackend(savedModel.Graph, input, output, savedModel.Session), options); err != nil {
+		savedModel.Session.Close()
+		return nil, err
+	}
+
+	return service, nil
+}
+
+// signatureTensorNames returns the names of the input and output tensors of
+// the signature
+func signatureTensorNames(signatures map[string]tf.Signature, signatureName, inputName, outputName string) (string, string, error) {
+	signature, ok := signatures[signatureName]
+	if !ok {
+		return "", "", errors.Wrapf(ErrInvalidModel, "signature %q does not exist, the model has %s", signatureName, strings.Join(sortedKeys(signatures), ", "))
+	}
+
+	input, err := signatureTensorName(signature.Inputs, inputName)
+	if err != nil {
+		return "", "", errors.Wrapf(ErrInvalidModel, "input of signature %q: %v", signatureName, err)
+	}
+	output, err := signatureTensorName(signature.Outputs, outputName)
+	if err != nil {
+		return "", "", errors.Wrapf(ErrInvalidModel, "output of signature %q: %v", signatureName, err)
+	}
+
+	return input, output, nil
+}
+
+func signatureTensorName(tensors map[string]tf.TensorInfo, name string) (string, error) {
+	if name != "" {
+		if tensor, ok := tensors[name]; ok {
+			return tensor.Name, nil
+		}
+		// not a key of the signature, so it names a tensor of the graph
+		return name, nil
+	}
+
+	switch len(tensors) {
+	case 0:
+		return "", errors.New("the signature has none")
+	case 1:
+		for _, tensor := range tensors {
+			return tensor.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("choose one of %s", strings.Join(sortedKeys(tensors), ", "))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
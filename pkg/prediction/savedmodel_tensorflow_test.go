@@ -0,0 +1,54 @@
+//go:build !notensorflow
+
+package prediction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tf "github.com/wamuir/graft/tensorflow"
+)
+
+var testSignatures = map[string]tf.Signature{
+	DefaultSignatureName: {
+		Inputs:  map[string]tf.TensorInfo{"input_1": {Name: "serving_default_input_1:0"}},
+		Outputs: map[string]tf.TensorInfo{"dense_3": {Name: "StatefulPartitionedCall:0"}},
+	},
+	"multi": {
+		Inputs: map[string]tf.TensorInfo{"image": {Name: "multi_image:0"}, "mask": {Name: "multi_mask:0"}},
+		Outputs: map[string]tf.TensorInfo{
+			"probabilities": {Name: "StatefulPartitionedCall_1:0"},
+			"logits":        {Name: "StatefulPartitionedCall_1:1"},
+		},
+	},
+}
+
+func Test_signatureTensorNames_discovers_single_tensors(t *testing.T) {
+	input, output, err := signatureTensorNames(testSignatures, DefaultSignatureName, "", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "serving_default_input_1:0", input)
+	assert.Equal(t, "StatefulPartitionedCall:0", output)
+}
+
+func Test_signatureTensorNames_by_key_or_tensor_name(t *testing.T) {
+	input, output, err := signatureTensorNames(testSignatures, "multi", "image", "StatefulPartitionedCall_1:1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "multi_image:0", input)
+	assert.Equal(t, "StatefulPartitionedCall_1:1", output)
+}
+
+func Test_signatureTensorNames_ambiguous(t *testing.T) {
+	_, _, err := signatureTensorNames(testSignatures, "multi", "", "probabilities")
+
+	assert.ErrorIs(t, err, ErrInvalidModel)
+	assert.ErrorContains(t, err, `input of signature "multi": choose one of image, mask`)
+}
+
+func Test_signatureTensorNames_unknown_signature(t *testing.T) {
+	_, _, err := signatureTensorNames(testSignatures, "predict", "", "")
+
+	assert.ErrorIs(t, err, ErrInvalidModel)
+	assert.ErrorContains(t, err, `signature "predict" does not exist, the model has multi, serving_default`)
+}
@@ -0,0 +1,178 @@
+//go:build !notensorflow
+
+package prediction
+
+import (
+	"github.com/pkg/errors"
+	tf "github.com/wamuir/graft/tensorflow"
+)
+
+const (
+	errorTextTensorflowEmptyResponse          = "tensorflow session produced empty result"
+	errorTextCouldNotExecuteTensorflowSession = "could not execute tensorflow session"
+	errorTextCouldNotImportGraph              = "could not import tensorflow graph"
+	errorTextCouldNotCreateSession            = "could not create tensorflow session"
+	errorTextCouldNotCreateTensorFromImage    = "could not create tensor from input image"
+)
+
+// supportedFormats are the model formats in the order they are looked for
+var supportedFormats = []ModelFormat{FormatSavedModel, FormatFrozenGraph, FormatWeights}
+
+// tensorflowBackend runs the model in a tensorflow session
+type tensorflowBackend struct {
+	graph     *tf.Graph
+	input     tf.Output
+	output    tf.Output
+	session   *tf.Session
+	inference func(inputTensor *tf.Tensor) ([][]float32, error)
+}
+
+// NewService creates a new service instance from the given model and labels.
+// It fails if the operations do not exist in the model, if their shapes do
+// not match the image dimensions and the number of labels, or if the
+// calibration does not fit the number of labels.
+func NewService(model []byte, labels []Label, colorChannels int64, inputOperationName, outputOperationName string, targetImageDimensions int, options ...Option) (*Service, error) {
+	if colorChannels != rgbColorChannels {
+		return nil, errors.Errorf("only %d color channels are supported, got %d", rgbColorChannels, colorChannels)
+	}
+
+	graph, err := createTensorFlowGraphFromModel(model)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotImportGraph)
+	}
+
+	input, output, err := modelOutputs(graph, inputOperationName, outputOperationName, targetImageDimensions, len(labels))
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := tf.NewSession(graph, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotCreateSession)
+	}
+
+	service := &Service{}
+	if err := service.init(labels, targetImageDimensions, newTensorflowBackend(graph, input, output, session), options); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return service, nil
+}
+
+func newTensorflowBackend(graph *tf.Graph, input, output tf.Output, session *tf.Session) *tensorflowBackend {
+	b := &tensorflowBackend{graph: graph, input: input, output: output, session: session}
+	b.inference = b.runInference
+	return b
+}
+
+func (b *tensorflowBackend) run(inputs [][]float32, height, width int) ([][]float32, error) {
+	inputTensor, err := makeBatchTensor(inputs, height, width)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotProcessInputImage)
+	}
+
+	return b.inference(inputTensor)
+}
+
+// runInference returns one row of probabilities per image in the input batch
+func (b *tensorflowBackend) runInference(inputTensor *tf.Tensor) ([][]float32, error) {
+	results, err := b.session.Run(
+		map[tf.Output]*tf.Tensor{
+			b.input: inputTensor,
+		},
+		[]tf.Output{
+			b.output,
+		},
+		nil)
+
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotExecuteTensorflowSession)
+	} else if len(results) == 0 {
+		return nil, errors.New(errorTextTensorflowEmptyResponse)
+	}
+
+	return results[0].Value().([][]float32), nil
+}
+
+// embed fetches the given tensor of the graph instead of the output. Each
+// embedding is the tensor of one image flattened in row major order.
+func (b *tensorflowBackend) embed(inputs [][]float32, height, width int, tensor string) ([][]float32, error) {
+	output, err := graphOutput(b.graph, tensor)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not find embedding tensor")
+	}
+
+	inputTensor, err := makeBatchTensor(inputs, height, width)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotProcessInputImage)
+	}
+
+	results, err := b.session.Run(map[tf.Output]*tf.Tensor{b.input: inputTensor}, []tf.Output{output}, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotExecuteTensorflowSession)
+	} else if len(results) == 0 {
+		return nil, errors.New(errorTextTensorflowEmptyResponse)
+	}
+
+	return flattenBatch(results[0].Value())
+}
+
+// flattenBatch flattens every entry of a batch of float32 tensors with two or
+// four dimensions, the shapes of dense and convolutional layers
+func flattenBatch(value any) ([][]float32, error) {
+	switch batch := value.(type) {
+	case [][]float32:
+		return batch, nil
+	case [][][][]float32:
+		flattened := make([][]float32, len(batch))
+		for i, rows := range batch {
+			for _, row := range rows {
+				for _, values := range row {
+					flattened[i] = append(flattened[i], values...)
+				}
+			}
+		}
+		return flattened, nil
+	}
+
+	return nil, errors.Errorf("embedding tensor of type %T is not supported, use a float32 tensor with 2 or 4 dimensions", value)
+}
+
+// close releases the tensorflow session
+func (b *tensorflowBackend) close() error {
+	if b.session == nil {
+		return nil
+	}
+	return b.session.Close()
+}
+
+func createTensorFlowGraphFromModel(model []byte) (*tf.Graph, error) {
+	// Construct an in-memory graph from the serialized form.
+	graph := tf.NewGraph()
+	if err := graph.Import(model, ""); err != nil {
+		return nil, err
+	}
+
+	return graph, nil
+}
+
+// makeBatchTensor stacks the inputs of several images of the same size into a
+// tensor shaped [len(inputs), height, width, 3]
+func makeBatchTensor(inputs [][]float32, height, width int) (*tf.Tensor, error) {
+	batch := make([]float32, 0, len(inputs)*height*width*rgbColorChannels)
+	for _, input := range inputs {
+		batch = append(batch, input...)
+	}
+
+	tensor, err := tf.NewTensor(batch)
+	if err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotCreateTensorFromImage)
+	}
+
+	if err := tensor.Reshape([]int64{int64(len(inputs)), int64(height), int64(width), rgbColorChannels}); err != nil {
+		return nil, errors.Wrap(err, errorTextCouldNotCreateTensorFromImage)
+	}
+
+	return tensor, nil
+}
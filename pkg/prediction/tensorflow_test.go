@@ -0,0 +1,218 @@
+//go:build !notensorflow
+
+package prediction
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocarina/gocsv"
+	"github.com/stretchr/testify/assert"
+	tf "github.com/wamuir/graft/tensorflow"
+	"github.com/wamuir/graft/tensorflow/op"
+)
+
+const (
+	testInputOperationName  = "input_1"
+	testOutputOperationName = "dense_3/Softmax"
+)
+
+// newTestService loads the model from MODEL_PATH. Tests against the real
+// model are skipped when it is not set.
+func newTestService(tb testing.TB, options ...Option) *Service {
+	modelPath := os.Getenv("MODEL_PATH")
+	if modelPath == "" {
+		tb.Skip("MODEL_PATH is not set, skipping test against the real model")
+	}
+
+	model, err := os.ReadFile(filepath.Join(modelPath, "model.pb"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	labelBytes, err := os.ReadFile(filepath.Join(modelPath, "labels.csv"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	var labels []Label
+	if err := gocsv.UnmarshalBytes(labelBytes, &labels); err != nil {
+		tb.Fatal(err)
+	}
+
+	service, err := NewService(model, labels, rgbColorChannels, testInputOperationName, testOutputOperationName, testImageDimensions, options...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { service.Stop() })
+
+	return service
+}
+
+// The scores of the model stay within tolerance of the legacy pipeline, which
+// decoded the re-encoded jpeg in a second tensorflow session
+func Test_PredictImage_scores_within_tolerance_of_jpeg_round_trip(t *testing.T) {
+	service := newTestService(t)
+
+	resized, err := service.resizeImage(testPhotoJPEG(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bounds := resized.Bounds()
+	directTensor, err := makeBatchTensor([][]float32{imageToFloats(resized)}, bounds.Dy(), bounds.Dx())
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, err := service.model.(*tensorflowBackend).runInference(directTensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacyTensor := legacyDecodeJPEG(t, legacyJPEGRoundTrip(t, resized))
+	legacy, err := service.model.(*tensorflowBackend).runInference(legacyTensor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.InDeltaSlice(t, legacy[0], direct[0], 0.02)
+}
+
+// The pure Go backend predicts the same probabilities as tensorflow, when the
+// model has also been exported in the weights format
+func Test_NewServiceFromWeights_within_tolerance_of_tensorflow(t *testing.T) {
+	service := newTestService(t)
+
+	weights, err := os.ReadFile(filepath.Join(os.Getenv("MODEL_PATH"), FormatWeights.FileName()))
+	if os.IsNotExist(err) {
+		t.Skip("no model in the weights format next to the model")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	goService, err := NewServiceFromWeights(weights, service.labels, testImageDimensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer goService.Stop()
+
+	resized, err := service.resizeImage(testPhotoJPEG(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := [][]float32{imageToFloats(resized)}
+
+	expected, err := service.runBatch(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := goService.runBatch(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.InDeltaSlice(t, expected[0], actual[0], weightsTolerance)
+}
+
+// legacyDecodeJPEG runs the normalization graph the preprocessing used to have
+func legacyDecodeJPEG(tb testing.TB, jpegBytes []byte) *tf.Tensor {
+	s := op.NewScope()
+	input := op.Placeholder(s, tf.String)
+	output := op.DecodeJpeg(s, input, op.DecodeJpegChannels(rgbColorChannels))
+	output = op.Cast(s, output, tf.Float)
+	output = op.Sub(s, output, op.Const(s, imagenetMeans[:]))
+	output = op.ExpandDims(s, output, op.Const(s.SubScope("batch"), int32(0)))
+
+	graph, err := s.Finalize()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	session, err := tf.NewSession(graph, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer session.Close()
+
+	tensor, err := tf.NewTensor(string(jpegBytes))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	normalized, err := session.Run(map[tf.Output]*tf.Tensor{input: tensor}, []tf.Output{output}, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return normalized[0]
+}
+
+func BenchmarkPredictImage(b *testing.B) {
+	service := newTestService(b)
+	imageBytes := testPhotoJPEG(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := service.PredictImage(context.Background(), imageBytes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// tensorFloats flattens the values of an input tensor
+func tensorFloats(tb testing.TB, tensor *tf.Tensor) []float32 {
+	switch value := tensor.Value().(type) {
+	case []float32:
+		return value
+	case [][][][]float32:
+		var floats []float32
+		for _, img := range value {
+			for _, row := range img {
+				for _, pixel := range row {
+					floats = append(floats, pixel...)
+				}
+			}
+		}
+		return floats
+	default:
+		tb.Fatalf("unexpected tensor value %T", value)
+		return nil
+	}
+}
+
+func Test_makeBatchTensor(t *testing.T) {
+	inputs := [][]float32{
+		{1, 2, 3, 4, 5, 6},
+		{7, 8, 9, 10, 11, 12},
+	}
+
+	tensor, err := makeBatchTensor(inputs, 1, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 1, 2, 3}, tensor.Shape())
+	assert.Equal(t, []float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, tensorFloats(t, tensor))
+}
+
+func BenchmarkPredictImage_batching(b *testing.B) {
+	imageBytes := testPhotoJPEG(b)
+	benchmarkBatching(b, func(maxBatchSize int) *Service {
+		return newTestService(b,
+			WithWorkers(16), WithQueueSize(1000), WithMaxBatchSize(maxBatchSize), WithMaxBatchWait(2*time.Millisecond))
+	}, imageBytes)
+}
+
+func Test_flattenBatch(t *testing.T) {
+	flattened, err := flattenBatch([][][][]float32{
+		{{{1, 2}, {3, 4}}, {{5, 6}, {7, 8}}},
+		{{{9, 10}, {11, 12}}, {{13, 14}, {15, 16}}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 2, 3, 4, 5, 6, 7, 8}, {9, 10, 11, 12, 13, 14, 15, 16}}, flattened)
+
+	flattened, err = flattenBatch([][]float32{{1, 2}, {3, 4}})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 2}, {3, 4}}, flattened)
+
+	_, err = flattenBatch([][][]float32{{{1}}})
+	assert.EqualError(t, err, "embedding tensor of type [][][]float32 is not supported, use a float32 tensor with 2 or 4 dimensions")
+}
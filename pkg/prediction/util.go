@@ -1,23 +1,34 @@
 package prediction
 
-import "math"
-
-func findMaxAndMaxIndex(probs []float32) (float32, int) {
-	max := float32(math.MinInt32)
-	maxIndex := -1
+import "sort"
 
+// newResult builds a Result from the raw output of the model. The scores are
+// sorted by descending probability, so the first entry is the predicted class.
+func newResult(probs []float32, labels []Label) *Result {
+	scores := make([]ClassScore, 0, len(probs))
 	for i := range probs {
-		value := probs[i]
-		if value > max {
-			max = value
-			maxIndex = i
-		}
+		scores = append(scores, ClassScore{Class: classNameForIndex(labels, i), Probability: probs[i]})
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].Probability > scores[j].Probability
+	})
+
+	result := &Result{Scores: scores}
+	if len(scores) > 0 {
+		result.Class = scores[0].Class
+		result.Probability = scores[0].Probability
 	}
 
-	return max, maxIndex
+	return result
 }
-func findClassWithMaxProbability(probs []float32, labels []Label) (string, float32) {
-	max, maxIndex := findMaxAndMaxIndex(probs)
 
-	return labels[maxIndex].ClassName, max
+func classNameForIndex(labels []Label, index int) string {
+	for _, label := range labels {
+		if label.Index == index {
+			return label.ClassName
+		}
+	}
+
+	return ""
 }
@@ -0,0 +1,44 @@
+package prediction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testLabels = []Label{
+	{Index: 0, ClassName: "cats"},
+	{Index: 1, ClassName: "non_cats"},
+	{Index: 2, ClassName: "dogs"},
+}
+
+func Test_newResult_sorts_scores(t *testing.T) {
+	result := newResult([]float32{0.2, 0.1, 0.7}, testLabels)
+
+	assert.Equal(t, "dogs", result.Class)
+	assert.Equal(t, float32(0.7), result.Probability)
+	assert.Equal(t, []ClassScore{
+		{Class: "dogs", Probability: 0.7},
+		{Class: "cats", Probability: 0.2},
+		{Class: "non_cats", Probability: 0.1},
+	}, result.Scores)
+}
+
+func Test_newResult_uses_label_index(t *testing.T) {
+	labels := []Label{
+		{Index: 1, ClassName: "non_cats"},
+		{Index: 0, ClassName: "cats"},
+	}
+
+	result := newResult([]float32{0.9, 0.1}, labels)
+
+	assert.Equal(t, "cats", result.Class)
+}
+
+func Test_TopK(t *testing.T) {
+	result := newResult([]float32{0.2, 0.1, 0.7}, testLabels)
+
+	assert.Equal(t, []ClassScore{{Class: "dogs", Probability: 0.7}}, result.TopK(1))
+	assert.Len(t, result.TopK(5), 3)
+	assert.Empty(t, result.TopK(-1))
+}
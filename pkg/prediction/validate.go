@@ -0,0 +1,97 @@
+//go:build !notensorflow
+
+package prediction
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	tf "github.com/wamuir/graft/tensorflow"
+)
+
+// modelOutputs looks up the input and output tensors and checks that they
+// fit the configured image dimensions and labels. A name is either the name
+// of an operation, whose first output is used, or "operation:index".
+func modelOutputs(graph *tf.Graph, inputName, outputName string, targetImageDimensions, numLabels int) (tf.Output, tf.Output, error) {
+	var problems []string
+
+	input, err := graphOutput(graph, inputName)
+	if err != nil {
+		problems = append(problems, "input "+err.Error())
+	} else {
+		problems = append(problems, checkInputShape(inputName, input.Shape(), targetImageDimensions)...)
+	}
+
+	output, err := graphOutput(graph, outputName)
+	if err != nil {
+		problems = append(problems, "output "+err.Error())
+	} else {
+		problems = append(problems, checkOutputShape(outputName, output.Shape(), numLabels)...)
+	}
+
+	if len(problems) > 0 {
+		return tf.Output{}, tf.Output{}, errors.Wrap(ErrInvalidModel, strings.Join(problems, "; "))
+	}
+
+	return input, output, nil
+}
+
+// graphOutput returns the output of the graph with the given name
+func graphOutput(graph *tf.Graph, name string) (tf.Output, error) {
+	operationName, index := name, 0
+	if separator := strings.LastIndex(name, ":"); separator >= 0 {
+		parsed, err := strconv.Atoi(name[separator+1:])
+		if err != nil || parsed < 0 {
+			return tf.Output{}, fmt.Errorf("tensor %q has an invalid output index", name)
+		}
+		operationName, index = name[:separator], parsed
+	}
+
+	operation := graph.Operation(operationName)
+	if operation == nil {
+		return tf.Output{}, fmt.Errorf("operation %q does not exist in the graph", operationName)
+	}
+	if index >= operation.NumOutputs() {
+		return tf.Output{}, fmt.Errorf("operation %q has no output %d", operationName, index)
+	}
+
+	return operation.Output(index), nil
+}
+
+// checkInputShape expects [batch, targetImageDimensions, targetImageDimensions, 3].
+// Dimensions unknown to the graph are not checked.
+func checkInputShape(name string, shape tf.Shape, targetImageDimensions int) []string {
+	if shape.NumDimensions() < 0 {
+		return nil
+	}
+	if shape.NumDimensions() != 4 {
+		return []string{fmt.Sprintf("input operation %q has shape %v, expected 4 dimensions [batch, height, width, channels]", name, shape)}
+	}
+
+	var problems []string
+	expected := []int64{-1, int64(targetImageDimensions), int64(targetImageDimensions), rgbColorChannels}
+	for dim := 1; dim < len(expected); dim++ {
+		if size, want := shape.Size(dim), expected[dim]; size >= 0 && size != want {
+			problems = append(problems, fmt.Sprintf("input operation %q has shape %v, expected size %d in dimension %d", name, shape, want, dim))
+		}
+	}
+
+	return problems
+}
+
+// checkOutputShape expects [batch, numLabels]
+func checkOutputShape(name string, shape tf.Shape, numLabels int) []string {
+	if shape.NumDimensions() < 0 {
+		return nil
+	}
+	if shape.NumDimensions() != 2 {
+		return []string{fmt.Sprintf("output operation %q has shape %v, expected 2 dimensions [batch, classes]", name, shape)}
+	}
+	if size := shape.Size(1); size >= 0 && size != int64(numLabels) {
+		return []string{fmt.Sprintf("output operation %q returns %d classes, but there are %d labels", name, size, numLabels)}
+	}
+
+	return nil
+}
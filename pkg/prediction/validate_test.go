@@ -0,0 +1,95 @@
+//go:build !notensorflow
+
+package prediction
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tf "github.com/wamuir/graft/tensorflow"
+)
+
+func Test_modelOutputs_missing_operations(t *testing.T) {
+	_, _, err := modelOutputs(tf.NewGraph(), "input_1", "dense_3/Softmax", 256, 2)
+
+	assert.ErrorIs(t, err, ErrInvalidModel)
+	assert.EqualError(t, err, `input operation "input_1" does not exist in the graph; `+
+		`output operation "dense_3/Softmax" does not exist in the graph: model does not match the configuration`)
+}
+
+func Test_graphOutput(t *testing.T) {
+	graph := tf.NewGraph()
+	operation, err := graph.AddOperation(tf.OpSpec{
+		Type:  "Placeholder",
+		Name:  "serving_default_input_1",
+		Attrs: map[string]interface{}{"dtype": tf.Float},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := graphOutput(graph, "serving_default_input_1")
+	assert.NoError(t, err)
+	assert.Equal(t, operation.Output(0), output)
+
+	output, err = graphOutput(graph, "serving_default_input_1:0")
+	assert.NoError(t, err)
+	assert.Equal(t, operation.Output(0), output)
+
+	_, err = graphOutput(graph, "serving_default_input_1:5")
+	assert.EqualError(t, err, `operation "serving_default_input_1" has no output 5`)
+
+	_, err = graphOutput(graph, "serving_default_input_1:x")
+	assert.EqualError(t, err, `tensor "serving_default_input_1:x" has an invalid output index`)
+}
+
+func Test_checkInputShape(t *testing.T) {
+	tests := []struct {
+		name     string
+		shape    tf.Shape
+		expected []string
+	}{
+		{"matching", tf.MakeShape(-1, 256, 256, 3), nil},
+		{"unknown rank", tf.Shape{}, nil},
+		{"unknown dimensions", tf.MakeShape(-1, -1, -1, 3), nil},
+		{"wrong rank", tf.MakeShape(-1, 256, 256), []string{
+			`input operation "input_1" has shape [?, 256, 256], expected 4 dimensions [batch, height, width, channels]`,
+		}},
+		{"wrong size", tf.MakeShape(-1, 224, 224, 3), []string{
+			`input operation "input_1" has shape [?, 224, 224, 3], expected size 256 in dimension 1`,
+			`input operation "input_1" has shape [?, 224, 224, 3], expected size 256 in dimension 2`,
+		}},
+		{"wrong channels", tf.MakeShape(-1, 256, 256, 1), []string{
+			`input operation "input_1" has shape [?, 256, 256, 1], expected size 3 in dimension 3`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, checkInputShape("input_1", tt.shape, 256))
+		})
+	}
+}
+
+func Test_checkOutputShape(t *testing.T) {
+	tests := []struct {
+		name     string
+		shape    tf.Shape
+		expected []string
+	}{
+		{"matching", tf.MakeShape(-1, 2), nil},
+		{"unknown rank", tf.Shape{}, nil},
+		{"wrong rank", tf.MakeShape(-1), []string{
+			`output operation "dense_3/Softmax" has shape [?], expected 2 dimensions [batch, classes]`,
+		}},
+		{"wrong number of classes", tf.MakeShape(-1, 3), []string{
+			`output operation "dense_3/Softmax" returns 3 classes, but there are 2 labels`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, checkOutputShape("dense_3/Softmax", tt.shape, 2))
+		})
+	}
+}